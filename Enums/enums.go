@@ -1,6 +1,12 @@
 package main
 
-import "fmt"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
 
 type MyType string
 
@@ -15,17 +21,140 @@ type MyType string
 
 type OrderStatus string
 
+// Every constant needs the type written out. In a const block only
+// "Name Type = value" gives Name that type; "Name = value" on its own makes
+// an UNTYPED string constant, which silently converts to any string type.
 const (
-	Recieved OrderStatus = "recieved"
-	Confirmed = "Confirmed"
-	Prepared = "prepared"
-	Delivered = "delivered"
+	Recieved  OrderStatus = "recieved"
+	Confirmed OrderStatus = "confirmed"
+	Prepared  OrderStatus = "prepared"
+	Delivered OrderStatus = "delivered"
+	Cancelled OrderStatus = "cancelled"
+	Refunded  OrderStatus = "refunded"
 )
 
+// ErrInvalidOrderStatus is returned for any value that is not one of the
+// constants above, wherever it comes from: a string, JSON, or a database.
+var ErrInvalidOrderStatus = errors.New("invalid order status")
+
+// AllOrderStatuses returns every status in lifecycle order. It returns a
+// new slice each time, so callers cannot change the list.
+func AllOrderStatuses() []OrderStatus {
+	return []OrderStatus{Recieved, Confirmed, Prepared, Delivered, Cancelled, Refunded}
+}
+
+// String implements fmt.Stringer.
+func (s OrderStatus) String() string {
+	return string(s)
+}
+
+// IsValid reports whether s is one of the known statuses.
+func (s OrderStatus) IsValid() bool {
+	for _, known := range AllOrderStatuses() {
+		if s == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseOrderStatus converts text to an OrderStatus. Case and surrounding
+// space are ignored, so values stored as "Confirmed" by older code still
+// parse; anything unknown is an error.
+func ParseOrderStatus(text string) (OrderStatus, error) {
+	s := OrderStatus(strings.ToLower(strings.TrimSpace(text)))
+	if !s.IsValid() {
+		return "", fmt.Errorf("%w: %q", ErrInvalidOrderStatus, text)
+	}
+	return s, nil
+}
+
+// MarshalText implements encoding.TextMarshaler (used for map keys, XML, ...).
+func (s OrderStatus) MarshalText() ([]byte, error) {
+	if !s.IsValid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidOrderStatus, string(s))
+	}
+	return []byte(s), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *OrderStatus) UnmarshalText(text []byte) error {
+	parsed, err := ParseOrderStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler: a status is a JSON string.
+func (s OrderStatus) MarshalJSON() ([]byte, error) {
+	text, err := s.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(text))
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Only JSON strings naming a
+// known status are accepted.
+func (s *OrderStatus) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidOrderStatus, data)
+	}
+	return s.UnmarshalText([]byte(text))
+}
+
+// Value implements database/sql/driver.Valuer, so an OrderStatus can be
+// passed straight to db.Exec. Invalid statuses are never written.
+func (s OrderStatus) Value() (driver.Value, error) {
+	text, err := s.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return string(text), nil
+}
+
+// Scan implements database/sql.Scanner, so rows.Scan(&status) works.
+// Drivers hand text columns over as string or []byte; NULL and anything
+// else is rejected.
+func (s *OrderStatus) Scan(src any) error {
+	switch v := src.(type) {
+	case string:
+		return s.UnmarshalText([]byte(v))
+	case []byte:
+		return s.UnmarshalText(v)
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidOrderStatus, src)
+	}
+}
+
 func changeOrderStatus(status OrderStatus) {
 	fmt.Println("Changing order status to ",status)
 }
 
-func main() {	
+func main() {
 	changeOrderStatus(Recieved)
-}
\ No newline at end of file
+
+	// Parsing rejects anything that is not a real status
+	s, err := ParseOrderStatus("Confirmed")
+	fmt.Println(s, err) // confirmed <nil>
+	_, err = ParseOrderStatus("shipped")
+	fmt.Println(err) // invalid order status: "shipped"
+
+	// JSON in and out
+	data, _ := json.Marshal(map[string]OrderStatus{"status": Delivered})
+	fmt.Println(string(data)) // {"status":"delivered"}
+	var order struct{ Status OrderStatus }
+	fmt.Println(json.Unmarshal([]byte(`{"Status":"lost"}`), &order)) // invalid order status: "lost"
+
+	// database/sql: Value when writing, Scan when reading
+	v, _ := Prepared.Value()
+	var fromDB OrderStatus
+	err = fromDB.Scan([]byte("refunded"))
+	fmt.Println(v, fromDB, err)   // prepared refunded <nil>
+	fmt.Println(fromDB.Scan(nil)) // invalid order status: cannot scan <nil>
+
+	fmt.Println(AllOrderStatuses())
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseOrderStatus(t *testing.T) {
+	tests := []struct {
+		text string
+		want OrderStatus // "" means rejected
+	}{
+		{"recieved", Recieved},
+		{"confirmed", Confirmed},
+		{"prepared", Prepared},
+		{"delivered", Delivered},
+		{"cancelled", Cancelled},
+		{"refunded", Refunded},
+		{"Confirmed", Confirmed}, // stored by the old constant, before it was lower-cased
+		{"  DELIVERED\n", Delivered},
+		{"", ""},
+		{"shipped", ""},
+		{"received", ""}, // the enum keeps its historical spelling
+		{"confirmed!", ""},
+	}
+	for _, tt := range tests {
+		got, err := ParseOrderStatus(tt.text)
+		if tt.want == "" {
+			if !errors.Is(err, ErrInvalidOrderStatus) {
+				t.Errorf("ParseOrderStatus(%q) = %q, %v; want ErrInvalidOrderStatus", tt.text, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseOrderStatus(%q) = %q, %v; want %q", tt.text, got, err, tt.want)
+		}
+	}
+}
+
+func TestOrderStatusRoundTrips(t *testing.T) {
+	for _, s := range AllOrderStatuses() {
+		if !s.IsValid() || s.String() != string(s) {
+			t.Errorf("%q: IsValid = %v, String = %q", s, s.IsValid(), s.String())
+		}
+
+		data, err := json.Marshal(s)
+		if err != nil {
+			t.Fatalf("json.Marshal(%q): %v", s, err)
+		}
+		var fromJSON OrderStatus
+		if err := json.Unmarshal(data, &fromJSON); err != nil || fromJSON != s {
+			t.Errorf("JSON %s decoded as %q, %v", data, fromJSON, err)
+		}
+
+		text, err := s.MarshalText()
+		if err != nil {
+			t.Fatalf("MarshalText(%q): %v", s, err)
+		}
+		var fromText OrderStatus
+		if err := fromText.UnmarshalText(text); err != nil || fromText != s {
+			t.Errorf("text %s decoded as %q, %v", text, fromText, err)
+		}
+
+		v, err := s.Value()
+		if err != nil {
+			t.Fatalf("Value(%q): %v", s, err)
+		}
+		for _, src := range []any{v, []byte(v.(string))} {
+			var fromDB OrderStatus
+			if err := fromDB.Scan(src); err != nil || fromDB != s {
+				t.Errorf("Scan(%#v) = %q, %v", src, fromDB, err)
+			}
+		}
+	}
+}
+
+func TestOrderStatusRejectsUnknownValues(t *testing.T) {
+	unknown := OrderStatus("shipped")
+	if unknown.IsValid() {
+		t.Error("IsValid accepted an unknown status")
+	}
+	if _, err := json.Marshal(unknown); !errors.Is(err, ErrInvalidOrderStatus) {
+		t.Errorf("json.Marshal = %v, want ErrInvalidOrderStatus", err)
+	}
+	if _, err := unknown.MarshalText(); !errors.Is(err, ErrInvalidOrderStatus) {
+		t.Errorf("MarshalText = %v, want ErrInvalidOrderStatus", err)
+	}
+	if _, err := unknown.Value(); !errors.Is(err, ErrInvalidOrderStatus) {
+		t.Errorf("Value = %v, want ErrInvalidOrderStatus", err)
+	}
+
+	for _, data := range []string{`"shipped"`, `""`, `3`, `null`, `["confirmed"]`} {
+		s := Prepared
+		err := json.Unmarshal([]byte(data), &s)
+		if !errors.Is(err, ErrInvalidOrderStatus) || s != Prepared {
+			t.Errorf("json.Unmarshal(%s) = %q, %v; want ErrInvalidOrderStatus and no change", data, s, err)
+		}
+	}
+	var wrapped struct{ Status OrderStatus }
+	if err := json.Unmarshal([]byte(`{"Status":"lost"}`), &wrapped); !errors.Is(err, ErrInvalidOrderStatus) {
+		t.Errorf("struct field = %v, want ErrInvalidOrderStatus", err)
+	}
+
+	for _, src := range []any{nil, "shipped", []byte("lost"), 3, int64(1)} {
+		var s OrderStatus
+		if err := s.Scan(src); !errors.Is(err, ErrInvalidOrderStatus) {
+			t.Errorf("Scan(%#v) = %v, want ErrInvalidOrderStatus", src, err)
+		}
+	}
+}
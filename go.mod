@@ -0,0 +1,7 @@
+module github.com/Anurag07-07/Go
+
+go 1.25.4
+
+replace github.com/golang => ./packages
+
+require github.com/golang v0.0.0-00010101000000-000000000000
@@ -0,0 +1,185 @@
+package main
+
+// This file holds the pretend "servers" behind razorpay and stripe.
+// Real gateways keep the state of every charge on their side; fakeBackend
+// keeps the same state in memory so that authorize → capture → refund can
+// be exercised offline, with the same rules the real APIs enforce.
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/money"
+)
+
+// chargeState is where a charge is in its life:
+//
+//	authorized ──capture──► captured ──refund──► (partially) refunded
+//	     │
+//	     └──────void──────► voided
+type chargeState int
+
+const (
+	stateAuthorized chargeState = iota // funds held on the card, not yet taken
+	stateCaptured                      // funds taken; may be refunded
+	stateVoided                        // hold released; nothing was taken
+)
+
+func (s chargeState) String() string {
+	return [...]string{"authorized", "captured", "voided"}[s]
+}
+
+// chargeRecord is everything the backend remembers about one charge.
+type chargeRecord struct {
+	state      chargeState
+	authorized money.Money // amount held at authorization
+	captured   money.Money // amount actually taken (≤ authorized)
+	refunded   money.Money // total refunded so far (≤ captured)
+}
+
+// fakeBackend is an in-process, concurrency-safe stand-in for a gateway API.
+type fakeBackend struct {
+	name     string // "razorpay" / "stripe" — used in errors
+	idPrefix string // prefix of charge IDs, like the real gateways use
+
+	mu      sync.Mutex // guards everything below
+	seq     int
+	charges map[string]*chargeRecord
+}
+
+func newFakeBackend(name, idPrefix string) *fakeBackend {
+	return &fakeBackend{name: name, idPrefix: idPrefix, charges: make(map[string]*chargeRecord)}
+}
+
+// fail builds a *ChargeError for this backend.
+func (b *fakeBackend) fail(code string, err error) error {
+	return &ChargeError{Gateway: b.name, Code: code, Err: err}
+}
+
+// authorize validates the request, honours context cancellation, turns the
+// test sources into the matching typed error and otherwise places a hold.
+func (b *fakeBackend) authorize(ctx context.Context, req ChargeRequest) (Authorization, error) {
+	if !req.Amount.IsPositive() { // zero, negative, or the zero Money{} with no currency
+		return Authorization{}, b.fail("amount_too_small", ErrInvalidAmount)
+	}
+	if err := ctx.Err(); err != nil { // caller gave up (deadline / cancel)
+		return Authorization{}, b.fail("request_cancelled", fmt.Errorf("%w: %v", ErrNetwork, err))
+	}
+
+	switch req.Source {
+	case sourceDeclined:
+		return Authorization{}, b.fail("card_declined", ErrDeclined)
+	case sourceInsufficientFunds:
+		return Authorization{}, b.fail("insufficient_funds", ErrInsufficientFunds)
+	case sourceTimeout:
+		return Authorization{}, b.fail("timeout", ErrNetwork)
+	}
+
+	zero, _ := money.Zero(req.Amount.Currency())
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	id := fmt.Sprintf("%s%06d", b.idPrefix, b.seq)
+	b.charges[id] = &chargeRecord{state: stateAuthorized, authorized: req.Amount, captured: zero, refunded: zero}
+	return Authorization{ID: id, Gateway: b.name, Amount: req.Amount, CreatedAt: time.Now()}, nil
+}
+
+// capture takes amount (at most the authorized amount) from a hold. Like the
+// real gateways, a hold can be captured only once; any uncaptured rest is
+// released.
+func (b *fakeBackend) capture(ctx context.Context, id string, amount money.Money) (ChargeResult, error) {
+	if err := ctx.Err(); err != nil {
+		return ChargeResult{}, b.fail("request_cancelled", fmt.Errorf("%w: %v", ErrNetwork, err))
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rec, ok := b.charges[id]
+	if !ok {
+		return ChargeResult{}, b.fail("resource_missing", fmt.Errorf("%w: %s", ErrChargeNotFound, id))
+	}
+	if rec.state != stateAuthorized {
+		return ChargeResult{}, b.fail("charge_already_"+rec.state.String(), fmt.Errorf("%w: cannot capture a %s charge", ErrInvalidState, rec.state))
+	}
+	if cmp, err := amount.Compare(rec.authorized); err != nil || !amount.IsPositive() {
+		return ChargeResult{}, b.fail("amount_invalid", fmt.Errorf("%w: capture %s of %s", ErrInvalidAmount, amount, rec.authorized))
+	} else if cmp > 0 {
+		return ChargeResult{}, b.fail("amount_too_large", fmt.Errorf("%w: capture %s, authorized %s", ErrOverCapture, amount, rec.authorized))
+	}
+
+	rec.state = stateCaptured
+	rec.captured = amount
+	return ChargeResult{ID: id, Gateway: b.name, Amount: amount, CreatedAt: time.Now()}, nil
+}
+
+// void releases a hold that has not been captured.
+func (b *fakeBackend) void(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return b.fail("request_cancelled", fmt.Errorf("%w: %v", ErrNetwork, err))
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rec, ok := b.charges[id]
+	if !ok {
+		return b.fail("resource_missing", fmt.Errorf("%w: %s", ErrChargeNotFound, id))
+	}
+	if rec.state != stateAuthorized {
+		return b.fail("charge_already_"+rec.state.String(), fmt.Errorf("%w: cannot void a %s charge", ErrInvalidState, rec.state))
+	}
+	rec.state = stateVoided
+	return nil
+}
+
+// refund returns part or all of a captured charge. The running total of
+// refunds can never exceed what was captured.
+func (b *fakeBackend) refund(ctx context.Context, id string, amount money.Money) (RefundResult, error) {
+	if err := ctx.Err(); err != nil {
+		return RefundResult{}, b.fail("request_cancelled", fmt.Errorf("%w: %v", ErrNetwork, err))
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rec, ok := b.charges[id]
+	if !ok {
+		return RefundResult{}, b.fail("resource_missing", fmt.Errorf("%w: %s", ErrChargeNotFound, id))
+	}
+	if rec.state != stateCaptured {
+		return RefundResult{}, b.fail("charge_not_captured", fmt.Errorf("%w: cannot refund a %s charge", ErrInvalidState, rec.state))
+	}
+
+	refundable, _ := rec.captured.Sub(rec.refunded)
+	cmp, err := amount.Compare(refundable)
+	if err != nil || !amount.IsPositive() {
+		return RefundResult{}, b.fail("amount_invalid", fmt.Errorf("%w: refund %s", ErrInvalidAmount, amount))
+	}
+	if cmp > 0 {
+		return RefundResult{}, b.fail("amount_exceeds_refundable", fmt.Errorf("%w: requested %s, refundable %s", ErrOverRefund, amount, refundable))
+	}
+
+	rec.refunded, _ = rec.refunded.Add(amount)
+	remaining, _ := refundable.Sub(amount)
+	b.seq++
+	return RefundResult{
+		ID:        fmt.Sprintf("rfnd_%06d", b.seq),
+		ChargeID:  id,
+		Gateway:   b.name,
+		Amount:    amount,
+		Remaining: remaining,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// refundable reports how much of a charge can still be refunded.
+func (b *fakeBackend) refundable(id string) (money.Money, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rec, ok := b.charges[id]
+	if !ok {
+		return money.Money{}, b.fail("resource_missing", fmt.Errorf("%w: %s", ErrChargeNotFound, id))
+	}
+	if rec.state != stateCaptured {
+		return money.Zero(rec.authorized.Currency())
+	}
+	return rec.captured.Sub(rec.refunded)
+}
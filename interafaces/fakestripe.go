@@ -0,0 +1,366 @@
+package main
+
+// This file is a small FAKE of the Stripe API, served by net/http/httptest
+// on a random localhost port. The HTTP client in stripe.go talks to it
+// exactly as it would to the real thing, so the whole request → response →
+// error mapping path runs without touching the network.
+//
+// Behind the HTTP layer the money rules come from the same fakeBackend the
+// razorpay simulation uses (backend.go); this file only adds what Stripe
+// adds on top: PaymentIntents, API keys, idempotency keys and JSON errors.
+// failNext lets a demo make the server answer 429/503 to exercise retries.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/money"
+)
+
+// fakeStripeServer is a running fake. Its URL field (from the embedded
+// *httptest.Server) is the base URL to hand to newStripe.
+type fakeStripeServer struct {
+	*httptest.Server
+	apiKey  string
+	backend *fakeBackend
+
+	mu       sync.Mutex // serializes requests; guards everything below
+	seq      int
+	intents  map[string]*fakeIntent
+	replies  map[string]fakeReply // saved responses by Idempotency-Key
+	faults   []int                // statuses to answer the next requests with
+	requests int                  // requests received, including faulted ones
+}
+
+type fakeIntent struct {
+	pi            paymentIntent
+	source        string
+	description   string
+	captureMethod string // "automatic" or "manual"
+	chargeID      string // the backend's ID, once confirmed
+}
+
+type fakeReply struct {
+	fingerprint string // hash of the request body that used the key
+	status      int
+	body        []byte
+}
+
+// newFakeStripeServer starts a fake that accepts apiKey. Call Close when done.
+func newFakeStripeServer(apiKey string) *fakeStripeServer {
+	s := &fakeStripeServer{
+		apiKey:  apiKey,
+		backend: newFakeBackend("stripe", "ch_"),
+		intents: make(map[string]*fakeIntent),
+		replies: make(map[string]fakeReply),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/payment_intents", s.createIntent)
+	mux.HandleFunc("GET /v1/payment_intents/{id}", s.getIntent)
+	mux.HandleFunc("POST /v1/payment_intents/{id}/confirm", s.confirmIntent)
+	mux.HandleFunc("POST /v1/payment_intents/{id}/capture", s.captureIntent)
+	mux.HandleFunc("POST /v1/payment_intents/{id}/cancel", s.cancelIntent)
+	mux.HandleFunc("POST /v1/refunds", s.createRefund)
+	s.Server = httptest.NewServer(s.middleware(mux))
+	return s
+}
+
+// failNext makes the server answer the next len(statuses) requests with
+// these status codes (e.g. 429, 503) instead of handling them.
+func (s *fakeStripeServer) failNext(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, statuses...)
+}
+
+// requestCount reports how many requests the server has received.
+func (s *fakeStripeServer) requestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// middleware does what every endpoint needs: one request at a time, API key
+// check, injected faults, and replaying saved replies for a repeated
+// Idempotency-Key.
+func (s *fakeStripeServer) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests++
+
+		if r.Header.Get("Authorization") != "Bearer "+s.apiKey {
+			writeStripeError(w, http.StatusUnauthorized, "invalid_request_error", "api_key_invalid", "", "Invalid API key provided.")
+			return
+		}
+		if len(s.faults) > 0 {
+			status := s.faults[0]
+			s.faults = s.faults[1:]
+			kind := "api_error"
+			if status == http.StatusTooManyRequests {
+				kind = "rate_limit_error"
+			}
+			writeStripeError(w, status, kind, "", "", http.StatusText(status))
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			writeStripeError(w, http.StatusBadRequest, "invalid_request_error", "parameter_invalid", "", err.Error())
+			return
+		}
+
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		fingerprint := r.Method + " " + r.URL.Path + "?" + r.PostForm.Encode()
+		sum := sha256.Sum256([]byte(fingerprint))
+		fingerprint = hex.EncodeToString(sum[:])
+		if saved, ok := s.replies[key]; ok {
+			if saved.fingerprint != fingerprint {
+				writeStripeError(w, http.StatusBadRequest, "idempotency_error", "idempotency_key_in_use", "",
+					"Keys for idempotent requests can only be used with the same parameters they were first used with.")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(saved.status)
+			w.Write(saved.body)
+			return
+		}
+
+		rec := httptest.NewRecorder()
+		next.ServeHTTP(rec, r)
+		if rec.Code < 500 { // like Stripe, server errors are not saved, so they can be retried
+			s.replies[key] = fakeReply{fingerprint: fingerprint, status: rec.Code, body: rec.Body.Bytes()}
+		}
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	})
+}
+
+func (s *fakeStripeServer) createIntent(w http.ResponseWriter, r *http.Request) {
+	minor, err := strconv.ParseInt(r.PostForm.Get("amount"), 10, 64)
+	if err != nil {
+		writeStripeError(w, http.StatusBadRequest, "invalid_request_error", "parameter_invalid_integer", "", "Invalid integer: amount")
+		return
+	}
+	if _, err := money.New(minor, strings.ToUpper(r.PostForm.Get("currency"))); err != nil {
+		writeStripeError(w, http.StatusBadRequest, "invalid_request_error", "parameter_invalid", "", err.Error())
+		return
+	}
+	captureMethod := r.PostForm.Get("capture_method")
+	if captureMethod == "" {
+		captureMethod = "automatic"
+	}
+
+	s.seq++
+	in := &fakeIntent{
+		pi: paymentIntent{
+			ID:       fmt.Sprintf("pi_%06d", s.seq),
+			Amount:   minor,
+			Currency: strings.ToLower(r.PostForm.Get("currency")),
+			Status:   "requires_confirmation",
+			Created:  time.Now().Unix(),
+		},
+		source:        r.PostForm.Get("payment_method"),
+		description:   r.PostForm.Get("description"),
+		captureMethod: captureMethod,
+	}
+	s.intents[in.pi.ID] = in
+	writeJSON(w, http.StatusOK, in.pi)
+}
+
+func (s *fakeStripeServer) getIntent(w http.ResponseWriter, r *http.Request) {
+	if in := s.intent(w, r.PathValue("id")); in != nil {
+		writeJSON(w, http.StatusOK, in.pi)
+	}
+}
+
+func (s *fakeStripeServer) confirmIntent(w http.ResponseWriter, r *http.Request) {
+	in := s.intent(w, r.PathValue("id"))
+	if in == nil || !s.requireStatus(w, in, "requires_confirmation") {
+		return
+	}
+	amount := money.MustNew(in.pi.Amount, strings.ToUpper(in.pi.Currency)) // checked in createIntent
+	auth, err := s.backend.authorize(r.Context(), ChargeRequest{Amount: amount, Source: in.source, Description: in.description})
+	if err != nil {
+		if !errors.Is(err, ErrNetwork) { // a network failure can be retried as is
+			in.pi.Status = "requires_payment_method"
+		}
+		writeBackendError(w, err)
+		return
+	}
+	in.chargeID = auth.ID
+	in.pi.Status = "requires_capture"
+
+	if in.captureMethod == "automatic" {
+		if _, err := s.backend.capture(r.Context(), in.chargeID, amount); err != nil {
+			writeBackendError(w, err)
+			return
+		}
+		in.pi.Status = "succeeded"
+		in.pi.AmountReceived = in.pi.Amount
+	}
+	writeJSON(w, http.StatusOK, in.pi)
+}
+
+func (s *fakeStripeServer) captureIntent(w http.ResponseWriter, r *http.Request) {
+	in := s.intent(w, r.PathValue("id"))
+	if in == nil || !s.requireStatus(w, in, "requires_capture") {
+		return
+	}
+	minor := in.pi.Amount
+	if v := r.PostForm.Get("amount_to_capture"); v != "" {
+		var err error
+		if minor, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeStripeError(w, http.StatusBadRequest, "invalid_request_error", "parameter_invalid_integer", "", "Invalid integer: amount_to_capture")
+			return
+		}
+	}
+	result, err := s.backend.capture(r.Context(), in.chargeID, money.MustNew(minor, strings.ToUpper(in.pi.Currency)))
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	in.pi.Status = "succeeded"
+	in.pi.AmountReceived = result.Amount.Minor()
+	writeJSON(w, http.StatusOK, in.pi)
+}
+
+func (s *fakeStripeServer) cancelIntent(w http.ResponseWriter, r *http.Request) {
+	in := s.intent(w, r.PathValue("id"))
+	if in == nil || !s.requireStatus(w, in, "requires_confirmation", "requires_payment_method", "requires_capture") {
+		return
+	}
+	if in.chargeID != "" {
+		if err := s.backend.void(r.Context(), in.chargeID); err != nil {
+			writeBackendError(w, err)
+			return
+		}
+	}
+	in.pi.Status = "canceled"
+	writeJSON(w, http.StatusOK, in.pi)
+}
+
+func (s *fakeStripeServer) createRefund(w http.ResponseWriter, r *http.Request) {
+	in := s.intent(w, r.PostForm.Get("payment_intent"))
+	if in == nil {
+		return
+	}
+	if in.pi.Status != "succeeded" {
+		writeStripeError(w, http.StatusBadRequest, "invalid_request_error", "charge_not_captured", "",
+			fmt.Sprintf("PaymentIntent %s has status %s and cannot be refunded.", in.pi.ID, in.pi.Status))
+		return
+	}
+	minor := in.pi.AmountReceived - in.pi.AmountRefunded // default: everything that is left
+	if v := r.PostForm.Get("amount"); v != "" {
+		var err error
+		if minor, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeStripeError(w, http.StatusBadRequest, "invalid_request_error", "parameter_invalid_integer", "", "Invalid integer: amount")
+			return
+		}
+	}
+	refund, err := s.backend.refund(r.Context(), in.chargeID, money.MustNew(minor, strings.ToUpper(in.pi.Currency)))
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	in.pi.AmountRefunded += refund.Amount.Minor()
+
+	resp := map[string]any{
+		"id":             refund.ID,
+		"object":         "refund",
+		"amount":         refund.Amount.Minor(),
+		"currency":       in.pi.Currency,
+		"status":         "succeeded",
+		"created":        refund.CreatedAt.Unix(),
+		"payment_intent": in.pi.ID,
+	}
+	if r.PostForm.Get("expand[]") == "payment_intent" {
+		resp["payment_intent"] = in.pi
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// intent looks up a PaymentIntent, answering 404 if there is none.
+func (s *fakeStripeServer) intent(w http.ResponseWriter, id string) *fakeIntent {
+	in, ok := s.intents[id]
+	if !ok {
+		writeStripeError(w, http.StatusNotFound, "invalid_request_error", "resource_missing", "",
+			fmt.Sprintf("No such payment_intent: '%s'", id))
+		return nil
+	}
+	return in
+}
+
+// requireStatus answers 400 unless the intent is in one of the statuses.
+func (s *fakeStripeServer) requireStatus(w http.ResponseWriter, in *fakeIntent, statuses ...string) bool {
+	for _, st := range statuses {
+		if in.pi.Status == st {
+			return true
+		}
+	}
+	writeStripeError(w, http.StatusBadRequest, "invalid_request_error", "payment_intent_unexpected_state", "",
+		fmt.Sprintf("This PaymentIntent's status is %s.", in.pi.Status))
+	return false
+}
+
+// writeBackendError turns a fakeBackend error into the response Stripe
+// would send for it.
+func writeBackendError(w http.ResponseWriter, err error) {
+	msg := err.Error()
+	var chargeErr *ChargeError
+	if errors.As(err, &chargeErr) {
+		msg = chargeErr.Err.Error()
+		// "<sentinel>: <detail>" — the client adds the sentinel back itself.
+		if _, detail, ok := strings.Cut(msg, ": "); ok {
+			msg = detail
+		}
+	}
+	switch {
+	case errors.Is(err, ErrInsufficientFunds):
+		writeStripeError(w, http.StatusPaymentRequired, "card_error", "card_declined", "insufficient_funds", "Your card has insufficient funds.")
+	case errors.Is(err, ErrDeclined):
+		writeStripeError(w, http.StatusPaymentRequired, "card_error", "card_declined", "generic_decline", "Your card was declined.")
+	case errors.Is(err, ErrNetwork):
+		writeStripeError(w, http.StatusServiceUnavailable, "api_error", "", "", "The card network did not respond.")
+	case errors.Is(err, ErrChargeNotFound):
+		writeStripeError(w, http.StatusNotFound, "invalid_request_error", "resource_missing", "", msg)
+	case errors.Is(err, ErrInvalidState):
+		writeStripeError(w, http.StatusBadRequest, "invalid_request_error", "payment_intent_unexpected_state", "", msg)
+	case errors.Is(err, ErrOverCapture), errors.Is(err, ErrOverRefund):
+		writeStripeError(w, http.StatusBadRequest, "invalid_request_error", "amount_too_large", "", msg)
+	case errors.Is(err, ErrInvalidAmount):
+		writeStripeError(w, http.StatusBadRequest, "invalid_request_error", "amount_too_small", "", msg)
+	default:
+		writeStripeError(w, http.StatusInternalServerError, "api_error", "", "", msg)
+	}
+}
+
+func writeStripeError(w http.ResponseWriter, status int, kind, code, declineCode, message string) {
+	var body stripeAPIError
+	body.Error.Type = kind
+	body.Error.Code = code
+	body.Error.DeclineCode = declineCode
+	body.Error.Message = message
+	writeJSON(w, status, body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,143 @@
+package main
+
+// This file makes payment.makePayment safe to retry.
+//
+// The problem: makePayment times out on the caller's side, but the charge
+// actually went through. The caller retries and the customer pays twice.
+//
+// The fix, used by every real payment API: the caller sends an IDEMPOTENCY
+// KEY (any unique string, e.g. the order ID) with the request. The first
+// call with a key does the work and remembers the outcome; every later call
+// with the same key gets the remembered outcome instead of a second charge.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Errors returned by an IdempotencyStore (and so by makePayment).
+var (
+	ErrIdempotencyConflict = errors.New("idempotency key reused with a different request")
+	ErrRequestInProgress   = errors.New("a request with this idempotency key is still in progress")
+)
+
+// DefaultIdempotencyTTL is how long keys are remembered when no TTL is given.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// ChargeOutcome is what gets remembered for a key: the result of the first
+// call, or the error it failed with.
+type ChargeOutcome struct {
+	Result ChargeResult
+	Err    error
+}
+
+// IdempotencyStore remembers outcomes by idempotency key. It is an
+// interface so the in-memory version below can be swapped for a shared one
+// (Redis, a database table) when several servers take payments.
+//
+// The protocol for one call is:
+//
+//	saved, err := store.Begin(key, fingerprint)
+//	  err != nil   → conflict or still in progress; give up
+//	  saved != nil → the key is done; return *saved without charging again
+//	  otherwise    → the key is now reserved for us; do the work, then
+//	                 Finish(key, outcome) to remember it, or Abandon(key)
+//	                 to let a retry try again
+type IdempotencyStore interface {
+	Begin(key, fingerprint string) (*ChargeOutcome, error)
+	Finish(key string, outcome ChargeOutcome)
+	Abandon(key string)
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore kept in a map. It is safe
+// for concurrent use.
+type MemoryIdempotencyStore struct {
+	ttl time.Duration
+	now func() time.Time // time.Now, replaceable for demos and tests
+
+	mu      sync.Mutex // guards entries
+	entries map[string]*idempotencyEntry
+}
+
+type idempotencyEntry struct {
+	fingerprint string // hash of the request that claimed the key
+	done        bool   // false while the first call is still running
+	outcome     ChargeOutcome
+	expires     time.Time
+}
+
+// newMemoryIdempotencyStore returns a store that forgets keys ttl after they
+// were last used. A ttl of zero or less means DefaultIdempotencyTTL.
+func newMemoryIdempotencyStore(ttl time.Duration) *MemoryIdempotencyStore {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	return &MemoryIdempotencyStore{ttl: ttl, now: time.Now, entries: make(map[string]*idempotencyEntry)}
+}
+
+// Begin implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Begin(key, fingerprint string) (*ChargeOutcome, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.prune(now)
+
+	if e, ok := s.entries[key]; ok {
+		switch {
+		case e.fingerprint != fingerprint:
+			return nil, ErrIdempotencyConflict
+		case !e.done:
+			return nil, ErrRequestInProgress
+		}
+		outcome := e.outcome // copy, so the caller cannot change what is stored
+		return &outcome, nil
+	}
+	// An unfinished entry also expires, so a caller that crashed mid-way
+	// does not block the key forever.
+	s.entries[key] = &idempotencyEntry{fingerprint: fingerprint, expires: now.Add(s.ttl)}
+	return nil, nil
+}
+
+// Finish implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Finish(key string, outcome ChargeOutcome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		e.done = true
+		e.outcome = outcome
+		e.expires = s.now().Add(s.ttl)
+	}
+}
+
+// Abandon implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Abandon(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok && !e.done {
+		delete(s.entries, key)
+	}
+}
+
+// prune drops expired keys. The caller must hold s.mu.
+func (s *MemoryIdempotencyStore) prune(now time.Time) {
+	for key, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// fingerprint identifies the PAYLOAD of a request — everything except the
+// idempotency key itself — so that a key reused for a different payment
+// can be told apart from a genuine retry.
+func (r ChargeRequest) fingerprint() string {
+	h := sha256.New()
+	for _, field := range []string{r.Amount.String(), r.Source, r.Description} {
+		h.Write([]byte(field))
+		h.Write([]byte{0}) // separator, so ("ab","c") and ("a","bc") differ
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
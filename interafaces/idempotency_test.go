@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/money"
+)
+
+// countingGateway forwards to another gateway and counts the calls that
+// reach it.
+type countingGateway struct {
+	PaymentGateway
+	calls atomic.Int32
+}
+
+func (g *countingGateway) Charge(ctx context.Context, req ChargeRequest) (ChargeResult, error) {
+	g.calls.Add(1)
+	return g.PaymentGateway.Charge(ctx, req)
+}
+
+func TestMakePaymentIdempotencyReplay(t *testing.T) {
+	ctx := context.Background()
+	base := ChargeRequest{Amount: money.MustParse("60.00 INR"), Source: "tok_visa", IdempotencyKey: "order-1"}
+	with := func(change func(*ChargeRequest)) ChargeRequest {
+		r := base
+		change(&r)
+		return r
+	}
+	noKey := with(func(r *ChargeRequest) { r.IdempotencyKey = "" })
+	declined := with(func(r *ChargeRequest) { r.Source = sourceDeclined })
+	timeout := with(func(r *ChargeRequest) { r.Source = sourceTimeout })
+
+	tests := []struct {
+		name      string
+		first     ChargeRequest
+		retry     ChargeRequest
+		wantCalls int32
+		wantErr   error // from the retry
+		sameID    bool  // the retry returns the first charge
+	}{
+		{"replay returns the first charge", base, base, 1, nil, true},
+		{"different payload is a conflict", base, with(func(r *ChargeRequest) { r.Amount = money.MustParse("65.00 INR") }), 1, ErrIdempotencyConflict, false},
+		{"different key is a new charge", base, with(func(r *ChargeRequest) { r.IdempotencyKey = "order-2" }), 2, nil, false},
+		{"no key means no protection", noKey, noKey, 2, nil, false},
+		{"a decline is remembered", declined, declined, 1, ErrDeclined, false},
+		{"a network failure is not remembered", timeout, timeout, 2, ErrNetwork, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw := &countingGateway{PaymentGateway: newRazorpay()}
+			p := payment{gateway: gw, idempotency: newMemoryIdempotencyStore(time.Hour)}
+
+			first, _ := p.makePayment(ctx, tt.first)
+			retry, err := p.makePayment(ctx, tt.retry)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("retry error = %v, want %v", err, tt.wantErr)
+			}
+			if got := gw.calls.Load(); got != tt.wantCalls {
+				t.Fatalf("gateway called %d times, want %d", got, tt.wantCalls)
+			}
+			if tt.sameID && retry.ID != first.ID {
+				t.Fatalf("retry charged %s, want the first charge %s", retry.ID, first.ID)
+			}
+		})
+	}
+}
+
+func TestMakePaymentConcurrentRetries(t *testing.T) {
+	gw := &countingGateway{PaymentGateway: newRazorpay()}
+	p := payment{gateway: gw, idempotency: newMemoryIdempotencyStore(time.Hour)}
+	req := ChargeRequest{Amount: money.MustParse("10.00 INR"), Source: "tok_visa", IdempotencyKey: "order-1"}
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := p.makePayment(context.Background(), req)
+			if err != nil && !errors.Is(err, ErrRequestInProgress) {
+				t.Errorf("makePayment: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := gw.calls.Load(); got != 1 {
+		t.Fatalf("gateway called %d times for one key, want 1", got)
+	}
+}
+
+func TestMemoryIdempotencyStoreExpiry(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newMemoryIdempotencyStore(time.Hour)
+	s.now = func() time.Time { return now }
+
+	if saved, err := s.Begin("k", "fp"); saved != nil || err != nil {
+		t.Fatalf("first Begin = %v, %v", saved, err)
+	}
+	if _, err := s.Begin("k", "fp"); !errors.Is(err, ErrRequestInProgress) {
+		t.Fatalf("Begin while in progress = %v, want ErrRequestInProgress", err)
+	}
+	s.Finish("k", ChargeOutcome{Result: ChargeResult{ID: "ch_1"}})
+	if saved, err := s.Begin("k", "fp"); err != nil || saved == nil || saved.Result.ID != "ch_1" {
+		t.Fatalf("Begin after Finish = %v, %v; want the saved outcome", saved, err)
+	}
+
+	now = now.Add(time.Hour)
+	if saved, err := s.Begin("k", "other"); saved != nil || err != nil {
+		t.Fatalf("Begin after the TTL = %v, %v; want a fresh reservation", saved, err)
+	}
+	s.Abandon("k")
+	if saved, err := s.Begin("k", "fp"); saved != nil || err != nil {
+		t.Fatalf("Begin after Abandon = %v, %v; want a fresh reservation", saved, err)
+	}
+}
@@ -7,22 +7,150 @@
 //  3. Method receivers      – functions attached to a struct
 //  4. Dependency Injection  – passing a behaviour (interface) into a struct
 //  5. Polymorphism          – one call, different implementations at runtime
+//  6. Errors as values      – typed errors the caller can inspect
+//  7. Interface embedding   – a bigger interface built from a smaller one
+//  8. Type assertions       – asking "does this value ALSO do X?" at runtime
+//
+// This program spans several files (see backend.go), so run the whole
+// directory:  go run ./interafaces
 package main
 
-import "fmt" // fmt provides formatted I/O functions like Println
+import (
+	"context"           // context carries deadlines and cancellation into each call
+	"errors"            // errors.New / errors.Is / errors.As for typed errors
+	"fmt"               // fmt provides formatted I/O functions like Println
+	"net/http"          // status codes for the fake Stripe server, webhook requests
+	"net/http/httptest" // a local server for our webhook endpoint
+	"strings"           // building webhook request bodies
+	"sync/atomic"       // a counter shared with an asynchronous event subscriber
+	"time"              // time stamps each charge result
+
+	"github.com/golang/events" // tells the rest of the program about captured payments (see packages/events)
+	"github.com/golang/ledger" // double-entry bookkeeping (see packages/ledger)
+	"github.com/golang/money"  // exact amounts in minor units (see packages/money)
+	"github.com/golang/rbac"   // who may refund / void (see packages/rbac)
+	"github.com/golang/user"   // the staff member asking for it
+)
 
 // ─────────────────────────────────────────────────────────────────────────────
 // INTERFACE DEFINITION
 // ─────────────────────────────────────────────────────────────────────────────
 
-// paymenter is an INTERFACE.
+// PaymentGateway is an INTERFACE.
 // An interface in Go defines a CONTRACT — a list of method signatures.
 // Any type that implements ALL methods in the interface satisfies it automatically.
 // (No explicit "implements" keyword needed — this is called implicit implementation.)
 //
-// Here, any type that has a  pay(amount float32)  method is a valid paymenter.
-type paymenter interface {
-	pay(amount float32) // any concrete payment gateway MUST implement this method
+// Charge takes a context (so a slow gateway can be cancelled) and a request,
+// and returns BOTH a result and an error. A failed charge is therefore always
+// distinguishable from a successful one:
+//
+//	err == nil → the money moved, result describes the charge
+//	err != nil → nothing was charged, err says why
+type PaymentGateway interface {
+	Charge(ctx context.Context, req ChargeRequest) (ChargeResult, error)
+}
+
+// PaymentProcessor is a BIGGER interface built by EMBEDDING PaymentGateway.
+// A PaymentProcessor can do everything a PaymentGateway can (Charge), plus the
+// two-step flow card networks support:
+//
+//	Authorize → hold the money on the card (nothing is taken yet)
+//	Capture   → take some or all of the held amount (partial capture)
+//	Void      → release the hold instead
+//	Refund    → give back some or all of a captured charge, possibly in parts
+//
+// Every gateway is a PaymentGateway; only some are PaymentProcessors. Code that
+// needs refunds asks for this interface with a TYPE ASSERTION (see payment below).
+type PaymentProcessor interface {
+	PaymentGateway // embedded interface — brings in Charge()
+
+	Authorize(ctx context.Context, req ChargeRequest) (Authorization, error)
+	Capture(ctx context.Context, authorizationID string, amount money.Money) (ChargeResult, error)
+	Void(ctx context.Context, authorizationID string) error
+	Refund(ctx context.Context, chargeID string, amount money.Money) (RefundResult, error)
+	// Refundable reports how much of a charge can still be refunded.
+	Refundable(ctx context.Context, chargeID string) (money.Money, error)
+}
+
+// ChargeRequest is everything a gateway needs to take a payment.
+type ChargeRequest struct {
+	Amount      money.Money // amount AND currency, e.g. money.MustParse("100.00 INR")
+	Source      string      // card / token to charge (see the test tokens below)
+	Description string      // shown on the customer's statement
+
+	// IdempotencyKey makes retries safe: calls with the same key are charged
+	// at most once (see idempotency.go). Empty means "no protection".
+	IdempotencyKey string
+}
+
+// ChargeResult describes a successful charge.
+type ChargeResult struct {
+	ID        string      // gateway's own identifier for the charge
+	Gateway   string      // which gateway handled it ("razorpay", "stripe")
+	Amount    money.Money // amount actually charged
+	CreatedAt time.Time   // when the gateway accepted the charge
+}
+
+// Authorization is a hold placed on the customer's card. Its ID is later
+// passed to Capture or Void.
+type Authorization struct {
+	ID        string
+	Gateway   string
+	Amount    money.Money // amount held — the most that can be captured
+	CreatedAt time.Time
+}
+
+// RefundResult describes one (possibly partial) refund.
+type RefundResult struct {
+	ID        string      // gateway's identifier for the refund itself
+	ChargeID  string      // the charge being refunded
+	Gateway   string      // which gateway handled it
+	Amount    money.Money // amount given back by THIS refund
+	Remaining money.Money // what can still be refunded afterwards
+	CreatedAt time.Time
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// TYPED ERRORS
+// ─────────────────────────────────────────────────────────────────────────────
+
+// Sentinel errors — one per failure KIND. Callers compare with errors.Is, so
+// they never have to parse error strings.
+var (
+	ErrDeclined          = errors.New("payment declined")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrNetwork           = errors.New("gateway unreachable")
+	ErrInvalidAmount     = errors.New("invalid amount")
+	ErrChargeNotFound    = errors.New("charge not found")
+	ErrInvalidState      = errors.New("operation not allowed in the charge's current state")
+	ErrOverCapture       = errors.New("capture exceeds authorized amount")
+	ErrOverRefund        = errors.New("refund exceeds refundable balance")
+	ErrUnsupported       = errors.New("gateway does not support this operation")
+)
+
+// ChargeError wraps one of the sentinel errors above with details from the
+// gateway. Because it has an Unwrap method:
+//
+//	errors.Is(err, ErrDeclined)  → true for a *ChargeError{Err: ErrDeclined}
+//	errors.As(err, &chargeErr)   → gives access to Gateway and Code
+type ChargeError struct {
+	Gateway string // which gateway failed
+	Code    string // gateway-specific reason code, e.g. "card_declined"
+	Err     error  // one of the sentinel errors
+}
+
+func (e *ChargeError) Error() string {
+	return fmt.Sprintf("%s: %v (%s)", e.Gateway, e.Err, e.Code)
+}
+
+func (e *ChargeError) Unwrap() error { return e.Err }
+
+// Retryable reports whether trying the same charge again might succeed.
+// Only network problems and rate limits are worth retrying — a declined
+// card stays declined.
+func (e *ChargeError) Retryable() bool {
+	return errors.Is(e.Err, ErrNetwork) || errors.Is(e.Err, ErrRateLimited)
 }
 
 // ─────────────────────────────────────────────────────────────────────────────
@@ -30,25 +158,145 @@ type paymenter interface {
 // ─────────────────────────────────────────────────────────────────────────────
 
 // payment is a high-level struct that does NOT care WHICH payment gateway is used.
-// It only knows that its gateway field must satisfy the paymenter interface.
+// It only knows that its gateway field must satisfy the PaymentGateway interface.
 //
 // This is DEPENDENCY INJECTION:
-//   → The actual gateway (razorpay / stripe / etc.) is "injected" from outside.
-//   → The payment struct stays decoupled from concrete implementations.
-//   → Swapping gateways requires ZERO changes to this struct.
+//
+//	→ The actual gateway (razorpay / stripe / etc.) is "injected" from outside.
+//	→ The payment struct stays decoupled from concrete implementations.
+//	→ Swapping gateways requires ZERO changes to this struct.
 type payment struct {
-	gateway paymenter // holds any value that satisfies the paymenter interface
+	gateway PaymentGateway // holds any value that satisfies the PaymentGateway interface
+	policy  *rbac.Engine   // decides who may run admin-only operations (refund, void)
+
+	idempotency IdempotencyStore // remembers outcomes by key; nil disables idempotency
+	ledger      *paymentLedger   // books every charge and refund; nil disables bookkeeping
+	events      *events.Bus      // announces captured payments; nil disables events
 }
 
 // makePayment is a METHOD on the payment struct (value receiver).
-// It delegates the actual payment work to whatever gateway was injected.
+// It delegates the actual payment work to whatever gateway was injected,
+// and passes the gateway's result AND error straight back to the caller.
 //
 // POLYMORPHISM in action:
-//   → If gateway is a razorpay{}, it runs razorpay's pay().
-//   → If gateway is a stripe{},   it runs stripe's pay().
-//   → The call p.gateway.pay(amount) looks identical in both cases!
-func (p payment) makePayment(amount float32) {
-	p.gateway.pay(amount) // dynamic dispatch — Go picks the right implementation at runtime
+//
+//	→ If gateway is a razorpay{}, it runs razorpay's Charge().
+//	→ If gateway is a *stripe,    it runs stripe's Charge().
+//	→ The call p.gateway.Charge(ctx, req) looks identical in both cases!
+//
+// With an idempotency store and req.IdempotencyKey set, a repeated call with
+// the same key and the same request returns the first call's outcome, and a
+// call with the same key but a different request fails with
+// ErrIdempotencyConflict. Retryable failures (network trouble) are NOT
+// remembered, so a retry after one gets a fresh attempt.
+func (p payment) makePayment(ctx context.Context, req ChargeRequest) (ChargeResult, error) {
+	if p.idempotency == nil || req.IdempotencyKey == "" {
+		return p.charge(ctx, req)
+	}
+
+	saved, err := p.idempotency.Begin(req.IdempotencyKey, req.fingerprint())
+	if err != nil {
+		return ChargeResult{}, err
+	}
+	if saved != nil {
+		return saved.Result, saved.Err // already done — do NOT charge again
+	}
+
+	result, err := p.charge(ctx, req)
+	var chargeErr *ChargeError
+	if errors.As(err, &chargeErr) && chargeErr.Retryable() {
+		p.idempotency.Abandon(req.IdempotencyKey)
+		return result, err
+	}
+	p.idempotency.Finish(req.IdempotencyKey, ChargeOutcome{Result: result, Err: err})
+	return result, err
+}
+
+// charge runs one real charge through the gateway, books it in the ledger
+// and announces it on the event bus. A charge that went through but could not
+// be booked or announced is still returned — the money HAS moved — together
+// with an error saying so.
+func (p payment) charge(ctx context.Context, req ChargeRequest) (ChargeResult, error) {
+	result, err := p.gateway.Charge(ctx, req) // dynamic dispatch — Go picks the right implementation at runtime
+	if err != nil {
+		return result, err
+	}
+	return result, p.captured(ctx, result, req.Description)
+}
+
+// capturePayment takes amount of an authorization hold — the second half of
+// Authorize/Capture — and, like charge, books and announces the money taken.
+func (p payment) capturePayment(ctx context.Context, authorizationID string, amount money.Money, description string) (ChargeResult, error) {
+	proc, err := p.processor()
+	if err != nil {
+		return ChargeResult{}, err
+	}
+	result, err := proc.Capture(ctx, authorizationID, amount)
+	if err != nil {
+		return result, err
+	}
+	return result, p.captured(ctx, result, description)
+}
+
+// captured books a charge or capture that went through in the ledger and
+// publishes PaymentCaptured for it.
+func (p payment) captured(ctx context.Context, result ChargeResult, description string) error {
+	if p.ledger != nil {
+		if err := p.ledger.recordCharge(result, description); err != nil {
+			return fmt.Errorf("charge %s succeeded but was not booked: %w", result.ID, err)
+		}
+	}
+	captured := events.PaymentCaptured{ChargeID: result.ID, Gateway: result.Gateway, Amount: result.Amount, Description: description}
+	if err := p.events.Publish(ctx, captured); err != nil { // a nil bus publishes nothing
+		return fmt.Errorf("charge %s succeeded but was not announced: %w", result.ID, err)
+	}
+	return nil
+}
+
+// processor uses a TYPE ASSERTION to check whether the injected gateway is
+// ALSO a PaymentProcessor:
+//
+//	proc, ok := p.gateway.(PaymentProcessor)
+//	  ok == true  → proc is the same value, now usable through the bigger interface
+//	  ok == false → the gateway can only Charge
+func (p payment) processor() (PaymentProcessor, error) {
+	proc, ok := p.gateway.(PaymentProcessor)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return proc, nil
+}
+
+// refundPayment gives back amount of a captured charge. Refunds are
+// ADMIN-ONLY: the actor's roles must grant "refund:payments" in the policy.
+func (p payment) refundPayment(ctx context.Context, actor user.User, chargeID string, amount money.Money) (RefundResult, error) {
+	if err := p.policy.Require(actor, "refund", "payments"); err != nil {
+		return RefundResult{}, err
+	}
+	proc, err := p.processor()
+	if err != nil {
+		return RefundResult{}, err
+	}
+	refund, err := proc.Refund(ctx, chargeID, amount)
+	if err != nil || p.ledger == nil {
+		return refund, err
+	}
+	if err := p.ledger.recordRefund(refund); err != nil {
+		return refund, fmt.Errorf("refund %s succeeded but was not booked: %w", refund.ID, err)
+	}
+	return refund, nil
+}
+
+// voidPayment releases an authorization hold. Also admin-only ("void:payments").
+func (p payment) voidPayment(ctx context.Context, actor user.User, authorizationID string) error {
+	if err := p.policy.Require(actor, "void", "payments"); err != nil {
+		return err
+	}
+	proc, err := p.processor()
+	if err != nil {
+		return err
+	}
+	return proc.Void(ctx, authorizationID)
 }
 
 // ─────────────────────────────────────────────────────────────────────────────
@@ -56,59 +304,266 @@ func (p payment) makePayment(amount float32) {
 // ─────────────────────────────────────────────────────────────────────────────
 
 // razorpay is a concrete (real) struct representing the Razorpay payment gateway.
-// It has no fields — it's used only to attach methods.
-type razorpay struct{}
+// Its only field points at the (fake) Razorpay servers that remember each charge.
+//
+// stripe — the other gateway — is a real HTTP client; it lives in stripe.go.
+type razorpay struct {
+	backend *fakeBackend
+}
 
-// stripe is a concrete struct representing the Stripe payment gateway.
-type stripe struct{}
+// newRazorpay is a constructor function. Each razorpay value gets its own
+// backend, so charge IDs from one mean nothing to another.
+func newRazorpay() razorpay { return razorpay{backend: newFakeBackend("razorpay", "pay_")} }
 
-// pay implements the paymenter interface for razorpay.
+// Test sources understood by the simulated gateways, in the spirit of the
+// test card tokens real gateways publish. Any other source succeeds.
+const (
+	sourceDeclined          = "tok_declined"
+	sourceInsufficientFunds = "tok_insufficient_funds"
+	sourceTimeout           = "tok_timeout"
+)
+
+// Charge implements the PaymentGateway interface for razorpay.
 // The method receiver (r razorpay) means: "this function belongs to the razorpay type".
-// Because razorpay now has a pay() method, it AUTOMATICALLY satisfies paymenter.
-func (r razorpay) pay(amount float32) {
-	fmt.Println("making payment using razorpay", amount) // simulates a Razorpay API call
+// Because razorpay now has a Charge() method, it AUTOMATICALLY satisfies PaymentGateway.
+// A one-step charge is simply "authorize, then capture everything".
+func (r razorpay) Charge(ctx context.Context, req ChargeRequest) (ChargeResult, error) {
+	auth, err := r.Authorize(ctx, req)
+	if err != nil {
+		return ChargeResult{}, err
+	}
+	return r.Capture(ctx, auth.ID, auth.Amount)
+}
+
+// The remaining razorpay methods make it a full PaymentProcessor. Each one
+// simply forwards to the backend, which simulates a Razorpay API call.
+func (r razorpay) Authorize(ctx context.Context, req ChargeRequest) (Authorization, error) {
+	return r.backend.authorize(ctx, req)
 }
 
-// pay implements the paymenter interface for stripe.
-// Similarly, stripe also satisfies paymenter without any explicit declaration.
-func (s stripe) pay(amount float32) {
-	fmt.Println("making payment using stripe", amount) // simulates a Stripe API call
+func (r razorpay) Capture(ctx context.Context, id string, amount money.Money) (ChargeResult, error) {
+	return r.backend.capture(ctx, id, amount)
 }
 
+func (r razorpay) Void(ctx context.Context, id string) error {
+	return r.backend.void(ctx, id)
+}
+
+func (r razorpay) Refund(ctx context.Context, id string, amount money.Money) (RefundResult, error) {
+	return r.backend.refund(ctx, id, amount)
+}
+
+func (r razorpay) Refundable(ctx context.Context, id string) (money.Money, error) {
+	return r.backend.refundable(id)
+}
+
+// Compile-time checks: the build fails right here if either type stops
+// satisfying the interface (a common Go idiom).
+var (
+	_ PaymentProcessor = razorpay{}
+	_ PaymentProcessor = (*stripe)(nil)
+)
+
 // ─────────────────────────────────────────────────────────────────────────────
 // MAIN — program entry point
 // ─────────────────────────────────────────────────────────────────────────────
 
 func main() {
+	ctx := context.Background() // root context — no deadline
+
 	// STEP 1: Choose a concrete gateway.
-	// We pick stripe here, but we could easily swap to razorpay{} with one change.
-	stripePaymentGw := stripe{} // creates a stripe value (satisfies paymenter)
+	// We pick stripe here, but we could easily swap to newRazorpay() with one change.
+	// stripe is an HTTP client; here it talks to a local fake of the Stripe
+	// API (fakestripe.go) instead of the internet.
+	stripeAPI := newFakeStripeServer("sk_test_demo")
+	defer stripeAPI.Close()
+	stripePaymentGw := newStripe(stripeAPI.URL, "sk_test_demo", stripeOptions{BaseBackoff: 10 * time.Millisecond}) // *stripe satisfies PaymentGateway
+
+	// Every charge and refund is booked in a double-entry ledger (packages/ledger).
+	book := ledger.New(ledger.Options{})
 
 	// STEP 2: Inject the gateway into the high-level payment struct.
-	// The payment struct only sees the paymenter interface — it doesn't know or
+	// The payment struct only sees the PaymentGateway interface — it doesn't know or
 	// care that it's actually a stripe under the hood.
 	newPayment := payment{
 		gateway: stripePaymentGw, // dependency injection via struct literal
+		policy:  paymentPolicy(), // who may refund / void
+
+		idempotency: newMemoryIdempotencyStore(24 * time.Hour),                                // safe retries
+		ledger:      newPaymentLedger(book, map[string]int64{"stripe": 290, "razorpay": 200}), // fees: 2.9% / 2%
+	}
+
+	// STEP 3: Trigger the payment and CHECK THE ERROR.
+	// Internally calls → stripe.Charge(...); the gateways themselves never print.
+	result, err := newPayment.makePayment(ctx, ChargeRequest{Amount: money.MustParse("100.00 INR"), Source: "tok_visa"})
+	if err != nil {
+		fmt.Println("payment failed:", err)
+		return
+	}
+	fmt.Println("charged:", result.Amount, "via", result.Gateway, result.ID) // charged: 100.00 INR via stripe pi_000001
+
+	// STEP 4: Failures come back as typed errors.
+	_, err = newPayment.makePayment(ctx, ChargeRequest{Amount: money.MustParse("250.00 INR"), Source: sourceInsufficientFunds})
+	switch {
+	case errors.Is(err, ErrInsufficientFunds):
+		fmt.Println("ask the customer for another card:", err)
+	case errors.Is(err, ErrDeclined):
+		fmt.Println("card declined:", err)
+	}
+
+	var chargeErr *ChargeError
+	_, err = payment{gateway: newRazorpay()}.makePayment(ctx, ChargeRequest{Amount: money.MustParse("250.00 INR"), Source: sourceTimeout})
+	if errors.As(err, &chargeErr) && chargeErr.Retryable() {
+		fmt.Println("temporary problem, safe to retry:", err)
+	}
+
+	// STEP 5: Retrying with an idempotency key never charges twice.
+	order := ChargeRequest{Amount: money.MustParse("60.00 INR"), Source: "tok_visa", IdempotencyKey: "order-1001"}
+	first, _ := newPayment.makePayment(ctx, order)
+	retry, _ := newPayment.makePayment(ctx, order) // e.g. after the first response was lost
+	fmt.Println("same charge:", first.ID == retry.ID, first.ID)
+
+	order.Amount = money.MustParse("65.00 INR") // same key, different payment
+	_, err = newPayment.makePayment(ctx, order)
+	fmt.Println(err) // idempotency key reused with a different request
+
+	// STEP 6: The HTTP client retries rate limits and outages with backoff...
+	stripeAPI.failNext(http.StatusTooManyRequests, http.StatusServiceUnavailable)
+	before := stripeAPI.requestCount()
+	if res, err := newPayment.makePayment(ctx, ChargeRequest{Amount: money.MustParse("15.00 INR"), Source: "tok_visa"}); err == nil {
+		fmt.Println("charged", res.ID, "after", stripeAPI.requestCount()-before, "HTTP requests") // 2 failed + create + confirm
+	}
+	// ...but not a bad API key.
+	_, err = newStripe(stripeAPI.URL, "sk_test_wrong", stripeOptions{}).Charge(ctx, ChargeRequest{Amount: money.MustParse("15.00 INR"), Source: "tok_visa"})
+	fmt.Println(errors.Is(err, ErrUnauthorized), err)
+
+	// STEP 7: Refunds are admin-only and can be partial.
+	cashier := user.User{Email: "cashier@example.com", Roles: []user.Role{"staff"}}
+	admin := user.User{Email: "admin@example.com", Roles: []user.Role{"admin"}}
+
+	_, err = newPayment.refundPayment(ctx, cashier, result.ID, money.MustParse("40.00 INR"))
+	fmt.Println(err) // rbac: forbidden: cashier@example.com may not refund payments
+
+	refund, err := newPayment.refundPayment(ctx, admin, result.ID, money.MustParse("40.00 INR"))
+	if err == nil {
+		fmt.Println("refunded", refund.Amount, "— still refundable:", refund.Remaining) // 40.00 INR — 60.00 INR
+	}
+	_, err = newPayment.refundPayment(ctx, admin, result.ID, money.MustParse("75.00 INR"))
+	if errors.Is(err, ErrOverRefund) {
+		fmt.Println("rejected:", err) // requested 75.00 INR, refundable 60.00 INR
 	}
 
-	// STEP 3: Trigger the payment.
-	// Internally calls → stripe.pay(100) → prints "making payment using stripe 100"
-	newPayment.makePayment(100)
+	// STEP 8: Authorize now, capture part later — or void the hold.
+	hold, _ := stripePaymentGw.Authorize(ctx, ChargeRequest{Amount: money.MustParse("500.00 INR"), Source: "tok_visa"})
+	captured, _ := newPayment.capturePayment(ctx, hold.ID, money.MustParse("320.00 INR"), "order-1002") // only 3 of 5 items shipped; booked like a charge
+	fmt.Println("captured", captured.Amount, "of", hold.Amount)
 
-	// ── Try swapping to Razorpay (just change stripePaymentGw to razorpay{}) ──
-	// razorpayPaymentGw := razorpay{}
-	// newPayment2 := payment{gateway: razorpayPaymentGw}
-	// newPayment2.makePayment(250)  // prints "making payment using razorpay 250"
+	hold2, _ := stripePaymentGw.Authorize(ctx, ChargeRequest{Amount: money.MustParse("80.00 INR"), Source: "tok_visa"})
+	if err := newPayment.voidPayment(ctx, admin, hold2.ID); err == nil {
+		_, err = newPayment.capturePayment(ctx, hold2.ID, hold2.Amount, "order-1003")
+		fmt.Println("capture after void:", err) // operation not allowed in the charge's current state
+	}
+
+	// STEP 9: The gateway tells us about later changes through signed webhooks.
+	secret := []byte("whsec_demo")
+	orders := newOrderBook()
+	receiver := newWebhookReceiver(secret, webhookOptions{OnRejected: func(e WebhookEvent, err error) {
+		fmt.Println("webhook rejected:", err)
+	}})
+	registerOrderHandlers(receiver, orders)
+	hooks := httptest.NewServer(receiver) // our webhook endpoint, on localhost
+	defer hooks.Close()
+
+	send := func(body string, at time.Time, sign []byte) int {
+		req, _ := http.NewRequest(http.MethodPost, hooks.URL, strings.NewReader(body))
+		req.Header.Set(WebhookSignatureHeader, signWebhook(sign, at, []byte(body)))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	orders.place("order-1001")
+	refunded := `{"id":"evt_1","type":"payment.refunded","data":{"charge_id":"` + result.ID + `","order_id":"order-1001","amount":"40.00 INR"}}`
+	succeeded := `{"id":"evt_0","type":"payment.succeeded","data":{"charge_id":"` + result.ID + `","order_id":"order-1001","amount":"100.00 INR"}}`
+	fmt.Println(send(refunded, time.Now(), secret), orders.status("order-1001"))  // 500 pending — arrived early; the gateway will resend it
+	fmt.Println(send(succeeded, time.Now(), secret), orders.status("order-1001")) // 200 paid
+	fmt.Println(send(refunded, time.Now(), secret), orders.status("order-1001"))  // 200 refunded — the redelivery, freshly signed
+	failedLate := `{"id":"evt_3","type":"payment.failed","data":{"charge_id":"` + result.ID + `","order_id":"order-1001","amount":"100.00 INR"}}`
+	fmt.Println(send(failedLate, time.Now(), secret), orders.status("order-1001")) // 422 refunded — can never apply, so it is not resent
+	event := refunded
+	fmt.Println(send(event, time.Now(), secret))                                                       // 200 — duplicate, ignored
+	fmt.Println(send(event, time.Now(), []byte("guess")))                                              // 401 — bad signature
+	fmt.Println(send(strings.Replace(event, "evt_1", "evt_2", 1), time.Now().Add(-time.Hour), secret)) // 401 — replayed/stale
+	// STEP 10: A router is itself a PaymentGateway, spreading charges over both.
+	router := newRoutingGateway(routerOptions{FailureThreshold: 2, Cooldown: time.Minute},
+		providerConfig{Name: "stripe", Gateway: stripePaymentGw, Weight: 100},
+		providerConfig{Name: "razorpay", Gateway: newRazorpay(), Currencies: []string{"INR"}}, // weight 0: fallback only
+	)
+	routed := payment{gateway: router}
+	for range 2 { // stripe is rate limiting us: its retries run out, the router fails over to razorpay
+		stripeAPI.failNext(429, 429, 429, 429) // the first try plus stripe's 3 retries
+		res, err := routed.makePayment(ctx, ChargeRequest{Amount: money.MustParse("99.00 INR"), Source: "tok_visa"})
+		fmt.Println("routed to", res.Gateway, err)
+	}
+	stats := router.Stats()
+	fmt.Printf("stripe: %+v\nrazorpay: %+v\n", stats["stripe"], stats["razorpay"])
+	_, err = routed.makePayment(ctx, ChargeRequest{Amount: money.MustParse("5.00 USD"), Source: "tok_visa"})
+	fmt.Println(err) // only stripe takes USD, and its breaker is open
+
+	// STEP 11: The ledger holds the trail of everything newPayment did — and it balances.
+	fmt.Println("ledger:")
+	printBalances(book)
+	fmt.Println("ledger balanced:", book.Check() == nil)
+
+	// STEP 12: Other parts of the program hear about every captured payment
+	// through an event bus (packages/events) — without payment knowing them.
+	bus := events.New(events.Options{
+		Outbox: events.NewMemoryOutbox(), // failed deliveries wait here for Redeliver
+		OnError: func(sub string, env events.Envelope, err error) {
+			fmt.Println("event", env.Event.EventType(), "→", sub+":", err)
+		},
+	})
+	bus.Subscribe("receipts", events.On(func(ctx context.Context, e events.PaymentCaptured, _ events.Envelope) error {
+		fmt.Println("receipt: email the customer about", e.ChargeID, e.Amount)
+		return nil
+	}))
+	var analytics atomic.Int64 // counted on the async subscriber's goroutine
+	bus.SubscribeAsync("analytics", events.On(func(ctx context.Context, e events.PaymentCaptured, _ events.Envelope) error {
+		analytics.Add(1)
+		return nil
+	}))
+	bus.Subscribe("crm", func(ctx context.Context, env events.Envelope) error {
+		return errors.New("crm is down") // fails on its own; the others still run
+	})
+	announced := payment{gateway: newRazorpay(), events: bus}
+	announced.makePayment(ctx, ChargeRequest{Amount: money.MustParse("120.00 INR"), Source: "tok_visa"})
+	bus.Close()                                                      // waits for the analytics goroutine to finish
+	fmt.Println("analytics counted", analytics.Load(), "payment(s)") // analytics counted 1 payment(s)
+}
+
+// paymentPolicy is the access policy for payment operations: staff may take
+// payments, only admins may refund or void them.
+func paymentPolicy() *rbac.Engine {
+	policy, err := rbac.NewEngine(rbac.Policy{Roles: map[user.Role]rbac.RoleDefinition{
+		"staff": {Permissions: []user.Permission{"charge:payments"}},
+		"admin": {Inherits: []user.Role{"staff"}, Permissions: []user.Permission{"*:payments"}},
+	}})
+	if err != nil {
+		panic(err) // the policy above is a constant — an error here is a programming bug
+	}
+	return policy
 }
 
 // ─────────────────────────────────────────────────────────────────────────────
 // SUMMARY — Why interfaces matter
 // ─────────────────────────────────────────────────────────────────────────────
 //
-//  ┌──────────────┐        implements        ┌─────────────────────┐
-//  │  paymenter   │ ◄────────────────────── │  razorpay / stripe  │
-//  │  (interface) │                          │  (concrete structs) │
-//  └──────┬───────┘                          └─────────────────────┘
+//  ┌──────────────────┐    implements    ┌─────────────────────┐
+//  │ PaymentProcessor │ ◄─────────────── │  razorpay / stripe  │
+//  │ ⊃ PaymentGateway │                  │  (concrete structs) │
+//  └──────┬───────────┘                  └─────────────────────┘
 //         │ used by
 //  ┌──────▼───────┐
 //  │   payment    │  ← only depends on the interface, not the concrete type
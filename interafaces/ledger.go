@@ -0,0 +1,133 @@
+package main
+
+// This file writes every payment into a double-entry ledger
+// (packages/ledger), so there is an auditable trail of where each rupee
+// went. The accounts, one per currency:
+//
+//	assets:<gateway>:<CUR>   money the gateway holds for us (to be paid out)
+//	revenue:sales:<CUR>      what customers paid
+//	expenses:refunds:<CUR>   what was given back
+//	expenses:fees:<CUR>      what the gateways kept as their fee
+//
+// A ₹100 charge through razorpay with a 2% fee becomes ONE balanced entry:
+//
+//	assets:razorpay:INR    +100.00   (debit)
+//	revenue:sales:INR      -100.00   (credit)
+//	expenses:fees:INR        +2.00   (debit)
+//	assets:razorpay:INR      -2.00   (credit)
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/golang/ledger"
+)
+
+// paymentLedger records gateway results in a ledger.Ledger.
+type paymentLedger struct {
+	book    *ledger.Ledger
+	feeBips map[string]int64 // gateway name → fee in basis points (1/100 of a percent)
+
+	mu sync.Mutex // serializes account creation
+}
+
+// newPaymentLedger returns a recorder writing to book. feeBips gives each
+// gateway's fee; gateways missing from it are recorded without a fee.
+func newPaymentLedger(book *ledger.Ledger, feeBips map[string]int64) *paymentLedger {
+	return &paymentLedger{book: book, feeBips: feeBips}
+}
+
+// recordCharge books a successful charge and the gateway's fee for it.
+func (pl *paymentLedger) recordCharge(res ChargeResult, description string) error {
+	cur := res.Amount.Currency()
+	gateway, err := pl.account("assets:"+res.Gateway, ledger.Asset, cur)
+	if err != nil {
+		return err
+	}
+	sales, err := pl.account("revenue:sales", ledger.Revenue, cur)
+	if err != nil {
+		return err
+	}
+	postings := []ledger.Posting{
+		{Account: gateway, Amount: res.Amount},
+		{Account: sales, Amount: res.Amount.Neg()},
+	}
+
+	fee, err := res.Amount.MulRat(pl.feeBips[res.Gateway], 10_000)
+	if err != nil {
+		return err
+	}
+	if fee.IsPositive() {
+		fees, err := pl.account("expenses:fees", ledger.Expense, cur)
+		if err != nil {
+			return err
+		}
+		postings = append(postings,
+			ledger.Posting{Account: fees, Amount: fee},
+			ledger.Posting{Account: gateway, Amount: fee.Neg()},
+		)
+	}
+
+	_, err = pl.book.Post(ledger.Entry{
+		Time:        res.CreatedAt,
+		Description: strings.TrimSpace("charge " + description),
+		Reference:   res.ID,
+		Postings:    postings,
+	})
+	return err
+}
+
+// recordRefund books a refund. Gateways keep their fee on refunds, so only
+// the refunded amount moves back.
+func (pl *paymentLedger) recordRefund(res RefundResult) error {
+	cur := res.Amount.Currency()
+	gateway, err := pl.account("assets:"+res.Gateway, ledger.Asset, cur)
+	if err != nil {
+		return err
+	}
+	refunds, err := pl.account("expenses:refunds", ledger.Expense, cur)
+	if err != nil {
+		return err
+	}
+	_, err = pl.book.Post(ledger.Entry{
+		Time:        res.CreatedAt,
+		Description: "refund of " + res.ChargeID,
+		Reference:   res.ID,
+		Postings: []ledger.Posting{
+			{Account: refunds, Amount: res.Amount},
+			{Account: gateway, Amount: res.Amount.Neg()},
+		},
+	})
+	return err
+}
+
+// account returns the code of prefix's account in currency, opening it on
+// first use.
+func (pl *paymentLedger) account(prefix string, typ ledger.AccountType, currency string) (string, error) {
+	code := prefix + ":" + currency
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	err := pl.book.Open(ledger.Account{Code: code, Name: prefix, Type: typ, Currency: currency})
+	if err != nil && !errors.Is(err, ledger.ErrDuplicateAccount) {
+		return "", err
+	}
+	return code, nil
+}
+
+// printBalances shows every account's balance the way accountants read
+// them: positive in the account's normal direction.
+func printBalances(book *ledger.Ledger) {
+	for _, a := range book.Accounts() {
+		balance, err := book.Balance(a.Code)
+		if err != nil {
+			fmt.Println(a.Code, err)
+			continue
+		}
+		if !a.Type.DebitNormal() {
+			balance = balance.Neg()
+		}
+		fmt.Printf("  %-22s %12s\n", a.Code, balance)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/events"
+	"github.com/golang/ledger"
+	"github.com/golang/money"
+)
+
+func TestPaymentLedgerBooksEachChargeOnce(t *testing.T) {
+	ctx := context.Background()
+	book := ledger.New(ledger.Options{})
+	p := payment{
+		gateway:     newRazorpay(),
+		idempotency: newMemoryIdempotencyStore(time.Hour),
+		ledger:      newPaymentLedger(book, map[string]int64{"razorpay": 200}), // 2%
+	}
+
+	req := ChargeRequest{Amount: money.MustParse("100.00 INR"), Source: "tok_visa", IdempotencyKey: "order-1"}
+	for range 3 { // the first call and two replays
+		if _, err := p.makePayment(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := p.makePayment(ctx, ChargeRequest{Amount: money.MustParse("10.00 INR"), Source: sourceDeclined}); err == nil {
+		t.Fatal("declined charge succeeded")
+	}
+
+	if got := len(book.Entries("")); got != 1 {
+		t.Fatalf("%d journal entries, want 1 (replays and declines book nothing)", got)
+	}
+	tests := []struct {
+		account string
+		want    string
+	}{
+		{"assets:razorpay:INR", "98.00 INR"},
+		{"revenue:sales:INR", "-100.00 INR"},
+		{"expenses:fees:INR", "2.00 INR"},
+	}
+	for _, tt := range tests {
+		got, err := book.Balance(tt.account)
+		if err != nil || !got.Equal(money.MustParse(tt.want)) {
+			t.Errorf("Balance(%s) = %s, %v; want %s", tt.account, got, err, tt.want)
+		}
+	}
+	if err := book.Check(); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+}
+
+func TestCapturedAuthorizationIsBookedAndAnnounced(t *testing.T) {
+	ctx := context.Background()
+	book := ledger.New(ledger.Options{})
+	bus := events.New(events.Options{})
+	var announced []events.PaymentCaptured
+	bus.Subscribe("test", events.On(func(ctx context.Context, e events.PaymentCaptured, _ events.Envelope) error {
+		announced = append(announced, e)
+		return nil
+	}))
+	gateway := newRazorpay()
+	p := payment{gateway: gateway, ledger: newPaymentLedger(book, nil), events: bus}
+
+	hold, err := gateway.Authorize(ctx, ChargeRequest{Amount: money.MustParse("500.00 INR"), Source: "tok_visa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(book.Entries("")); got != 0 {
+		t.Fatalf("%d journal entries after Authorize, want 0 (a hold moves no money)", got)
+	}
+	if _, err := p.capturePayment(ctx, hold.ID, money.MustParse("600.00 INR"), "order-1"); !errors.Is(err, ErrOverCapture) {
+		t.Fatalf("over-capture err = %v, want ErrOverCapture", err)
+	}
+	res, err := p.capturePayment(ctx, hold.ID, money.MustParse("320.00 INR"), "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := book.Balance("revenue:sales:INR"); err != nil || !got.Equal(money.MustParse("-320.00 INR")) {
+		t.Errorf("revenue = %s, %v; want -320.00 INR (only the captured part)", got, err)
+	}
+	if len(announced) != 1 || announced[0].ChargeID != res.ID || !announced[0].Amount.Equal(res.Amount) || announced[0].Description != "order-1" {
+		t.Fatalf("announced %+v, want one PaymentCaptured for %s", announced, res.ID)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+// This file is a ROUTING gateway: one PaymentGateway that spreads charges
+// over several real ones. Because it satisfies PaymentGateway itself, it is
+// injected into payment exactly like razorpay or stripe — payment never
+// knows there is more than one gateway behind it.
+//
+// For every charge the router:
+//
+//  1. keeps the providers that accept it (currency list, amount limits),
+//  2. puts one first — picked by weight, e.g. 70% razorpay / 30% stripe —
+//     and the rest after it in the order they were configured,
+//  3. skips providers whose CIRCUIT BREAKER is open,
+//  4. tries them in turn, moving on only after an error that proves the
+//     charge never reached the gateway (a rate limit). A timeout or a 5xx
+//     may come AFTER the card was charged, so failing over could charge the
+//     customer twice; those errors, like a declined card (declined
+//     everywhere), are returned as is.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	mrand "math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/golang/money"
+)
+
+// ErrNoGateway is returned when no provider can take a charge: none accepts
+// it, every breaker is open, or every provider refused it before submission.
+var ErrNoGateway = errors.New("no payment gateway available")
+
+// providerConfig describes one gateway behind the router. Zero fields mean
+// "no restriction".
+type providerConfig struct {
+	Name       string
+	Gateway    PaymentGateway
+	Currencies []string    // accepted currencies, e.g. {"INR"}; empty = all
+	MinAmount  money.Money // smallest accepted charge (only checked in its own currency)
+	MaxAmount  money.Money // largest accepted charge (only checked in its own currency)
+	Weight     int         // share of traffic among the accepting providers
+}
+
+// routerOptions tunes the circuit breakers. Zero fields take their defaults.
+type routerOptions struct {
+	FailureThreshold int              // consecutive failures that open a breaker; defaults to 3
+	Cooldown         time.Duration    // how long an open breaker skips its provider; defaults to 30s
+	Now              func() time.Time // defaults to time.Now
+	Rand             func(n int) int  // returns a number in [0, n); defaults to math/rand/v2.IntN
+}
+
+// breakerState is the classic three-state circuit breaker:
+//
+//	closed ──(threshold failures)──► open ──(cooldown over)──► half-open
+//	   ▲                                                          │
+//	   └───────────(trial charge succeeds)────────────────────────┤
+//	                       open ◄──(trial charge fails)───────────┘
+type breakerState int
+
+const (
+	breakerClosed   breakerState = iota // healthy — send traffic
+	breakerOpen                         // failing — skip until the cooldown is over
+	breakerHalfOpen                     // cooldown over — let ONE trial charge through
+)
+
+func (s breakerState) String() string {
+	return [...]string{"closed", "open", "half-open"}[s]
+}
+
+// providerStats are the counters exposed per provider.
+type providerStats struct {
+	Successes int          // charges that went through
+	Failures  int          // retryable failures (the ones that count against the breaker)
+	Declines  int          // non-retryable errors: the gateway worked, the payment did not
+	Skipped   int          // times the breaker made the router pass this provider by
+	State     breakerState // breaker state right now
+}
+
+type provider struct {
+	cfg providerConfig
+
+	// guarded by routingGateway.mu
+	stats       providerStats
+	consecutive int       // retryable failures in a row
+	openedAt    time.Time // when the breaker last opened
+	trial       bool      // a half-open trial charge is in flight (see allow)
+}
+
+// routingGateway implements PaymentGateway on top of several providers. It
+// is safe for concurrent use.
+type routingGateway struct {
+	opts routerOptions
+
+	mu        sync.Mutex
+	providers []*provider
+}
+
+// newRoutingGateway returns a router over providers, tried in this order
+// after the weighted pick.
+func newRoutingGateway(opts routerOptions, providers ...providerConfig) *routingGateway {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = 3
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = 30 * time.Second
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.Rand == nil {
+		opts.Rand = mrand.IntN
+	}
+	r := &routingGateway{opts: opts}
+	for _, cfg := range providers {
+		r.providers = append(r.providers, &provider{cfg: cfg})
+	}
+	return r
+}
+
+// Charge implements PaymentGateway.
+func (r *routingGateway) Charge(ctx context.Context, req ChargeRequest) (ChargeResult, error) {
+	candidates := r.route(req)
+	if len(candidates) == 0 {
+		return ChargeResult{}, fmt.Errorf("%w: none accepts %s", ErrNoGateway, req.Amount)
+	}
+
+	var lastErr error
+	for _, p := range candidates {
+		trial, ok := r.allow(p)
+		if !ok {
+			continue
+		}
+		result, err := p.cfg.Gateway.Charge(ctx, req)
+		if err != nil && ctx.Err() != nil {
+			// The caller gave up, which says nothing about the provider's
+			// health; do not count it, and do not start another attempt.
+			r.abandon(p, trial)
+			return result, err
+		}
+		r.record(p, trial, err)
+
+		if err == nil || !notSubmitted(err) {
+			return result, err // success, a decline, or a failure that may have charged the card
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		return ChargeResult{}, fmt.Errorf("%w: every circuit breaker is open", ErrNoGateway)
+	}
+	return ChargeResult{}, fmt.Errorf("%w: %w", ErrNoGateway, lastErr)
+}
+
+// Stats returns a snapshot of every provider's counters, by name.
+func (r *routingGateway) Stats() map[string]providerStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make(map[string]providerStats, len(r.providers))
+	for _, p := range r.providers {
+		s := p.stats
+		s.State = r.state(p)
+		out[p.cfg.Name] = s
+	}
+	return out
+}
+
+// route returns the providers that accept req, the weighted pick first.
+func (r *routingGateway) route(req ChargeRequest) []*provider {
+	var accepting []*provider
+	total := 0
+	for _, p := range r.providers {
+		if p.accepts(req.Amount) {
+			accepting = append(accepting, p)
+			total += max(p.cfg.Weight, 0)
+		}
+	}
+	if total == 0 {
+		return accepting // no weights: configuration order
+	}
+
+	n := r.opts.Rand(total)
+	for i, p := range accepting {
+		if n -= max(p.cfg.Weight, 0); n < 0 {
+			// Move the pick to the front, keep the others in order.
+			return append([]*provider{p}, slices.Delete(slices.Clone(accepting), i, i+1)...)
+		}
+	}
+	return accepting
+}
+
+// accepts reports whether the provider's currency and amount rules allow amount.
+func (p *provider) accepts(amount money.Money) bool {
+	if len(p.cfg.Currencies) > 0 && !slices.Contains(p.cfg.Currencies, amount.Currency()) {
+		return false
+	}
+	if cmp, err := amount.Compare(p.cfg.MinAmount); err == nil && cmp < 0 {
+		return false
+	}
+	if cmp, err := amount.Compare(p.cfg.MaxAmount); err == nil && cmp > 0 && !p.cfg.MaxAmount.IsZero() {
+		return false
+	}
+	return true
+}
+
+// notSubmitted reports whether err proves the provider never took the
+// charge, so the next provider can be tried without charging twice. A rate
+// limit is refused before any work is done; a network error or 5xx may have
+// come after the charge went through.
+func notSubmitted(err error) bool {
+	return errors.Is(err, ErrRateLimited)
+}
+
+// allow asks p's breaker whether a charge may be sent now. trial is true if
+// this call is the half-open trial charge; the caller passes it back to
+// record or abandon, so only the call that took the trial gives it back.
+func (r *routingGateway) allow(p *provider) (trial, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch r.state(p) {
+	case breakerOpen:
+		p.stats.Skipped++
+		return false, false
+	case breakerHalfOpen:
+		if p.trial { // someone else is already testing the provider
+			p.stats.Skipped++
+			return false, false
+		}
+		p.trial = true
+		return true, true
+	}
+	return false, true
+}
+
+// abandon gives back the trial of a call that ended without an outcome
+// worth counting, such as one cancelled by the caller.
+func (r *routingGateway) abandon(p *provider, trial bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if trial {
+		p.trial = false
+	}
+}
+
+// record feeds the outcome of a charge into p's counters and breaker.
+func (r *routingGateway) record(p *provider, trial bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if trial {
+		p.trial = false
+	}
+
+	var chargeErr *ChargeError
+	switch {
+	case err == nil:
+		p.stats.Successes++
+		p.consecutive = 0
+		p.openedAt = time.Time{} // close the breaker
+	case errors.As(err, &chargeErr) && chargeErr.Retryable():
+		p.stats.Failures++
+		p.consecutive++
+		if trial || p.consecutive >= r.opts.FailureThreshold {
+			p.openedAt = r.opts.Now() // (re)open the breaker
+		}
+	default:
+		p.stats.Declines++
+		p.consecutive = 0 // the gateway answered, so it is healthy
+		p.openedAt = time.Time{}
+	}
+}
+
+// state works out p's breaker state from its fields. The caller must hold r.mu.
+func (r *routingGateway) state(p *provider) breakerState {
+	if p.openedAt.IsZero() {
+		return breakerClosed
+	}
+	if r.opts.Now().Sub(p.openedAt) < r.opts.Cooldown {
+		return breakerOpen
+	}
+	return breakerHalfOpen
+}
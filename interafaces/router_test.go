@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/money"
+)
+
+// scriptedGateway answers each Charge with the next error of its script
+// (nil = success), then succeeds.
+type scriptedGateway struct {
+	name   string
+	script []error
+	calls  int
+}
+
+func (g *scriptedGateway) Charge(ctx context.Context, req ChargeRequest) (ChargeResult, error) {
+	g.calls++
+	if len(g.script) > 0 {
+		err := g.script[0]
+		g.script = g.script[1:]
+		if err != nil {
+			return ChargeResult{}, err
+		}
+	}
+	return ChargeResult{ID: g.name + "_1", Gateway: g.name, Amount: req.Amount}, nil
+}
+
+func chargeErr(err error) error { return &ChargeError{Gateway: "test", Code: "test", Err: err} }
+
+func TestRouterFailover(t *testing.T) {
+	tests := []struct {
+		name        string
+		firstErr    error
+		wantErr     error
+		wantGateway string // "" when the charge fails
+		wantSecond  int    // calls reaching the second provider
+	}{
+		{"success stays on the first", nil, nil, "first", 0},
+		{"rate limit fails over", chargeErr(ErrRateLimited), nil, "second", 1},
+		{"network error may have charged: no failover", chargeErr(ErrNetwork), ErrNetwork, "", 0},
+		{"decline is final", chargeErr(ErrDeclined), ErrDeclined, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := &scriptedGateway{name: "first", script: []error{tt.firstErr}}
+			second := &scriptedGateway{name: "second"}
+			r := newRoutingGateway(routerOptions{},
+				providerConfig{Name: "first", Gateway: first},
+				providerConfig{Name: "second", Gateway: second},
+			)
+			res, err := r.Charge(context.Background(), ChargeRequest{Amount: money.MustParse("10.00 INR")})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Charge = %v, want %v", err, tt.wantErr)
+			}
+			if res.Gateway != tt.wantGateway || second.calls != tt.wantSecond {
+				t.Fatalf("charged by %q with %d calls to second, want %q and %d", res.Gateway, second.calls, tt.wantGateway, tt.wantSecond)
+			}
+		})
+	}
+}
+
+func TestRouterRoutesByCurrencyAndWeight(t *testing.T) {
+	inr := &scriptedGateway{name: "inr"}
+	anyCur := &scriptedGateway{name: "any"}
+	pick := 0
+	r := newRoutingGateway(routerOptions{Rand: func(n int) int { return pick }},
+		providerConfig{Name: "inr", Gateway: inr, Currencies: []string{"INR"}, Weight: 70},
+		providerConfig{Name: "any", Gateway: anyCur, Weight: 30},
+	)
+	tests := []struct {
+		amount string
+		pick   int
+		want   string
+	}{
+		{"10.00 INR", 0, "inr"},
+		{"10.00 INR", 69, "inr"},
+		{"10.00 INR", 70, "any"},
+		{"10.00 USD", 0, "any"},
+	}
+	for _, tt := range tests {
+		pick = tt.pick
+		res, err := r.Charge(context.Background(), ChargeRequest{Amount: money.MustParse(tt.amount)})
+		if err != nil || res.Gateway != tt.want {
+			t.Errorf("%s with pick %d → %q, %v; want %q", tt.amount, tt.pick, res.Gateway, err, tt.want)
+		}
+	}
+	if _, err := r.Charge(context.Background(), ChargeRequest{Amount: money.MustParse("10 JPY")}); err != nil {
+		t.Errorf("JPY: %v", err) // "any" has no currency list
+	}
+}
+
+func TestRouterBreaker(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	gw := &scriptedGateway{name: "only", script: []error{chargeErr(ErrNetwork), chargeErr(ErrNetwork), chargeErr(ErrNetwork)}}
+	r := newRoutingGateway(routerOptions{FailureThreshold: 2, Cooldown: time.Minute, Now: func() time.Time { return now }},
+		providerConfig{Name: "only", Gateway: gw},
+	)
+	charge := func() error {
+		_, err := r.Charge(context.Background(), ChargeRequest{Amount: money.MustParse("1.00 INR")})
+		return err
+	}
+
+	charge()
+	charge()
+	if got := r.Stats()["only"].State; got != breakerOpen {
+		t.Fatalf("after 2 failures the breaker is %s, want open", got)
+	}
+	if err := charge(); !errors.Is(err, ErrNoGateway) || gw.calls != 2 {
+		t.Fatalf("charge with the breaker open = %v after %d calls, want ErrNoGateway without a call", err, gw.calls)
+	}
+
+	now = now.Add(time.Minute)
+	if got := r.Stats()["only"].State; got != breakerHalfOpen {
+		t.Fatalf("after the cooldown the breaker is %s, want half-open", got)
+	}
+	charge() // the trial fails: open again
+	if got := r.Stats()["only"].State; got != breakerOpen {
+		t.Fatalf("after a failed trial the breaker is %s, want open", got)
+	}
+	now = now.Add(time.Minute)
+	if err := charge(); err != nil { // the trial succeeds: closed
+		t.Fatalf("trial charge: %v", err)
+	}
+	if got := r.Stats()["only"].State; got != breakerClosed {
+		t.Fatalf("after a good trial the breaker is %s, want closed", got)
+	}
+}
+
+func TestRouterTrialBelongsToItsCall(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	r := newRoutingGateway(routerOptions{FailureThreshold: 1, Cooldown: time.Minute, Now: func() time.Time { return now }},
+		providerConfig{Name: "only", Gateway: &scriptedGateway{}},
+	)
+	p := r.providers[0]
+	network := chargeErr(ErrNetwork)
+
+	aIsTrial, ok := r.allow(p) // call A starts while the breaker is closed
+	if !ok || aIsTrial {
+		t.Fatalf("closed breaker: allow = %v, %v; want an ordinary call", aIsTrial, ok)
+	}
+	r.record(p, false, network) // another call fails: open
+	now = now.Add(time.Minute)
+	bIsTrial, ok := r.allow(p) // call B is the half-open trial
+	if !ok || !bIsTrial {
+		t.Fatalf("half-open breaker: allow = %v, %v; want the trial", bIsTrial, ok)
+	}
+
+	r.record(p, aIsTrial, network) // A finishes while B is still in flight
+	now = now.Add(time.Minute)
+	if _, ok := r.allow(p); ok {
+		t.Fatal("a second trial was let through while the first is still in flight")
+	}
+	r.record(p, bIsTrial, nil) // B succeeds
+	if got := r.Stats()["only"].State; got != breakerClosed {
+		t.Fatalf("after B succeeded the breaker is %s, want closed", got)
+	}
+}
+
+func TestRouterIgnoresCancelledCalls(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	gw := &scriptedGateway{name: "only", script: []error{chargeErr(ErrNetwork)}}
+	second := &scriptedGateway{name: "second"}
+	r := newRoutingGateway(routerOptions{FailureThreshold: 1},
+		providerConfig{Name: "only", Gateway: gw},
+		providerConfig{Name: "second", Gateway: second},
+	)
+	cancel() // the gateway fails because the caller gave up
+	if _, err := r.Charge(ctx, ChargeRequest{Amount: money.MustParse("1.00 INR")}); !errors.Is(err, ErrNetwork) {
+		t.Fatalf("Charge = %v, want the gateway's ErrNetwork", err)
+	}
+	stats := r.Stats()["only"]
+	if stats.Failures != 0 || stats.State != breakerClosed {
+		t.Fatalf("stats after a cancelled call = %+v, want no failure and a closed breaker", stats)
+	}
+	if second.calls != 0 {
+		t.Fatal("a cancelled charge was retried on the next provider")
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+// This file is a REAL HTTP client for a Stripe-style payments API.
+//
+// Stripe's REST API takes form-encoded requests and answers with JSON:
+//
+//	POST /v1/payment_intents               create a PaymentIntent (amount, currency, payment_method)
+//	POST /v1/payment_intents/{id}/confirm  try to take (or hold) the money
+//	POST /v1/payment_intents/{id}/capture  take a held amount, possibly only part of it
+//	POST /v1/payment_intents/{id}/cancel   release a hold
+//	POST /v1/refunds                       give money back
+//	GET  /v1/payment_intents/{id}          look a PaymentIntent up
+//
+// The demo points the client at the local fake server in fakestripe.go, so
+// nothing ever touches the network.
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/money"
+)
+
+// More sentinel errors, for failures only an HTTP API has.
+var (
+	ErrUnauthorized   = errors.New("gateway rejected the API key")
+	ErrRateLimited    = errors.New("too many requests")
+	ErrInvalidRequest = errors.New("gateway rejected the request")
+)
+
+// stripeOptions tunes the client. Zero fields take their defaults.
+type stripeOptions struct {
+	HTTPClient  *http.Client  // defaults to a client with a 10s timeout
+	MaxRetries  int           // extra attempts after a 429/503; defaults to 3
+	BaseBackoff time.Duration // wait before the first retry; defaults to 250ms
+	MaxBackoff  time.Duration // upper bound for any single wait; defaults to 5s
+}
+
+// stripe is the Stripe payment gateway, reached over HTTP.
+type stripe struct {
+	baseURL string // e.g. "https://api.stripe.com"
+	apiKey  string // secret key, sent as a Bearer token
+	opts    stripeOptions
+}
+
+// newStripe returns a client for the API at baseURL.
+func newStripe(baseURL, apiKey string, opts stripeOptions) *stripe {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = 3
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = 250 * time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 5 * time.Second
+	}
+	return &stripe{baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey, opts: opts}
+}
+
+// paymentIntent is the part of Stripe's PaymentIntent object we use.
+type paymentIntent struct {
+	ID             string `json:"id"`
+	Amount         int64  `json:"amount"`
+	AmountReceived int64  `json:"amount_received"`
+	AmountRefunded int64  `json:"amount_refunded"`
+	Currency       string `json:"currency"` // lower case, e.g. "inr"
+	Status         string `json:"status"`   // requires_confirmation, requires_capture, succeeded, canceled, ...
+	Created        int64  `json:"created"`  // Unix seconds
+}
+
+// stripeRefund is the part of Stripe's Refund object we use.
+type stripeRefund struct {
+	ID            string        `json:"id"`
+	Amount        int64         `json:"amount"`
+	Currency      string        `json:"currency"`
+	PaymentIntent paymentIntent `json:"payment_intent"` // expanded via expand[]=payment_intent
+	Created       int64         `json:"created"`
+}
+
+// stripeAPIError is the body of every non-2xx response.
+type stripeAPIError struct {
+	Error struct {
+		Type        string `json:"type"`                   // card_error, invalid_request_error, api_error, ...
+		Code        string `json:"code,omitempty"`         // e.g. card_declined, resource_missing
+		DeclineCode string `json:"decline_code,omitempty"` // e.g. insufficient_funds
+		Message     string `json:"message"`
+	} `json:"error"`
+}
+
+// Charge implements PaymentGateway: create a PaymentIntent that captures
+// automatically, then confirm it.
+func (s *stripe) Charge(ctx context.Context, req ChargeRequest) (ChargeResult, error) {
+	pi, err := s.createAndConfirm(ctx, req, "automatic")
+	if err != nil {
+		return ChargeResult{}, err
+	}
+	amount, err := s.amount(pi.AmountReceived, pi.Currency)
+	if err != nil {
+		return ChargeResult{}, err
+	}
+	return ChargeResult{ID: pi.ID, Gateway: "stripe", Amount: amount, CreatedAt: time.Unix(pi.Created, 0)}, nil
+}
+
+// Authorize places a hold: a PaymentIntent with capture_method=manual.
+func (s *stripe) Authorize(ctx context.Context, req ChargeRequest) (Authorization, error) {
+	pi, err := s.createAndConfirm(ctx, req, "manual")
+	if err != nil {
+		return Authorization{}, err
+	}
+	amount, err := s.amount(pi.Amount, pi.Currency)
+	if err != nil {
+		return Authorization{}, err
+	}
+	return Authorization{ID: pi.ID, Gateway: "stripe", Amount: amount, CreatedAt: time.Unix(pi.Created, 0)}, nil
+}
+
+// Capture takes amount from a held PaymentIntent.
+func (s *stripe) Capture(ctx context.Context, id string, amount money.Money) (ChargeResult, error) {
+	var pi paymentIntent
+	form := url.Values{"amount_to_capture": {strconv.FormatInt(amount.Minor(), 10)}}
+	if err := s.do(ctx, "capture", http.MethodPost, "/v1/payment_intents/"+url.PathEscape(id)+"/capture", form, "", &pi); err != nil {
+		return ChargeResult{}, err
+	}
+	captured, err := s.amount(pi.AmountReceived, pi.Currency)
+	if err != nil {
+		return ChargeResult{}, err
+	}
+	return ChargeResult{ID: pi.ID, Gateway: "stripe", Amount: captured, CreatedAt: time.Unix(pi.Created, 0)}, nil
+}
+
+// Void cancels a held PaymentIntent.
+func (s *stripe) Void(ctx context.Context, id string) error {
+	return s.do(ctx, "cancel", http.MethodPost, "/v1/payment_intents/"+url.PathEscape(id)+"/cancel", url.Values{}, "", nil)
+}
+
+// Refund gives back amount of a captured PaymentIntent.
+func (s *stripe) Refund(ctx context.Context, id string, amount money.Money) (RefundResult, error) {
+	var r stripeRefund
+	form := url.Values{
+		"payment_intent": {id},
+		"amount":         {strconv.FormatInt(amount.Minor(), 10)},
+		"expand[]":       {"payment_intent"}, // so the reply says what is left to refund
+	}
+	if err := s.do(ctx, "refund", http.MethodPost, "/v1/refunds", form, "", &r); err != nil {
+		return RefundResult{}, err
+	}
+	refunded, err := s.amount(r.Amount, r.Currency)
+	if err != nil {
+		return RefundResult{}, err
+	}
+	remaining, err := s.amount(r.PaymentIntent.AmountReceived-r.PaymentIntent.AmountRefunded, r.Currency)
+	if err != nil {
+		return RefundResult{}, err
+	}
+	return RefundResult{
+		ID:        r.ID,
+		ChargeID:  id,
+		Gateway:   "stripe",
+		Amount:    refunded,
+		Remaining: remaining,
+		CreatedAt: time.Unix(r.Created, 0),
+	}, nil
+}
+
+// Refundable looks the PaymentIntent up and reports what is left to refund.
+func (s *stripe) Refundable(ctx context.Context, id string) (money.Money, error) {
+	var pi paymentIntent
+	if err := s.do(ctx, "retrieve", http.MethodGet, "/v1/payment_intents/"+url.PathEscape(id), nil, "", &pi); err != nil {
+		return money.Money{}, err
+	}
+	return s.amount(pi.AmountReceived-pi.AmountRefunded, pi.Currency)
+}
+
+// createAndConfirm runs the two calls every payment starts with. Both carry
+// an idempotency key derived from the caller's (or a fresh random one), so
+// the retries in do can never create a second PaymentIntent or confirm one
+// twice.
+func (s *stripe) createAndConfirm(ctx context.Context, req ChargeRequest, captureMethod string) (paymentIntent, error) {
+	if !req.Amount.IsPositive() {
+		return paymentIntent{}, &ChargeError{Gateway: "stripe", Code: "amount_too_small", Err: ErrInvalidAmount}
+	}
+	key := req.IdempotencyKey
+	if key == "" {
+		key = randomKey()
+	}
+
+	var pi paymentIntent
+	form := url.Values{
+		"amount":         {strconv.FormatInt(req.Amount.Minor(), 10)},
+		"currency":       {strings.ToLower(req.Amount.Currency())},
+		"payment_method": {req.Source},
+		"capture_method": {captureMethod},
+	}
+	if req.Description != "" {
+		form.Set("description", req.Description)
+	}
+	if err := s.do(ctx, "create", http.MethodPost, "/v1/payment_intents", form, key+"/create", &pi); err != nil {
+		return paymentIntent{}, err
+	}
+	err := s.do(ctx, "confirm", http.MethodPost, "/v1/payment_intents/"+url.PathEscape(pi.ID)+"/confirm", url.Values{}, key+"/confirm", &pi)
+	return pi, err
+}
+
+// do sends one API request, retrying with exponential backoff while the
+// server answers 429 or 503, and decodes a 2xx body into out (if non-nil).
+// op names the operation for error mapping ("capture", "refund", ...).
+//
+// Every attempt of a POST carries the same Idempotency-Key; a POST without
+// one gets a fresh random key here. Otherwise a 503 sent after the server
+// did the work would make the retry capture or refund a second time.
+func (s *stripe) do(ctx context.Context, op, method, path string, form url.Values, idempotencyKey string, out any) error {
+	if method == http.MethodPost && idempotencyKey == "" {
+		idempotencyKey = randomKey()
+	}
+	for attempt := 0; ; attempt++ {
+		status, body, header, err := s.send(ctx, method, path, form, idempotencyKey)
+		if err != nil {
+			return &ChargeError{Gateway: "stripe", Code: "connection_error", Err: fmt.Errorf("%w: %v", ErrNetwork, err)}
+		}
+		if status/100 == 2 {
+			if out == nil {
+				return nil
+			}
+			if err := json.Unmarshal(body, out); err != nil {
+				return &ChargeError{Gateway: "stripe", Code: "invalid_response", Err: fmt.Errorf("%w: %v", ErrInvalidRequest, err)}
+			}
+			return nil
+		}
+
+		apiErr := s.apiError(op, status, body)
+		if (status != http.StatusTooManyRequests && status != http.StatusServiceUnavailable) || attempt >= s.opts.MaxRetries {
+			return apiErr
+		}
+		if err := sleepContext(ctx, s.backoff(attempt, header.Get("Retry-After"))); err != nil {
+			return apiErr // gave up waiting; report the last answer we got
+		}
+	}
+}
+
+// send performs a single HTTP round trip.
+func (s *stripe) send(ctx context.Context, method, path string, form url.Values, idempotencyKey string) (int, []byte, http.Header, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, body)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := s.opts.HTTPClient.Do(req)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return resp.StatusCode, data, resp.Header, nil
+}
+
+// apiError turns a non-2xx response into a *ChargeError wrapping the
+// matching sentinel, so callers handle Stripe exactly like razorpay.
+func (s *stripe) apiError(op string, status int, body []byte) error {
+	var e stripeAPIError
+	_ = json.Unmarshal(body, &e) // a garbled body still has a status code
+	code := e.Error.Code
+	if code == "" {
+		code = strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+	}
+
+	var sentinel error
+	switch {
+	case status == http.StatusUnauthorized:
+		sentinel = ErrUnauthorized
+	case status == http.StatusTooManyRequests:
+		sentinel = ErrRateLimited
+	case status >= 500:
+		sentinel = ErrNetwork
+	case e.Error.DeclineCode == "insufficient_funds":
+		sentinel = ErrInsufficientFunds
+	case code == "card_declined", status == http.StatusPaymentRequired:
+		sentinel = ErrDeclined
+	case code == "resource_missing", status == http.StatusNotFound:
+		sentinel = ErrChargeNotFound
+	case code == "payment_intent_unexpected_state", code == "charge_already_refunded", code == "charge_not_captured":
+		sentinel = ErrInvalidState
+	case code == "amount_too_large" && op == "capture":
+		sentinel = ErrOverCapture
+	case code == "amount_too_large" && op == "refund":
+		sentinel = ErrOverRefund
+	case code == "amount_too_small", code == "parameter_invalid_integer":
+		sentinel = ErrInvalidAmount
+	default:
+		sentinel = ErrInvalidRequest
+	}
+	if e.Error.Message != "" {
+		sentinel = fmt.Errorf("%w: %s", sentinel, e.Error.Message)
+	}
+	return &ChargeError{Gateway: "stripe", Code: code, Err: sentinel}
+}
+
+// backoff returns how long to wait before retry number attempt+1: the
+// server's Retry-After if it sent one, otherwise BaseBackoff doubled per
+// attempt with "full jitter" (a random wait up to that value) so that many
+// clients do not retry in lockstep. Both are capped at MaxBackoff before
+// any arithmetic that could overflow.
+func (s *stripe) backoff(attempt int, retryAfter string) time.Duration {
+	if secs, err := strconv.Atoi(retryAfter); err == nil && secs >= 0 {
+		if time.Duration(secs) > s.opts.MaxBackoff/time.Second {
+			return s.opts.MaxBackoff
+		}
+		return min(time.Duration(secs)*time.Second, s.opts.MaxBackoff)
+	}
+	d := s.opts.MaxBackoff
+	if s.opts.BaseBackoff <= s.opts.MaxBackoff>>attempt { // BaseBackoff<<attempt ≤ MaxBackoff, without shifting past int64
+		d = s.opts.BaseBackoff << attempt
+	}
+	return time.Duration(mrand.Int64N(int64(d)) + 1)
+}
+
+func (s *stripe) amount(minor int64, currency string) (money.Money, error) {
+	m, err := money.New(minor, strings.ToUpper(currency))
+	if err != nil {
+		return money.Money{}, &ChargeError{Gateway: "stripe", Code: "invalid_response", Err: fmt.Errorf("%w: %v", ErrInvalidRequest, err)}
+	}
+	return m, nil
+}
+
+// sleepContext waits for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// randomKey returns a fresh idempotency key.
+func randomKey() string {
+	b := make([]byte, 16)
+	rand.Read(b) // never fails (see crypto/rand.Read)
+	return hex.EncodeToString(b)
+}
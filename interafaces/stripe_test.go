@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/money"
+)
+
+// lossyTransport records the Idempotency-Key of every request and can
+// replace the next responses with a 503 AFTER the server handled them, as a
+// load balancer that timed out would.
+type lossyTransport struct {
+	mu   sync.Mutex
+	keys []string
+	lose int
+}
+
+func (t *lossyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.keys = append(t.keys, req.Header.Get("Idempotency-Key"))
+	if err != nil || t.lose == 0 {
+		return resp, err
+	}
+	t.lose--
+	resp.Body.Close()
+	return &http.Response{
+		StatusCode: http.StatusServiceUnavailable,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(`{"error":{"type":"api_error"}}`)),
+		Request:    req,
+	}, nil
+}
+
+func newTestStripe(t *testing.T) (*fakeStripeServer, *stripe, *lossyTransport) {
+	t.Helper()
+	server := newFakeStripeServer("sk_test")
+	t.Cleanup(server.Close)
+	transport := &lossyTransport{}
+	client := newStripe(server.URL, "sk_test", stripeOptions{
+		HTTPClient:  &http.Client{Transport: transport},
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  5 * time.Millisecond,
+	})
+	return server, client, transport
+}
+
+func TestStripeChargeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		apiKey  string
+		source  string
+		amount  string
+		wantErr error
+	}{
+		{"success", "sk_test", "tok_visa", "100.00 INR", nil},
+		{"declined", "sk_test", sourceDeclined, "100.00 INR", ErrDeclined},
+		{"insufficient funds", "sk_test", sourceInsufficientFunds, "100.00 INR", ErrInsufficientFunds},
+		{"network trouble", "sk_test", sourceTimeout, "100.00 INR", ErrNetwork},
+		{"zero amount", "sk_test", "tok_visa", "0 INR", ErrInvalidAmount},
+		{"bad api key", "sk_wrong", "tok_visa", "100.00 INR", ErrUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeStripeServer("sk_test")
+			defer server.Close()
+			client := newStripe(server.URL, tt.apiKey, stripeOptions{BaseBackoff: time.Millisecond})
+
+			res, err := client.Charge(context.Background(), ChargeRequest{Amount: money.MustParse(tt.amount), Source: tt.source})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Charge = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !res.Amount.Equal(money.MustParse(tt.amount)) {
+				t.Fatalf("charged %s, want %s", res.Amount, tt.amount)
+			}
+		})
+	}
+}
+
+func TestStripeRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		faults    []int
+		wantErr   error
+		wantCalls int
+	}{
+		{"rate limit then outage", []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}, nil, 4}, // 2 faults + create + confirm
+		{"retries run out", []int{429, 429, 429, 429}, ErrRateLimited, 4},
+		{"other errors are not retried", []int{http.StatusInternalServerError}, ErrNetwork, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client, _ := newTestStripe(t)
+			server.failNext(tt.faults...)
+			_, err := client.Charge(context.Background(), ChargeRequest{Amount: money.MustParse("15.00 INR"), Source: "tok_visa"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Charge = %v, want %v", err, tt.wantErr)
+			}
+			if got := server.requestCount(); got != tt.wantCalls {
+				t.Fatalf("%d requests, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestStripeRetriedPostsReuseTheIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	_, client, transport := newTestStripe(t)
+	hold, err := client.Authorize(ctx, ChargeRequest{Amount: money.MustParse("100.00 INR"), Source: "tok_visa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each call is handled by the server, answered with a 503, retried
+	// and then answered from the saved reply instead of running twice.
+	transport.lose = 1
+	transport.keys = nil
+	captured, err := client.Capture(ctx, hold.ID, money.MustParse("80.00 INR"))
+	if err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+	if !captured.Amount.Equal(money.MustParse("80.00 INR")) {
+		t.Fatalf("captured %s, want 80.00 INR", captured.Amount)
+	}
+	if len(transport.keys) != 2 || transport.keys[0] == "" || transport.keys[0] != transport.keys[1] {
+		t.Fatalf("capture attempts sent keys %q, want one key on both", transport.keys)
+	}
+
+	transport.lose = 1
+	refund, err := client.Refund(ctx, hold.ID, money.MustParse("30.00 INR"))
+	if err != nil {
+		t.Fatalf("Refund: %v", err)
+	}
+	if !refund.Remaining.Equal(money.MustParse("50.00 INR")) {
+		t.Fatalf("refundable after one refund = %s, want 50.00 INR (refunded twice?)", refund.Remaining)
+	}
+	left, err := client.Refundable(ctx, hold.ID)
+	if err != nil || !left.Equal(money.MustParse("50.00 INR")) {
+		t.Fatalf("Refundable = %s, %v; want 50.00 INR", left, err)
+	}
+
+	// Separate calls get separate keys, so a second refund is not a replay.
+	if _, err := client.Refund(ctx, hold.ID, money.MustParse("30.00 INR")); err != nil {
+		t.Fatalf("second Refund: %v", err)
+	}
+	if left, _ := client.Refundable(ctx, hold.ID); !left.Equal(money.MustParse("20.00 INR")) {
+		t.Fatalf("Refundable after two refunds = %s, want 20.00 INR", left)
+	}
+}
+
+func TestStripeStateErrors(t *testing.T) {
+	ctx := context.Background()
+	_, client, _ := newTestStripe(t)
+	hold, err := client.Authorize(ctx, ChargeRequest{Amount: money.MustParse("100.00 INR"), Source: "tok_visa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Refund(ctx, hold.ID, money.MustParse("10.00 INR")); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("Refund of an uncaptured hold = %v, want ErrInvalidState", err)
+	}
+	if _, err := client.Capture(ctx, hold.ID, money.MustParse("150.00 INR")); !errors.Is(err, ErrOverCapture) {
+		t.Fatalf("Capture above the hold = %v, want ErrOverCapture", err)
+	}
+	if err := client.Void(ctx, hold.ID); err != nil {
+		t.Fatalf("Void: %v", err)
+	}
+	if _, err := client.Capture(ctx, hold.ID, money.MustParse("10.00 INR")); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("Capture after Void = %v, want ErrInvalidState", err)
+	}
+	if _, err := client.Capture(ctx, "pi_missing", money.MustParse("10.00 INR")); !errors.Is(err, ErrChargeNotFound) {
+		t.Fatalf("Capture of an unknown intent = %v, want ErrChargeNotFound", err)
+	}
+}
+
+func TestStripeBackoffIsCapped(t *testing.T) {
+	s := newStripe("http://example.invalid", "sk", stripeOptions{BaseBackoff: time.Second, MaxBackoff: 5 * time.Second})
+	tests := []struct {
+		attempt    int
+		retryAfter string
+		max        time.Duration
+	}{
+		{0, "", time.Second},
+		{2, "", 4 * time.Second},
+		{3, "", 5 * time.Second},
+		{62, "", 5 * time.Second},
+		{100, "", 5 * time.Second}, // BaseBackoff<<100 would overflow
+		{0, "2", 2 * time.Second},
+		{0, "9999999999999", 5 * time.Second}, // seconds × time.Second would overflow
+	}
+	for _, tt := range tests {
+		d := s.backoff(tt.attempt, tt.retryAfter)
+		if d <= 0 || d > tt.max {
+			t.Errorf("backoff(%d, %q) = %s, want in (0, %s]", tt.attempt, tt.retryAfter, d, tt.max)
+		}
+	}
+}
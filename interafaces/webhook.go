@@ -0,0 +1,420 @@
+package main
+
+// This file receives WEBHOOKS: HTTP requests a payment gateway sends to us
+// when something happens to a payment after makePayment returned — the
+// charge settled, failed, was refunded, or the customer disputed it.
+//
+// Anyone on the internet can POST to a webhook URL, so every request is
+// checked before it is believed:
+//
+//  1. Signature — the gateway signs "<timestamp>.<body>" with HMAC-SHA256
+//     using a secret only it and we know, and sends
+//     "Payment-Signature: t=<unix seconds>,v1=<hex digest>".
+//  2. Timestamp — a signed request captured by an attacker stays valid
+//     forever, so requests older than the tolerance are refused (replays).
+//  3. Event ID — gateways deliver "at least once", so the same event may
+//     arrive twice; we handle each ID only once. A redelivery is signed
+//     afresh with a new timestamp, so IDs are remembered for days (the
+//     retention), far longer than the timestamp tolerance.
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/money"
+)
+
+// WebhookSignatureHeader carries the signature of a webhook request.
+const WebhookSignatureHeader = "Payment-Signature"
+
+// DefaultWebhookTolerance is how old a webhook may be when no tolerance is given.
+const DefaultWebhookTolerance = 5 * time.Minute
+
+// DefaultWebhookRetention is how long handled event IDs are remembered when
+// no retention is given. Gateways keep redelivering a failed event for up to
+// three days, so a week leaves room to spare.
+const DefaultWebhookRetention = 7 * 24 * time.Hour
+
+// Errors returned by webhookReceiver.Verify.
+var (
+	ErrBadSignature = errors.New("webhook signature is missing or invalid")
+	ErrStaleEvent   = errors.New("webhook timestamp outside the tolerance")
+)
+
+// EventType says what happened. It is a TYPED string, so a plain string
+// cannot be passed by mistake where an EventType is expected.
+type EventType string
+
+const (
+	EventPaymentSucceeded EventType = "payment.succeeded"
+	EventPaymentFailed    EventType = "payment.failed"
+	EventPaymentRefunded  EventType = "payment.refunded"
+	EventPaymentDisputed  EventType = "payment.disputed"
+)
+
+// WebhookEvent is the JSON body of a webhook.
+type WebhookEvent struct {
+	ID      string    `json:"id"`      // unique per event — used to drop duplicates
+	Type    EventType `json:"type"`    // what happened
+	Created int64     `json:"created"` // Unix seconds
+	Data    struct {
+		ChargeID string      `json:"charge_id"`
+		OrderID  string      `json:"order_id"` // the merchant's reference, sent along with the charge
+		Amount   money.Money `json:"amount"`   // "12.34 INR" (see money.MarshalText)
+		Reason   string      `json:"reason,omitempty"`
+	} `json:"data"`
+}
+
+// WebhookHandlerFunc handles one verified, de-duplicated event. Returning an
+// error makes the receiver answer 500, so the gateway delivers it again —
+// unless the error wraps ErrEventRejected.
+type WebhookHandlerFunc func(ctx context.Context, event WebhookEvent) error
+
+// ErrEventRejected marks a handler error that no redelivery can fix: the
+// event names an order we do not have, or a move the order can never make.
+// The receiver reports it to OnRejected and answers 422, so the gateway
+// stops sending it instead of retrying for days.
+var ErrEventRejected = errors.New("webhook event cannot be applied")
+
+// webhookOptions configures a webhookReceiver. Zero fields take their defaults.
+type webhookOptions struct {
+	Tolerance time.Duration    // max age (and clock skew) of a request; defaults to DefaultWebhookTolerance
+	Retention time.Duration    // how long handled event IDs are remembered; defaults to DefaultWebhookRetention
+	Now       func() time.Time // defaults to time.Now
+
+	// OnRejected is told about every event answered 422 (see
+	// ErrEventRejected). It defaults to log.Printf.
+	OnRejected func(event WebhookEvent, err error)
+}
+
+// webhookReceiver is an http.Handler for webhook requests. It is safe for
+// concurrent use, as every http.Handler must be.
+type webhookReceiver struct {
+	secret []byte
+	opts   webhookOptions
+
+	mu       sync.Mutex
+	handlers map[EventType][]WebhookHandlerFunc
+	seen     map[string]time.Time // event ID → when it was handled (or claimed)
+	pruned   time.Time            // when seen was last swept for expired IDs
+}
+
+// newWebhookReceiver returns a receiver that checks signatures with secret.
+func newWebhookReceiver(secret []byte, opts webhookOptions) *webhookReceiver {
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = DefaultWebhookTolerance
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultWebhookRetention
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.OnRejected == nil {
+		opts.OnRejected = func(event WebhookEvent, err error) {
+			log.Printf("webhook %s rejected: %v", event.ID, err)
+		}
+	}
+	return &webhookReceiver{
+		secret:   secret,
+		opts:     opts,
+		handlers: make(map[EventType][]WebhookHandlerFunc),
+		seen:     make(map[string]time.Time),
+	}
+}
+
+// On registers h for events of type t. Handlers run in registration order.
+func (wr *webhookReceiver) On(t EventType, h WebhookHandlerFunc) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	wr.handlers[t] = append(wr.handlers[t], h)
+}
+
+// ServeHTTP implements http.Handler. The status codes tell the gateway what
+// to do next: 2xx = delivered, 4xx = never send this again, 5xx = retry later.
+func (wr *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := wr.Verify(r.Header.Get(WebhookSignatureHeader), body); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil || event.ID == "" {
+		http.Error(w, "malformed event", http.StatusBadRequest)
+		return
+	}
+	if !wr.claim(event.ID) {
+		fmt.Fprintln(w, "duplicate event ignored")
+		return
+	}
+	if err := wr.dispatch(r.Context(), event); err != nil {
+		if errors.Is(err, ErrEventRejected) {
+			wr.opts.OnRejected(event, err) // stays claimed: a resend changes nothing
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		wr.release(event.ID) // let the gateway's retry through
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// Verify checks a signature header against body: a valid v1 signature and a
+// timestamp within the tolerance of now. Several v1 entries may be present
+// while the gateway rotates secrets; one match is enough.
+func (wr *webhookReceiver) Verify(header string, body []byte) error {
+	var ts string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	secs, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrBadSignature
+	}
+
+	want := webhookMAC(wr.secret, ts, body)
+	valid := false
+	for _, sig := range sigs {
+		got, err := hex.DecodeString(sig)
+		if err == nil && hmac.Equal(got, want) { // constant time: no timing leaks
+			valid = true
+		}
+	}
+	if !valid {
+		return ErrBadSignature
+	}
+
+	// Checked only AFTER the signature, so an attacker learns nothing from it.
+	age := wr.opts.Now().Sub(time.Unix(secs, 0))
+	if age > wr.opts.Tolerance || age < -wr.opts.Tolerance {
+		return fmt.Errorf("%w: signed %s ago", ErrStaleEvent, age.Round(time.Second))
+	}
+	return nil
+}
+
+// claim marks id as handled, reporting false if it already was. The
+// timestamp check does not help here — the gateway signs every redelivery
+// anew — so IDs are kept for the whole retention. The map is swept at most
+// once per tolerance, so a busy endpoint does not walk it on every request.
+// (Several servers behind one URL would keep the IDs in a shared store.)
+func (wr *webhookReceiver) claim(id string) bool {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	now := wr.opts.Now()
+	if now.Sub(wr.pruned) >= wr.opts.Tolerance {
+		for seenID, at := range wr.seen {
+			if now.Sub(at) > wr.opts.Retention {
+				delete(wr.seen, seenID)
+			}
+		}
+		wr.pruned = now
+	}
+	if at, ok := wr.seen[id]; ok && now.Sub(at) <= wr.opts.Retention {
+		return false
+	}
+	wr.seen[id] = now
+	return true
+}
+
+func (wr *webhookReceiver) release(id string) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	delete(wr.seen, id)
+}
+
+// dispatch runs the handlers registered for event.Type. Events nobody
+// handles are acknowledged and dropped — gateways add new types over time.
+func (wr *webhookReceiver) dispatch(ctx context.Context, event WebhookEvent) error {
+	wr.mu.Lock()
+	handlers := wr.handlers[event.Type] // copy the slice header under the lock...
+	wr.mu.Unlock()                      // ...but never run handlers while holding it
+
+	for _, h := range handlers {
+		if err := h(ctx, event); err != nil {
+			return fmt.Errorf("handling %s %s: %w", event.Type, event.ID, err)
+		}
+	}
+	return nil
+}
+
+// signWebhook builds the signature header for body — what the GATEWAY does
+// before sending. The demo uses it to play the gateway's part.
+func signWebhook(secret []byte, at time.Time, body []byte) string {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(webhookMAC(secret, ts, body))
+}
+
+func webhookMAC(secret []byte, ts string, body []byte) []byte {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(ts))
+	m.Write([]byte("."))
+	m.Write(body)
+	return m.Sum(nil)
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Orders updated by the webhooks
+// ─────────────────────────────────────────────────────────────────────────────
+
+// paymentStatus is where an order's payment is. Webhooks may only move it
+// along these edges (the full order lifecycle lives in the structs demo):
+//
+//	pending        ──► paid, payment_failed
+//	payment_failed ──► paid                  (the customer paid another way)
+//	paid           ──► refunded, disputed
+//	disputed       ──► paid (dispute won), refunded (dispute lost)
+//	refunded           terminal
+type paymentStatus string
+
+const (
+	paymentPending  paymentStatus = "pending" // order placed, gateway has not reported yet
+	paymentPaid     paymentStatus = "paid"
+	paymentFailed   paymentStatus = "payment_failed" // the customer may still pay another way
+	paymentRefunded paymentStatus = "refunded"       // terminal
+	paymentDisputed paymentStatus = "disputed"
+)
+
+// paymentTransitions lists, for every status, the statuses it may move to.
+var paymentTransitions = map[paymentStatus][]paymentStatus{
+	paymentPending:  {paymentPaid, paymentFailed},
+	paymentFailed:   {paymentPaid},
+	paymentPaid:     {paymentRefunded, paymentDisputed},
+	paymentDisputed: {paymentPaid, paymentRefunded},
+}
+
+// Errors returned by orderBook.transition.
+var (
+	ErrUnknownOrder       = errors.New("no such order")
+	ErrIllegalTransition  = errors.New("illegal payment status transition")         // not now, not ever
+	ErrTransitionTooEarly = errors.New("payment status transition not allowed yet") // legal after moves still to come
+)
+
+// orderBook is the tiny bit of order state this demo keeps: a payment
+// status per order ID.
+type orderBook struct {
+	mu       sync.Mutex
+	statuses map[string]paymentStatus
+}
+
+func newOrderBook() *orderBook {
+	return &orderBook{statuses: make(map[string]paymentStatus)}
+}
+
+// place records a new order, waiting for its payment.
+func (b *orderBook) place(orderID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.statuses[orderID]; !ok {
+		b.statuses[orderID] = paymentPending
+	}
+}
+
+// transition moves an order to status if paymentTransitions allows it.
+// Moving to the status the order is already in is a no-op, so a second
+// partial refund or a re-sent event with a new ID is harmless.
+//
+// A refused move is ErrTransitionTooEarly if some path of later moves leads
+// to it (a refund while pending: the success may still arrive), and
+// ErrIllegalTransition if none does (payment_failed after paid).
+func (b *orderBook) transition(orderID string, to paymentStatus) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	from, ok := b.statuses[orderID]
+	switch {
+	case !ok:
+		return fmt.Errorf("%w: %s", ErrUnknownOrder, orderID)
+	case from == to:
+		return nil
+	case slices.Contains(paymentTransitions[from], to):
+		b.statuses[orderID] = to
+		return nil
+	case reachable(from, to):
+		return fmt.Errorf("order %s: %w: %s → %s", orderID, ErrTransitionTooEarly, from, to)
+	}
+	return fmt.Errorf("order %s: %w: %s → %s", orderID, ErrIllegalTransition, from, to)
+}
+
+// reachable reports whether some sequence of moves leads from one status
+// to another.
+func reachable(from, to paymentStatus) bool {
+	seen := map[paymentStatus]bool{from: true}
+	queue := []paymentStatus{from}
+	for len(queue) > 0 {
+		for _, next := range paymentTransitions[queue[0]] {
+			if next == to {
+				return true
+			}
+			if !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+		queue = queue[1:]
+	}
+	return false
+}
+
+func (b *orderBook) status(orderID string) paymentStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.statuses[orderID]
+}
+
+// registerOrderHandlers makes every payment event move its order to the
+// matching status, through orderBook.transition. A move that is only too
+// early fails the delivery, so the gateway sends the event again later: an
+// event that overtook an earlier one (a refund before the success it
+// follows) then applies once the earlier one has arrived. Everything else
+// that fails — no order_id, an unknown order, a move that can never happen —
+// is rejected for good.
+func registerOrderHandlers(wr *webhookReceiver, orders *orderBook) {
+	statusFor := map[EventType]paymentStatus{
+		EventPaymentSucceeded: paymentPaid,
+		EventPaymentFailed:    paymentFailed,
+		EventPaymentRefunded:  paymentRefunded,
+		EventPaymentDisputed:  paymentDisputed,
+	}
+	for t, status := range statusFor {
+		wr.On(t, func(_ context.Context, e WebhookEvent) error {
+			if e.Data.OrderID == "" {
+				return fmt.Errorf("%w: event has no order_id", ErrEventRejected)
+			}
+			err := orders.transition(e.Data.OrderID, status)
+			if errors.Is(err, ErrUnknownOrder) || errors.Is(err, ErrIllegalTransition) {
+				return fmt.Errorf("%w: %w", ErrEventRejected, err)
+			}
+			return err
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testWebhookSecret = []byte("whsec_test")
+
+// deliver signs body at the given time and posts it to wr, returning the
+// status code.
+func deliver(wr *webhookReceiver, body string, at time.Time) int {
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+	req.Header.Set(WebhookSignatureHeader, signWebhook(testWebhookSecret, at, []byte(body)))
+	rec := httptest.NewRecorder()
+	wr.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func webhookBody(id string, t EventType, orderID string) string {
+	return fmt.Sprintf(`{"id":%q,"type":%q,"data":{"charge_id":"ch_1","order_id":%q,"amount":"10.00 INR"}}`, id, t, orderID)
+}
+
+func TestWebhookVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	wr := newWebhookReceiver(testWebhookSecret, webhookOptions{Now: func() time.Time { return now }})
+	body := []byte(`{"id":"evt_1"}`)
+	good := signWebhook(testWebhookSecret, now, body)
+	_, goodSig, _ := strings.Cut(good, ",")
+
+	tests := []struct {
+		name   string
+		header string
+		want   error
+	}{
+		{"valid", good, nil},
+		{"rotated secrets, one matches", signWebhook([]byte("old"), now, body) + "," + goodSig, nil},
+		{"wrong secret", signWebhook([]byte("guess"), now, body), ErrBadSignature},
+		{"no header", "", ErrBadSignature},
+		{"no signature", "t=1700000000", ErrBadSignature},
+		{"signature for another time", "t=1700000001," + goodSig, ErrBadSignature},
+		{"too old", signWebhook(testWebhookSecret, now.Add(-DefaultWebhookTolerance-time.Second), body), ErrStaleEvent},
+		{"from the future", signWebhook(testWebhookSecret, now.Add(DefaultWebhookTolerance+time.Second), body), ErrStaleEvent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := wr.Verify(tt.header, body); !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookDeduplicatesRedeliveries(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	wr := newWebhookReceiver(testWebhookSecret, webhookOptions{Retention: 72 * time.Hour, Now: func() time.Time { return now }})
+	handled := 0
+	wr.On(EventPaymentSucceeded, func(context.Context, WebhookEvent) error {
+		handled++
+		return nil
+	})
+	body := webhookBody("evt_1", EventPaymentSucceeded, "order-1")
+
+	tests := []struct {
+		after       time.Duration // since the previous delivery
+		wantHandled int
+	}{
+		{0, 1},
+		{time.Minute, 1},
+		{time.Hour, 1}, // far past the tolerance, but signed afresh
+		{48 * time.Hour, 1},
+		{72 * time.Hour, 2}, // retention over: the ID is forgotten
+	}
+	for _, tt := range tests {
+		now = now.Add(tt.after)
+		if code := deliver(wr, body, now); code != http.StatusOK {
+			t.Fatalf("delivery after %s answered %d", tt.after, code)
+		}
+		if handled != tt.wantHandled {
+			t.Fatalf("after %s: handled %d times, want %d", tt.after, handled, tt.wantHandled)
+		}
+	}
+}
+
+func TestWebhookHandlerErrorAllowsRetry(t *testing.T) {
+	wr := newWebhookReceiver(testWebhookSecret, webhookOptions{})
+	fail := true
+	wr.On(EventPaymentFailed, func(context.Context, WebhookEvent) error {
+		if fail {
+			return errors.New("database down")
+		}
+		return nil
+	})
+	body := webhookBody("evt_1", EventPaymentFailed, "order-1")
+	if code := deliver(wr, body, time.Now()); code != http.StatusInternalServerError {
+		t.Fatalf("failed handler answered %d, want 500", code)
+	}
+	fail = false
+	if code := deliver(wr, body, time.Now()); code != http.StatusOK {
+		t.Fatalf("retry answered %d, want 200", code)
+	}
+}
+
+func TestWebhookOrderTransitions(t *testing.T) {
+	type delivery struct {
+		event      EventType
+		wantCode   int
+		wantStatus paymentStatus
+	}
+	tests := []struct {
+		name       string
+		deliveries []delivery
+	}{
+		{"paid then refunded", []delivery{
+			{EventPaymentSucceeded, 200, paymentPaid},
+			{EventPaymentRefunded, 200, paymentRefunded},
+			{EventPaymentRefunded, 200, paymentRefunded}, // a second partial refund
+		}},
+		{"refund before success is retried", []delivery{
+			{EventPaymentRefunded, 500, paymentPending},
+			{EventPaymentSucceeded, 200, paymentPaid},
+			{EventPaymentRefunded, 200, paymentRefunded},
+		}},
+		{"dispute before success is retried", []delivery{
+			{EventPaymentDisputed, 500, paymentPending},
+			{EventPaymentFailed, 200, paymentFailed},
+			{EventPaymentDisputed, 500, paymentFailed},
+		}},
+		{"failed, then paid another way", []delivery{
+			{EventPaymentFailed, 200, paymentFailed},
+			{EventPaymentSucceeded, 200, paymentPaid},
+		}},
+		{"no going back", []delivery{
+			{EventPaymentSucceeded, 200, paymentPaid},
+			{EventPaymentFailed, 422, paymentPaid}, // stale: can never apply
+			{EventPaymentRefunded, 200, paymentRefunded},
+			{EventPaymentSucceeded, 422, paymentRefunded},
+			{EventPaymentDisputed, 422, paymentRefunded},
+		}},
+		{"dispute won", []delivery{
+			{EventPaymentSucceeded, 200, paymentPaid},
+			{EventPaymentDisputed, 200, paymentDisputed},
+			{EventPaymentSucceeded, 200, paymentPaid},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wr := newWebhookReceiver(testWebhookSecret, webhookOptions{OnRejected: func(WebhookEvent, error) {}})
+			orders := newOrderBook()
+			registerOrderHandlers(wr, orders)
+			orders.place("order-1")
+			for i, d := range tt.deliveries {
+				code := deliver(wr, webhookBody(fmt.Sprint("evt_", i), d.event, "order-1"), time.Now())
+				if code != d.wantCode || orders.status("order-1") != d.wantStatus {
+					t.Fatalf("delivery %d (%s): %d %s, want %d %s", i, d.event, code, orders.status("order-1"), d.wantCode, d.wantStatus)
+				}
+			}
+		})
+	}
+
+	orders := newOrderBook()
+	if err := orders.transition("order-x", paymentPaid); !errors.Is(err, ErrUnknownOrder) {
+		t.Fatalf("transition of an unknown order = %v, want ErrUnknownOrder", err)
+	}
+}
+
+func TestWebhookRejectsEventsThatCanNeverApply(t *testing.T) {
+	var rejected []string
+	wr := newWebhookReceiver(testWebhookSecret, webhookOptions{OnRejected: func(e WebhookEvent, err error) {
+		if !errors.Is(err, ErrEventRejected) {
+			t.Errorf("OnRejected(%s) got %v, want an ErrEventRejected", e.ID, err)
+		}
+		rejected = append(rejected, e.ID)
+	}})
+	orders := newOrderBook()
+	registerOrderHandlers(wr, orders)
+	orders.place("order-1")
+	if code := deliver(wr, webhookBody("evt_paid", EventPaymentSucceeded, "order-1"), time.Now()); code != http.StatusOK {
+		t.Fatalf("payment.succeeded answered %d", code)
+	}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"payment.failed after paid", webhookBody("evt_late", EventPaymentFailed, "order-1")},
+		{"unknown order", webhookBody("evt_unknown", EventPaymentSucceeded, "order-x")},
+		{"no order_id", webhookBody("evt_noorder", EventPaymentRefunded, "")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range 2 { // the first delivery, then a resend
+				if code := deliver(wr, tt.body, time.Now()); code >= 500 {
+					t.Fatalf("delivery %d answered %d: the gateway would retry for days", i, code)
+				}
+			}
+		})
+	}
+	if len(rejected) != len(tests) {
+		t.Fatalf("OnRejected told about %v, want each event once", rejected)
+	}
+	if got := orders.status("order-1"); got != paymentPaid {
+		t.Fatalf("order is %s after the rejected events, want paid", got)
+	}
+}
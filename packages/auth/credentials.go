@@ -1,7 +1,210 @@
 package auth
 
-import "fmt"
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
 
-func LoginWithCredentials(username string, password string) {
-	fmt.Println("login user using",username,password)
-}
\ No newline at end of file
+	"github.com/golang/user"
+)
+
+// ErrInvalidCredentials is returned by LoginWithCredentials when the
+// username is unknown or the password is wrong. The two cases are
+// deliberately indistinguishable to callers.
+var ErrInvalidCredentials = errors.New("auth: invalid username or password")
+
+// SetPassword hashes password and stores the hash on u. The caller is
+// responsible for saving u afterwards.
+func SetPassword(u *user.User, password string) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	u.PasswordHash = hash
+	return nil
+}
+
+// Result describes a successful login.
+type Result struct {
+	User            user.User
+	AuthenticatedAt time.Time
+	// AccessToken is a signed bearer token for User. It is empty unless
+	// the Authenticator was built with WithTokenIssuer.
+	AccessToken string
+}
+
+// Authenticator checks usernames and passwords against the users in a
+// user.Repository. The username is the user's email.
+type Authenticator struct {
+	users   user.Repository
+	tokens  *TokenIssuer
+	factors SecondFactorStore
+	totp    *TOTPVerifier
+	limiter *AttemptTracker
+	now     func() time.Time
+}
+
+// Option configures an Authenticator.
+type Option func(*Authenticator)
+
+// WithTokenIssuer makes successful logins return an access token signed
+// by t.
+func WithTokenIssuer(t *TokenIssuer) Option {
+	return func(a *Authenticator) { a.tokens = t }
+}
+
+// WithSecondFactor makes Login demand a TOTP code, checked by v, from
+// every user that has a secret enrolled in factors.
+func WithSecondFactor(factors SecondFactorStore, v *TOTPVerifier) Option {
+	return func(a *Authenticator) {
+		a.factors = factors
+		a.totp = v
+	}
+}
+
+// WithAttemptTracker makes Login refuse attempts for usernames and
+// sources that t has locked out, and report every failure to t.
+func WithAttemptTracker(t *AttemptTracker) Option {
+	return func(a *Authenticator) { a.limiter = t }
+}
+
+// WithClock overrides the time source, which defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(a *Authenticator) { a.now = now }
+}
+
+// NewAuthenticator returns an Authenticator that looks users up in users.
+func NewAuthenticator(users user.Repository, opts ...Option) *Authenticator {
+	a := &Authenticator{users: users, now: time.Now}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// LoginRequest holds everything a user presents when logging in.
+type LoginRequest struct {
+	Username string
+	Password string
+	// OTP is the current TOTP code. It is only consulted for users with
+	// two-factor authentication enrolled.
+	OTP string
+	// Source identifies where the attempt came from, such as a client IP
+	// address. When set, failures are also tracked per source.
+	Source string
+}
+
+// LoginWithCredentials is Login without a second factor. Users with
+// two-factor authentication enrolled get ErrSecondFactorRequired.
+func (a *Authenticator) LoginWithCredentials(username string, password string) (Result, error) {
+	return a.Login(LoginRequest{Username: username, Password: password})
+}
+
+// Login verifies the password for req.Username and, if the user has
+// enrolled a second factor, req.OTP. It returns ErrInvalidCredentials if
+// the username or password is wrong, ErrSecondFactorRequired or
+// ErrInvalidOTP if the code is missing or wrong, ErrAccountLocked if the
+// username or source has failed too often recently, and otherwise errors
+// from the underlying stores. Neither the password nor its hash is ever
+// logged.
+func (a *Authenticator) Login(req LoginRequest) (Result, error) {
+	keys := a.attemptKeys(req)
+	for i, key := range keys {
+		if err := a.limiter.Begin(key); err != nil {
+			for _, begun := range keys[:i] {
+				a.limiter.Cancel(begun)
+			}
+			return Result{}, err
+		}
+	}
+
+	u, err := a.authenticate(req)
+	switch {
+	case err == nil:
+		// Only the user's own record is cleared. A source's failures
+		// expire on their own: otherwise a client guessing other people's
+		// passwords could reset its limit by logging into its own account.
+		for i, key := range keys {
+			if i == 0 { // the user key; see attemptKeys
+				a.limiter.Success(key)
+			} else {
+				a.limiter.Cancel(key)
+			}
+		}
+	case errors.Is(err, ErrInvalidCredentials), errors.Is(err, ErrInvalidOTP), errors.Is(err, ErrOTPReplayed):
+		for _, key := range keys {
+			a.limiter.Failure(key)
+		}
+		return Result{}, err
+	default:
+		for _, key := range keys {
+			a.limiter.Cancel(key)
+		}
+		return Result{}, err
+	}
+
+	res := Result{User: u, AuthenticatedAt: a.now()}
+	if a.tokens != nil {
+		if res.AccessToken, err = a.tokens.Issue(u); err != nil {
+			return Result{}, err
+		}
+	}
+	return res, nil
+}
+
+func (a *Authenticator) authenticate(req LoginRequest) (user.User, error) {
+	u, err := a.users.GetByEmail(req.Username)
+	if errors.Is(err, user.ErrNotFound) || err == nil && u.PasswordHash == "" {
+		// Burn the same amount of work as a real check so that response
+		// times do not reveal which usernames exist.
+		VerifyPassword(dummyHash(), req.Password)
+		return user.User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return user.User{}, err
+	}
+	if err := VerifyPassword(u.PasswordHash, req.Password); err != nil {
+		if errors.Is(err, ErrPasswordMismatch) {
+			return user.User{}, ErrInvalidCredentials
+		}
+		return user.User{}, err
+	}
+	if err := a.checkSecondFactor(u.Email, req.OTP); err != nil {
+		return user.User{}, err
+	}
+	u.PasswordHash = ""
+	return u, nil
+}
+
+// attemptKeys returns the AttemptTracker keys for req, username first, or
+// nil when no tracker is configured.
+func (a *Authenticator) attemptKeys(req LoginRequest) []string {
+	if a.limiter == nil {
+		return nil
+	}
+	keys := []string{"user:" + strings.ToLower(strings.TrimSpace(req.Username))}
+	if req.Source != "" {
+		keys = append(keys, "source:"+req.Source)
+	}
+	return keys
+}
+
+func (a *Authenticator) checkSecondFactor(email, otp string) error {
+	if a.factors == nil {
+		return nil
+	}
+	secret, enrolled, err := a.factors.TOTPSecret(email)
+	if err != nil || !enrolled {
+		return err
+	}
+	if otp == "" {
+		return ErrSecondFactorRequired
+	}
+	return a.totp.Verify(email, secret, otp)
+}
+
+var dummyHash = sync.OnceValue(func() string {
+	h, _ := HashPassword("not a real password")
+	return h
+})
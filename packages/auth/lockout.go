@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrAccountLocked is returned while further login attempts are refused
+// because of recent failures. Use errors.As with *LockedError to find out
+// how long to wait.
+var ErrAccountLocked = errors.New("auth: too many failed attempts")
+
+// LockedError reports a refused attempt. It matches ErrAccountLocked with
+// errors.Is.
+type LockedError struct {
+	Key        string
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%v: retry in %s", ErrAccountLocked, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockedError) Unwrap() error { return ErrAccountLocked }
+
+// Defaults used by NewAttemptTracker.
+const (
+	DefaultMaxFailures     = 5
+	DefaultLockoutDuration = 15 * time.Minute
+	DefaultBaseDelay       = time.Second
+	DefaultMaxDelay        = time.Minute
+	DefaultFailureWindow   = time.Hour
+)
+
+// LockoutOptions configures an AttemptTracker. Zero fields take their
+// defaults.
+type LockoutOptions struct {
+	// MaxFailures is the number of consecutive failures after which a key
+	// is locked out for LockoutDuration.
+	MaxFailures     int
+	LockoutDuration time.Duration
+	// BaseDelay is the wait imposed after the first failure. It doubles
+	// with every further failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// FailureWindow is how long a key must go without failures before its
+	// count is forgotten.
+	FailureWindow time.Duration
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// AttemptTracker counts failed attempts per key (a username, a client
+// address, ...) and decides when the next attempt may be made. It is safe
+// for concurrent use.
+//
+// An attempt is reserved with Begin BEFORE the slow password check and
+// settled afterwards with Success, Failure or Cancel. Reserving and
+// refusing happen under one lock, so parallel guesses cannot all slip past
+// the limits while none of them has been recorded yet.
+type AttemptTracker struct {
+	opts LockoutOptions
+
+	mu      sync.Mutex
+	records map[string]*attempts
+	pruned  time.Time // when records was last swept for expired keys
+}
+
+type attempts struct {
+	failures    int
+	inFlight    int // attempts begun but not yet settled
+	lastFailure time.Time
+	blocked     time.Time
+}
+
+// NewAttemptTracker returns a tracker configured by opts.
+func NewAttemptTracker(opts LockoutOptions) *AttemptTracker {
+	if opts.MaxFailures <= 0 {
+		opts.MaxFailures = DefaultMaxFailures
+	}
+	if opts.LockoutDuration <= 0 {
+		opts.LockoutDuration = DefaultLockoutDuration
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = DefaultBaseDelay
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = DefaultMaxDelay
+	}
+	if opts.FailureWindow <= 0 {
+		opts.FailureWindow = DefaultFailureWindow
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &AttemptTracker{opts: opts, records: make(map[string]*attempts)}
+}
+
+// Check returns a *LockedError if key may not attempt yet. It reserves
+// nothing, so it is only advisory; use Begin to make an attempt.
+func (t *AttemptTracker) Check(key string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.records[key]
+	if !ok {
+		return nil
+	}
+	return t.refuse(key, r, t.opts.Now())
+}
+
+// Begin reserves an attempt for key, or returns a *LockedError if key may
+// not attempt yet. Every successful Begin must be followed by exactly one
+// Success, Failure or Cancel for key.
+//
+// Attempts in flight count against the limits as if they had failed:
+// there are never more of them than failures left before the lockout, and
+// once key has failed at all, only one may be in flight at a time.
+func (t *AttemptTracker) Begin(key string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prune(t.opts.Now())
+	r, ok := t.records[key]
+	if !ok {
+		r = &attempts{}
+		t.records[key] = r
+	}
+	if err := t.refuse(key, r, t.opts.Now()); err != nil {
+		return err
+	}
+	r.inFlight++
+	return nil
+}
+
+// refuse returns a *LockedError if r allows no further attempt at now.
+// It forgets failures older than the failure window. The caller holds t.mu.
+func (t *AttemptTracker) refuse(key string, r *attempts, now time.Time) error {
+	if wait := r.blocked.Sub(now); wait > 0 {
+		return &LockedError{Key: key, RetryAfter: wait}
+	}
+	if r.failures > 0 && now.Sub(r.lastFailure) >= t.opts.FailureWindow {
+		r.failures = 0
+	}
+	// Once a lockout has been served, failures stays at MaxFailures or more
+	// and the rule below lets one attempt through at a time.
+	budgetUsed := r.failures < t.opts.MaxFailures && r.failures+r.inFlight >= t.opts.MaxFailures
+	if budgetUsed || r.failures > 0 && r.inFlight > 0 {
+		return &LockedError{Key: key, RetryAfter: t.delay(r.failures + 1)}
+	}
+	return nil
+}
+
+// prune forgets keys with nothing left to remember: no attempt in flight,
+// no block still running and no failure inside the window. Keys are chosen
+// by whoever attempts to log in, so without this a stream of made-up
+// usernames would grow records forever. It sweeps at most once per
+// MaxDelay. The caller holds t.mu.
+func (t *AttemptTracker) prune(now time.Time) {
+	if now.Sub(t.pruned) < t.opts.MaxDelay {
+		return
+	}
+	t.pruned = now
+	for key, r := range t.records {
+		if r.inFlight == 0 && !now.Before(r.blocked) && now.Sub(r.lastFailure) >= t.opts.FailureWindow {
+			delete(t.records, key)
+		}
+	}
+}
+
+// delay returns the backoff imposed after the n-th consecutive failure.
+func (t *AttemptTracker) delay(n int) time.Duration {
+	d := t.opts.BaseDelay << max(n-1, 0)
+	if d <= 0 || d > t.opts.MaxDelay || n > 62 {
+		d = t.opts.MaxDelay
+	}
+	return d
+}
+
+// Failure settles an attempt for key as failed.
+func (t *AttemptTracker) Failure(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.opts.Now()
+	r, ok := t.records[key]
+	if !ok {
+		r = &attempts{}
+		t.records[key] = r
+	}
+	r.inFlight = max(r.inFlight-1, 0)
+	if r.failures > 0 && now.Sub(r.lastFailure) >= t.opts.FailureWindow {
+		r.failures = 0
+	}
+	r.failures++
+	r.lastFailure = now
+	if r.failures >= t.opts.MaxFailures {
+		r.blocked = now.Add(t.opts.LockoutDuration)
+		return
+	}
+	r.blocked = now.Add(t.delay(r.failures))
+}
+
+// Success settles an attempt for key as successful and clears its failure
+// history.
+func (t *AttemptTracker) Success(key string) {
+	t.mu.Lock()
+	delete(t.records, key)
+	t.mu.Unlock()
+}
+
+// Cancel releases an attempt for key that ended in neither success nor
+// failure, such as one aborted by a storage error.
+func (t *AttemptTracker) Cancel(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.records[key]
+	if !ok {
+		return
+	}
+	r.inFlight = max(r.inFlight-1, 0)
+	if r.inFlight == 0 && r.failures == 0 {
+		delete(t.records, key)
+	}
+}
+
+// Unlock is an alias for Success, for administrators releasing a locked
+// account by hand.
+func (t *AttemptTracker) Unlock(key string) { t.Success(key) }
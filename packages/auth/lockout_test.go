@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/user"
+)
+
+// fakeClock is a settable time source for the Now option of the
+// lockout, token and session tests.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestAttemptTrackerBackoff(t *testing.T) {
+	clock := newFakeClock()
+	tr := NewAttemptTracker(LockoutOptions{
+		MaxFailures:     4,
+		LockoutDuration: time.Hour,
+		BaseDelay:       time.Second,
+		MaxDelay:        3 * time.Second,
+		Now:             clock.Now,
+	})
+
+	// After the n-th failure the key must wait this long.
+	tests := []struct {
+		failure int
+		wait    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 3 * time.Second}, // 4s capped at MaxDelay
+		{4, time.Hour},       // MaxFailures reached: locked out
+	}
+	for _, tt := range tests {
+		if err := tr.Begin("k"); err != nil {
+			t.Fatalf("failure %d: Begin: %v", tt.failure, err)
+		}
+		tr.Failure("k")
+
+		var locked *LockedError
+		if err := tr.Begin("k"); !errors.As(err, &locked) || locked.RetryAfter != tt.wait {
+			t.Fatalf("after failure %d: Begin = %v, want retry in %s", tt.failure, err, tt.wait)
+		}
+		clock.Advance(tt.wait - time.Nanosecond)
+		if err := tr.Check("k"); !errors.Is(err, ErrAccountLocked) {
+			t.Fatalf("after failure %d: still blocked just before the wait ends, got %v", tt.failure, err)
+		}
+		clock.Advance(time.Nanosecond)
+	}
+
+	if err := tr.Begin("k"); err != nil {
+		t.Fatalf("Begin after lockout expired: %v", err)
+	}
+	tr.Success("k")
+	if err := tr.Check("k"); err != nil {
+		t.Fatalf("Check after Success: %v", err)
+	}
+}
+
+func TestAttemptTrackerFailureWindow(t *testing.T) {
+	clock := newFakeClock()
+	tr := NewAttemptTracker(LockoutOptions{MaxFailures: 2, FailureWindow: time.Hour, Now: clock.Now})
+
+	tr.Begin("k")
+	tr.Failure("k")
+	clock.Advance(time.Hour)
+
+	// The old failure is forgotten, so this one is the first again.
+	tr.Begin("k")
+	tr.Failure("k")
+	var locked *LockedError
+	if err := tr.Check("k"); !errors.As(err, &locked) || locked.RetryAfter != DefaultBaseDelay {
+		t.Fatalf("Check = %v, want backoff of %s, not a lockout", err, DefaultBaseDelay)
+	}
+}
+
+func TestAttemptTrackerConcurrentBegin(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    int // recorded before the parallel attempts
+		wantAllowed int
+	}{
+		{"no failures: up to MaxFailures", 0, 3},
+		{"after a failure: one at a time", 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			tr := NewAttemptTracker(LockoutOptions{MaxFailures: 3, Now: clock.Now})
+			for range tt.failures {
+				tr.Begin("k")
+				tr.Failure("k")
+			}
+			clock.Advance(time.Hour - time.Second) // past the backoff, inside the window
+
+			var allowed sync.WaitGroup
+			var mu sync.Mutex
+			n := 0
+			for range 20 {
+				allowed.Add(1)
+				go func() {
+					defer allowed.Done()
+					if tr.Begin("k") == nil {
+						mu.Lock()
+						n++
+						mu.Unlock()
+					}
+				}()
+			}
+			allowed.Wait()
+			if n != tt.wantAllowed {
+				t.Fatalf("%d parallel attempts allowed, want %d", n, tt.wantAllowed)
+			}
+		})
+	}
+}
+
+func TestAttemptTrackerCancel(t *testing.T) {
+	tr := NewAttemptTracker(LockoutOptions{MaxFailures: 1})
+	if err := tr.Begin("k"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Begin("k"); err == nil {
+		t.Fatal("second attempt allowed while the only one left is in flight")
+	}
+	tr.Cancel("k")
+	if err := tr.Begin("k"); err != nil {
+		t.Fatalf("Begin after Cancel: %v", err)
+	}
+}
+
+func TestLoginParallelGuessesAreLimited(t *testing.T) {
+	users := user.NewMemoryRepository()
+	hash, err := HashPasswordWithCost("correct horse", MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users.Create(user.User{Email: "ann@example.com", Name: "Ann", PasswordHash: hash})
+
+	clock := newFakeClock()
+	tracker := NewAttemptTracker(LockoutOptions{MaxFailures: 3, Now: clock.Now})
+	a := NewAuthenticator(users, WithAttemptTracker(tracker))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	checked := 0 // guesses whose password was actually verified
+	for range 30 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := a.Login(LoginRequest{Username: "ann@example.com", Password: "guess", Source: "203.0.113.9"})
+			if errors.Is(err, ErrInvalidCredentials) {
+				mu.Lock()
+				checked++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if checked > 3 {
+		t.Fatalf("%d guesses were checked, want at most MaxFailures (3)", checked)
+	}
+
+	// A successful login clears the user's key but not the source's: a
+	// client cannot wipe its record by logging into its own account.
+	clock.Advance(DefaultLockoutDuration)
+	if _, err := a.Login(LoginRequest{Username: "ann@example.com", Password: "correct horse", Source: "203.0.113.9"}); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if err := tracker.Check("user:ann@example.com"); err != nil {
+		t.Errorf("Check(user) after success = %v", err)
+	}
+	a.Login(LoginRequest{Username: "bob@example.com", Password: "guess", Source: "203.0.113.9"})
+	var locked *LockedError
+	if _, err := a.Login(LoginRequest{Username: "cy@example.com", Password: "guess", Source: "203.0.113.9"}); !errors.As(err, &locked) || locked.Key != "source:203.0.113.9" || locked.RetryAfter <= DefaultBaseDelay {
+		// A cleared source would have one failure: the first, shortest delay.
+		t.Fatalf("next guess from the source = %v, want a delay that counts its earlier failures", err)
+	}
+}
+
+func TestAttemptTrackerForgetsExpiredKeys(t *testing.T) {
+	clock := newFakeClock()
+	tr := NewAttemptTracker(LockoutOptions{MaxFailures: 2, LockoutDuration: time.Hour, FailureWindow: time.Hour, Now: clock.Now})
+	for i := range 1000 { // one failure each for a thousand made-up usernames
+		key := fmt.Sprint("user:nobody", i)
+		tr.Begin(key)
+		tr.Failure(key)
+	}
+	clock.Advance(59 * time.Minute)
+	tr.Begin("user:fresh")
+	if n := len(tr.records); n != 1001 {
+		t.Fatalf("%d records inside the failure window, want all 1001 kept", n)
+	}
+	clock.Advance(time.Hour)
+	tr.Begin("user:fresh")
+	if n := len(tr.records); n != 1 {
+		t.Fatalf("%d records after the window passed, want only the one in flight", n)
+	}
+}
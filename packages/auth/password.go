@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Cost bounds for HashPasswordWithCost. The cost is the PBKDF2-HMAC-SHA256
+// iteration count, so doubling it roughly doubles the time to hash.
+const (
+	MinCost     = 10_000
+	DefaultCost = 600_000
+	MaxCost     = 10_000_000
+)
+
+const (
+	hashScheme = "pbkdf2-sha256"
+	saltLen    = 16
+	keyLen     = 32
+)
+
+var (
+	// ErrPasswordMismatch is returned by VerifyPassword when the password
+	// does not match the stored hash.
+	ErrPasswordMismatch = errors.New("auth: password does not match hash")
+
+	// ErrMalformedHash is returned when a stored hash cannot be parsed.
+	ErrMalformedHash = errors.New("auth: malformed password hash")
+
+	// ErrInvalidCost is returned when the requested cost is out of range.
+	ErrInvalidCost = errors.New("auth: hash cost out of range")
+)
+
+var b64 = base64.RawStdEncoding
+
+// HashPassword returns a salted hash of password using DefaultCost.
+//
+// The result is self-describing, in the form
+//
+//	$pbkdf2-sha256$<cost>$<salt>$<key>
+//
+// so it can be stored as-is and later passed to VerifyPassword.
+func HashPassword(password string) (string, error) {
+	return HashPasswordWithCost(password, DefaultCost)
+}
+
+// HashPasswordWithCost is like HashPassword but uses the given cost.
+func HashPasswordWithCost(password string, cost int) (string, error) {
+	if cost < MinCost || cost > MaxCost {
+		return "", fmt.Errorf("%w: %d", ErrInvalidCost, cost)
+	}
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, cost, keyLen)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("$%s$%d$%s$%s", hashScheme, cost, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+// VerifyPassword reports whether password matches hash. It returns nil on
+// success, ErrPasswordMismatch if the password is wrong and ErrMalformedHash
+// if hash was not produced by HashPassword.
+func VerifyPassword(hash, password string) error {
+	cost, salt, want, err := parseHash(hash)
+	if err != nil {
+		return err
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, cost, len(want))
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+func parseHash(hash string) (cost int, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 5 || parts[0] != "" || parts[1] != hashScheme {
+		return 0, nil, nil, ErrMalformedHash
+	}
+	cost, err = strconv.Atoi(parts[2])
+	if err != nil || cost < MinCost || cost > MaxCost {
+		return 0, nil, nil, ErrMalformedHash
+	}
+	salt, err = b64.DecodeString(parts[3])
+	if err != nil || len(salt) == 0 {
+		return 0, nil, nil, ErrMalformedHash
+	}
+	key, err = b64.DecodeString(parts[4])
+	if err != nil || len(key) == 0 {
+		return 0, nil, nil, ErrMalformedHash
+	}
+	return cost, salt, key, nil
+}
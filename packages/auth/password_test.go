@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// rfcHash builds a stored hash from a published PBKDF2-HMAC-SHA256 test
+// vector, so VerifyPassword is checked against keys computed elsewhere.
+func rfcHash(t *testing.T, cost int, salt, keyHex string) string {
+	t.Helper()
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "$" + hashScheme + "$" + strconv.Itoa(cost) + "$" + b64.EncodeToString([]byte(salt)) + "$" + b64.EncodeToString(key)
+}
+
+func TestVerifyPasswordKnownVectors(t *testing.T) {
+	// RFC 7914 §11: PBKDF2-HMAC-SHA256, P="Password", S="NaCl", c=80000, dkLen=64.
+	const rfc7914 = "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56" +
+		"a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"
+	hash := rfcHash(t, 80000, "NaCl", rfc7914)
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		want     error
+	}{
+		{"rfc 7914 vector", hash, "Password", nil},
+		{"wrong password", hash, "password", ErrPasswordMismatch},
+		{"empty password", hash, "", ErrPasswordMismatch},
+		// RFC 7914 §11 P="passwd", S="salt", c=1 is a valid key, but its
+		// cost is below MinCost, so the hash is refused outright.
+		{"cost below minimum", rfcHash(t, 1, "salt", "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"), "passwd", ErrMalformedHash},
+		{"unknown scheme", strings.Replace(hash, hashScheme, "bcrypt", 1), "Password", ErrMalformedHash},
+		{"missing field", "$" + hashScheme + "$80000$" + b64.EncodeToString([]byte("NaCl")), "Password", ErrMalformedHash},
+		{"bad base64", "$" + hashScheme + "$80000$!!$!!", "Password", ErrMalformedHash},
+		{"empty", "", "Password", ErrMalformedHash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyPassword(tt.hash, tt.password); !errors.Is(err, tt.want) {
+				t.Fatalf("VerifyPassword = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashPasswordRoundTrip(t *testing.T) {
+	h1, err := HashPasswordWithCost("s3cret", MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, err := HashPasswordWithCost("s3cret", MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h1 == h2 {
+		t.Fatal("two hashes of the same password are equal; the salt is not random")
+	}
+	if !strings.HasPrefix(h1, "$"+hashScheme+"$"+strconv.Itoa(MinCost)+"$") {
+		t.Fatalf("hash %q does not describe its scheme and cost", h1)
+	}
+	if err := VerifyPassword(h1, "s3cret"); err != nil {
+		t.Fatalf("VerifyPassword(correct) = %v", err)
+	}
+	if err := VerifyPassword(h1, "S3cret"); !errors.Is(err, ErrPasswordMismatch) {
+		t.Fatalf("VerifyPassword(wrong) = %v, want ErrPasswordMismatch", err)
+	}
+}
+
+func TestHashPasswordWithCostBounds(t *testing.T) {
+	tests := []struct {
+		cost int
+		want error
+	}{
+		{MinCost - 1, ErrInvalidCost},
+		{MinCost, nil},
+		{MaxCost + 1, ErrInvalidCost},
+		{0, ErrInvalidCost},
+	}
+	for _, tt := range tests {
+		if _, err := HashPasswordWithCost("pw", tt.cost); !errors.Is(err, tt.want) {
+			t.Errorf("HashPasswordWithCost(cost %d) = %v, want %v", tt.cost, err, tt.want)
+		}
+	}
+}
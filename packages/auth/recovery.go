@@ -0,0 +1,333 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/user"
+)
+
+// Purpose says what an account token may be used for. A token issued for
+// one purpose is rejected for any other.
+type Purpose string
+
+const (
+	PurposePasswordReset     Purpose = "password-reset"
+	PurposeEmailVerification Purpose = "email-verification"
+)
+
+// Errors returned by AccountTokens.
+var (
+	ErrAccountTokenInvalid = errors.New("auth: invalid account token")
+	ErrAccountTokenExpired = errors.New("auth: account token expired")
+	ErrAccountTokenUsed    = errors.New("auth: account token already used")
+)
+
+// Default lifetimes used by NewAccountTokens.
+const (
+	DefaultResetTTL        = time.Hour
+	DefaultVerificationTTL = 48 * time.Hour
+)
+
+// Message is what AccountTokens asks a Notifier to deliver, typically as
+// an email containing a link with Token in it.
+type Message struct {
+	To        string
+	Purpose   Purpose
+	Token     string
+	ExpiresAt time.Time
+}
+
+// Notifier delivers account token messages to users.
+type Notifier interface {
+	Notify(Message) error
+}
+
+// NotifierFunc adapts a function to the Notifier interface.
+type NotifierFunc func(Message) error
+
+// Notify calls f(m).
+func (f NotifierFunc) Notify(m Message) error { return f(m) }
+
+// AccountTokenOptions configures AccountTokens. Zero fields take their
+// defaults.
+type AccountTokenOptions struct {
+	ResetTTL        time.Duration
+	VerificationTTL time.Duration
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// AccountTokens runs the password reset and email verification flows.
+// Tokens are HMAC-signed, expire, can be used once, and every outstanding
+// token of a user is invalidated when their password changes. It is safe
+// for concurrent use.
+type AccountTokens struct {
+	users    user.Repository
+	notifier Notifier
+	key      []byte
+	opts     AccountTokenOptions
+
+	mu     sync.Mutex
+	issued map[string]*accountToken // by token ID
+}
+
+type accountToken struct {
+	email   string
+	purpose Purpose
+	expires time.Time
+	used    bool
+	revoked bool // by PasswordChanged while in use; release leaves it set
+}
+
+type accountTokenPayload struct {
+	ID      string  `json:"id"`
+	Email   string  `json:"email"`
+	Purpose Purpose `json:"purpose"`
+	Expires int64   `json:"exp"`
+}
+
+// NewAccountTokens returns AccountTokens that sign with key, look users up
+// in users and send messages through notifier.
+func NewAccountTokens(users user.Repository, notifier Notifier, key []byte, opts AccountTokenOptions) *AccountTokens {
+	if opts.ResetTTL <= 0 {
+		opts.ResetTTL = DefaultResetTTL
+	}
+	if opts.VerificationTTL <= 0 {
+		opts.VerificationTTL = DefaultVerificationTTL
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &AccountTokens{
+		users:    users,
+		notifier: notifier,
+		key:      bytes.Clone(key),
+		opts:     opts,
+		issued:   make(map[string]*accountToken),
+	}
+}
+
+// RequestPasswordReset sends a reset token to the user with email. It
+// returns nil without sending anything if there is no such user, so the
+// response does not reveal which addresses are registered.
+func (t *AccountTokens) RequestPasswordReset(email string) error {
+	return t.request(email, PurposePasswordReset, t.opts.ResetTTL)
+}
+
+// RequestEmailVerification sends a verification token to the user with
+// email. Like RequestPasswordReset it is silent about unknown addresses.
+func (t *AccountTokens) RequestEmailVerification(email string) error {
+	return t.request(email, PurposeEmailVerification, t.opts.VerificationTTL)
+}
+
+func (t *AccountTokens) request(email string, purpose Purpose, ttl time.Duration) error {
+	u, err := t.users.GetByEmail(email)
+	if errors.Is(err, user.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	expires := t.opts.Now().Add(ttl)
+	payload := accountTokenPayload{
+		ID:      base64.RawURLEncoding.EncodeToString(id),
+		Email:   u.Email,
+		Purpose: purpose,
+		Expires: expires.Unix(),
+	}
+	token, err := t.sign(payload)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.prune()
+	t.issued[payload.ID] = &accountToken{email: u.Email, purpose: purpose, expires: expires}
+	t.mu.Unlock()
+
+	return t.notifier.Notify(Message{To: u.Email, Purpose: purpose, Token: token, ExpiresAt: expires})
+}
+
+// Verify checks token for purpose without using it up and returns the
+// email it was issued to.
+func (t *AccountTokens) Verify(token string, purpose Purpose) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rec, err := t.lookup(token, purpose)
+	if err != nil {
+		return "", err
+	}
+	return rec.email, nil
+}
+
+// ResetPassword uses a password reset token to set a new password, then
+// invalidates every other outstanding token of the user. If the password
+// cannot be saved the token stays usable.
+func (t *AccountTokens) ResetPassword(token, newPassword string) (err error) {
+	email, release, err := t.consume(token, PurposePasswordReset)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+	u, err := t.users.GetByEmail(email)
+	if err != nil {
+		return err
+	}
+	if err := SetPassword(&u, newPassword); err != nil {
+		return err
+	}
+	if err := t.users.Update(u); err != nil {
+		return err
+	}
+	t.PasswordChanged(u.Email)
+	return nil
+}
+
+// ConfirmEmail uses a verification token to mark the user's email as
+// verified. If the user cannot be updated the token stays usable.
+func (t *AccountTokens) ConfirmEmail(token string) (err error) {
+	email, release, err := t.consume(token, PurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+	u, err := t.users.GetByEmail(email)
+	if err != nil {
+		return err
+	}
+	u.EmailVerified = true
+	return t.users.Update(u)
+}
+
+// PasswordChanged invalidates every outstanding token issued to email.
+// Call it whenever a password is changed outside ResetPassword.
+func (t *AccountTokens) PasswordChanged(email string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, rec := range t.issued {
+		if !strings.EqualFold(rec.email, email) {
+			continue
+		}
+		// Used tokens are kept until they expire so that reuse is
+		// reported as such. One may still be in use by a change that
+		// fails and releases it, so it is revoked rather than left alone.
+		if rec.used {
+			rec.revoked = true
+		} else {
+			delete(t.issued, id)
+		}
+	}
+}
+
+// consume marks token used, so a concurrent request with the same token
+// fails, and returns a release func that makes it usable again unless
+// PasswordChanged revoked it meanwhile. Callers release the token when the
+// change it authorizes could not be saved.
+func (t *AccountTokens) consume(token string, purpose Purpose) (email string, release func(), err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rec, err := t.lookup(token, purpose)
+	if err != nil {
+		return "", nil, err
+	}
+	rec.used = true
+	release = func() {
+		t.mu.Lock()
+		rec.used = false
+		t.mu.Unlock()
+	}
+	return rec.email, release, nil
+}
+
+// lookup checks the signature of token and the state of its record. The
+// caller must hold t.mu.
+func (t *AccountTokens) lookup(token string, purpose Purpose) (*accountToken, error) {
+	payload, err := t.parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if payload.Purpose != purpose {
+		return nil, ErrAccountTokenInvalid
+	}
+	rec, ok := t.issued[payload.ID]
+	if !ok || rec.purpose != purpose || rec.email != payload.Email {
+		return nil, ErrAccountTokenInvalid
+	}
+	if rec.used {
+		return nil, ErrAccountTokenUsed
+	}
+	if rec.revoked {
+		return nil, ErrAccountTokenInvalid
+	}
+	if !t.opts.Now().Before(rec.expires) {
+		return nil, ErrAccountTokenExpired
+	}
+	return rec, nil
+}
+
+// prune forgets expired tokens. The caller must hold t.mu.
+func (t *AccountTokens) prune() {
+	now := t.opts.Now()
+	for id, rec := range t.issued {
+		if !now.Before(rec.expires) {
+			delete(t.issued, id)
+		}
+	}
+}
+
+func (t *AccountTokens) sign(p accountTokenPayload) (string, error) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding.EncodeToString(body)
+	return enc + "." + base64.RawURLEncoding.EncodeToString(t.mac(enc)), nil
+}
+
+func (t *AccountTokens) parse(token string) (accountTokenPayload, error) {
+	var p accountTokenPayload
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return p, ErrAccountTokenInvalid
+	}
+	rawSig, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(rawSig, t.mac(body)) {
+		return p, ErrAccountTokenInvalid
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return p, ErrAccountTokenInvalid
+	}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return p, ErrAccountTokenInvalid
+	}
+	return p, nil
+}
+
+func (t *AccountTokens) mac(body string) []byte {
+	m := hmac.New(sha256.New, t.key)
+	m.Write([]byte(body))
+	return m.Sum(nil)
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/user"
+)
+
+// flakyUsers is a user.Repository whose next Update fails with failNext.
+// beforeUpdate, if set, runs at the start of every Update.
+type flakyUsers struct {
+	*user.MemoryRepository
+	failNext     error
+	beforeUpdate func()
+}
+
+func (r *flakyUsers) Update(u user.User) error {
+	if r.beforeUpdate != nil {
+		r.beforeUpdate()
+	}
+	if err := r.failNext; err != nil {
+		r.failNext = nil
+		return err
+	}
+	return r.MemoryRepository.Update(u)
+}
+
+func TestAccountTokensFailedUpdateKeepsToken(t *testing.T) {
+	errDisk := errors.New("disk full")
+	tests := []struct {
+		purpose Purpose
+		use     func(*AccountTokens, string) error
+	}{
+		{PurposePasswordReset, func(a *AccountTokens, tok string) error { return a.ResetPassword(tok, "new password") }},
+		{PurposeEmailVerification, (*AccountTokens).ConfirmEmail},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.purpose), func(t *testing.T) {
+			users := &flakyUsers{MemoryRepository: user.NewMemoryRepository()}
+			users.Create(user.User{Email: "ann@example.com", Name: "Ann"})
+			var sent Message
+			tokens := NewAccountTokens(users, NotifierFunc(func(m Message) error { sent = m; return nil }), []byte("key"), AccountTokenOptions{})
+			var err error
+			if tt.purpose == PurposePasswordReset {
+				err = tokens.RequestPasswordReset("ann@example.com")
+			} else {
+				err = tokens.RequestEmailVerification("ann@example.com")
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			users.failNext = errDisk
+			if err := tt.use(tokens, sent.Token); !errors.Is(err, errDisk) {
+				t.Fatalf("first use = %v, want %v", err, errDisk)
+			}
+			if err := tt.use(tokens, sent.Token); err != nil {
+				t.Fatalf("retry after failed update = %v, want success", err)
+			}
+			if err := tt.use(tokens, sent.Token); !errors.Is(err, ErrAccountTokenUsed) {
+				t.Fatalf("third use = %v, want ErrAccountTokenUsed", err)
+			}
+		})
+	}
+}
+
+func TestAccountTokensPasswordChangeRevokesTokenInUse(t *testing.T) {
+	users := &flakyUsers{MemoryRepository: user.NewMemoryRepository()}
+	users.Create(user.User{Email: "ann@example.com", Name: "Ann"})
+	var sent Message
+	tokens := NewAccountTokens(users, NotifierFunc(func(m Message) error { sent = m; return nil }), []byte("key"), AccountTokenOptions{})
+	if err := tokens.RequestPasswordReset("ann@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The password changes elsewhere while the reset is saving, and the
+	// reset then fails: releasing its token must not bring it back.
+	errDisk := errors.New("disk full")
+	users.failNext = errDisk
+	users.beforeUpdate = func() {
+		users.beforeUpdate = nil
+		tokens.PasswordChanged("ANN@example.com")
+	}
+	if err := tokens.ResetPassword(sent.Token, "new password"); !errors.Is(err, errDisk) {
+		t.Fatalf("reset = %v, want %v", err, errDisk)
+	}
+	if err := tokens.ResetPassword(sent.Token, "new password"); !errors.Is(err, ErrAccountTokenInvalid) {
+		t.Fatalf("retry after the password changed = %v, want ErrAccountTokenInvalid", err)
+	}
+}
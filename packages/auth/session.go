@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/user"
+)
+
+var (
+	// ErrSessionNotFound is returned for unknown or revoked session IDs.
+	ErrSessionNotFound = errors.New("auth: session not found")
+
+	// ErrSessionExpired is returned when a session has passed its idle or
+	// absolute expiry.
+	ErrSessionExpired = errors.New("auth: session expired")
+)
+
+// Default session lifetimes used by NewMemorySessionStore.
+const (
+	DefaultIdleTimeout = 30 * time.Minute
+	DefaultMaxLifetime = 24 * time.Hour
+)
+
+// Session binds a random ID to an authenticated user.
+type Session struct {
+	ID        string
+	User      user.User
+	CreatedAt time.Time
+	LastSeen  time.Time
+	// ExpiresAt is the earlier of the idle deadline and the absolute
+	// deadline, as of LastSeen.
+	ExpiresAt time.Time
+}
+
+// SessionStore issues and tracks login sessions.
+type SessionStore interface {
+	// Create starts a new session for u.
+	Create(u user.User) (Session, error)
+	// Get returns the session with the given ID and extends its idle
+	// deadline. It returns ErrSessionNotFound or ErrSessionExpired if the
+	// session cannot be used.
+	Get(id string) (Session, error)
+	// Revoke ends a single session.
+	Revoke(id string) error
+	// RevokeUser ends every session belonging to the user with email and
+	// returns how many were ended.
+	RevokeUser(email string) (int, error)
+	// ListByUser returns the active sessions for the user with email,
+	// oldest first.
+	ListByUser(email string) ([]Session, error)
+}
+
+// SessionOptions configures a MemorySessionStore. Zero fields take their
+// defaults.
+type SessionOptions struct {
+	// IdleTimeout is the sliding expiry: a session unused for this long
+	// expires. Each successful Get pushes the deadline forward.
+	IdleTimeout time.Duration
+	// MaxLifetime is the absolute expiry measured from creation; it is
+	// never extended.
+	MaxLifetime time.Duration
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// MemorySessionStore is an in-memory SessionStore. It is safe for
+// concurrent use.
+type MemorySessionStore struct {
+	opts SessionOptions
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+	pruned   time.Time // when sessions was last swept for expired entries
+}
+
+// NewMemorySessionStore returns an empty store configured by opts.
+func NewMemorySessionStore(opts SessionOptions) *MemorySessionStore {
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultIdleTimeout
+	}
+	if opts.MaxLifetime <= 0 {
+		opts.MaxLifetime = DefaultMaxLifetime
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &MemorySessionStore{opts: opts, sessions: make(map[string]*Session)}
+}
+
+// Create implements SessionStore.
+func (s *MemorySessionStore) Create(u user.User) (Session, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return Session{}, err
+	}
+	now := s.opts.Now()
+	sess := &Session{ID: id, User: u, CreatedAt: now}
+	s.touch(sess, now)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(now)
+	s.sessions[id] = sess
+	return *sess, nil
+}
+
+// Get implements SessionStore.
+func (s *MemorySessionStore) Get(id string) (Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return Session{}, ErrSessionNotFound
+	}
+	now := s.opts.Now()
+	if !now.Before(sess.ExpiresAt) {
+		delete(s.sessions, id)
+		return Session{}, ErrSessionExpired
+	}
+	s.touch(sess, now)
+	return *sess, nil
+}
+
+// Revoke implements SessionStore.
+func (s *MemorySessionStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[id]; !ok {
+		return ErrSessionNotFound
+	}
+	delete(s.sessions, id)
+	return nil
+}
+
+// RevokeUser implements SessionStore. Emails are compared after
+// user.NormalizeEmail.
+func (s *MemorySessionStore) RevokeUser(email string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	email = user.NormalizeEmail(email)
+	n := 0
+	for id, sess := range s.sessions {
+		if user.NormalizeEmail(sess.User.Email) == email {
+			delete(s.sessions, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// ListByUser implements SessionStore. Emails are compared after
+// user.NormalizeEmail. Expired sessions are dropped as a side effect.
+func (s *MemorySessionStore) ListByUser(email string) ([]Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.opts.Now()
+	email = user.NormalizeEmail(email)
+	var out []Session
+	for id, sess := range s.sessions {
+		if !now.Before(sess.ExpiresAt) {
+			delete(s.sessions, id)
+			continue
+		}
+		if user.NormalizeEmail(sess.User.Email) == email {
+			out = append(out, *sess)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+// prune forgets expired sessions. Without it a session that is never
+// used again stays in memory for good. It sweeps at most once per
+// IdleTimeout. The caller holds s.mu.
+func (s *MemorySessionStore) prune(now time.Time) {
+	if now.Sub(s.pruned) < s.opts.IdleTimeout {
+		return
+	}
+	s.pruned = now
+	for id, sess := range s.sessions {
+		if !now.Before(sess.ExpiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// touch records activity on sess at now and recomputes its expiry.
+func (s *MemorySessionStore) touch(sess *Session, now time.Time) {
+	sess.LastSeen = now
+	idle := now.Add(s.opts.IdleTimeout)
+	absolute := sess.CreatedAt.Add(s.opts.MaxLifetime)
+	if idle.After(absolute) {
+		idle = absolute
+	}
+	sess.ExpiresAt = idle
+}
+
+// newSessionID returns 256 bits of randomness encoded for use in URLs and
+// cookies.
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/user"
+)
+
+func newTestSessions(clock *fakeClock) *MemorySessionStore {
+	return NewMemorySessionStore(SessionOptions{IdleTimeout: 10 * time.Minute, MaxLifetime: time.Hour, Now: clock.Now})
+}
+
+func TestSessionIdleExpiry(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessions(clock)
+	sess, err := s.Create(user.User{Email: "ann@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(9 * time.Minute)
+	if _, err := s.Get(sess.ID); err != nil {
+		t.Fatalf("Get before the idle deadline: %v", err)
+	}
+	clock.Advance(9 * time.Minute) // 18 minutes in, but only 9 since the last Get
+	if _, err := s.Get(sess.ID); err != nil {
+		t.Fatalf("Get after a Get extended the deadline: %v", err)
+	}
+	clock.Advance(10 * time.Minute)
+	if _, err := s.Get(sess.ID); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("Get after idling = %v, want ErrSessionExpired", err)
+	}
+	if _, err := s.Get(sess.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("Get of a dropped session = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestSessionAbsoluteExpiry(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessions(clock)
+	sess, _ := s.Create(user.User{Email: "ann@example.com"})
+
+	for range 6 { // never idle, 54 minutes in
+		clock.Advance(9 * time.Minute)
+		if _, err := s.Get(sess.ID); err != nil {
+			t.Fatalf("Get at %v: %v", clock.Now().Sub(sess.CreatedAt), err)
+		}
+	}
+	got, _ := s.Get(sess.ID)
+	if want := sess.CreatedAt.Add(time.Hour); !got.ExpiresAt.Equal(want) {
+		t.Fatalf("ExpiresAt = %v, want the absolute deadline %v", got.ExpiresAt, want)
+	}
+	clock.Advance(6 * time.Minute)
+	if _, err := s.Get(sess.ID); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("Get past MaxLifetime = %v, want ErrSessionExpired", err)
+	}
+}
+
+func TestSessionRevocation(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessions(clock)
+	first, _ := s.Create(user.User{Email: "Ann@Example.com"})
+	clock.Advance(time.Second)
+	second, _ := s.Create(user.User{Email: "ann@example.com"})
+	other, _ := s.Create(user.User{Email: "bob@example.com"})
+
+	list, err := s.ListByUser(" ANN@example.com")
+	if err != nil || len(list) != 2 || list[0].ID != first.ID || list[1].ID != second.ID {
+		t.Fatalf("ListByUser = %v, %v; want both of ann's sessions, oldest first", list, err)
+	}
+
+	if err := s.Revoke(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(first.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("Get of a revoked session = %v, want ErrSessionNotFound", err)
+	}
+	if err := s.Revoke(first.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("second Revoke = %v, want ErrSessionNotFound", err)
+	}
+
+	first, _ = s.Create(user.User{Email: "Ann@Example.com"})
+	if n, err := s.RevokeUser("ANN@EXAMPLE.COM"); err != nil || n != 2 {
+		t.Fatalf("RevokeUser = %d, %v; want 2", n, err)
+	}
+	for _, id := range []string{first.ID, second.ID} {
+		if _, err := s.Get(id); !errors.Is(err, ErrSessionNotFound) {
+			t.Fatalf("Get after RevokeUser = %v, want ErrSessionNotFound", err)
+		}
+	}
+	if _, err := s.Get(other.ID); err != nil {
+		t.Fatalf("another user's session: %v", err)
+	}
+}
+
+func TestSessionStoreForgetsExpiredSessions(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSessions(clock)
+	for range 1000 {
+		if _, err := s.Create(user.User{Email: "ann@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock.Advance(10 * time.Minute)
+	s.Create(user.User{Email: "bob@example.com"})
+
+	s.mu.Lock()
+	n := len(s.sessions)
+	s.mu.Unlock()
+	if n != 1 {
+		t.Fatalf("%d sessions kept, want 1 (the abandoned ones have expired)", n)
+	}
+}
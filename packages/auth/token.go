@@ -0,0 +1,353 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/user"
+)
+
+// Supported token signing algorithms, named as in the JWT "alg" header.
+const (
+	HS256 = "HS256"
+	EdDSA = "EdDSA"
+)
+
+// Errors returned by TokenIssuer.
+var (
+	ErrTokenMalformed   = errors.New("auth: malformed token")
+	ErrTokenSignature   = errors.New("auth: invalid token signature")
+	ErrTokenExpired     = errors.New("auth: token expired")
+	ErrTokenNotYetValid = errors.New("auth: token not yet valid")
+	ErrTokenIssuer      = errors.New("auth: unexpected token issuer")
+	ErrTokenAudience    = errors.New("auth: unexpected token audience")
+	ErrUnsupportedAlg   = errors.New("auth: unsupported token algorithm")
+	ErrUnknownKey       = errors.New("auth: unknown signing key")
+	ErrVerifyOnlyKey    = errors.New("auth: key cannot sign")
+	ErrNoSigningKey     = errors.New("auth: no current signing key")
+	ErrInvalidKey       = errors.New("auth: invalid signing key")
+)
+
+// MinHS256SecretSize is the shortest secret NewHS256Key accepts: as many
+// bytes as the SHA-256 output, so the secret is no easier to guess than
+// the MAC itself.
+const MinHS256SecretSize = 32
+
+// DefaultTokenTTL is how long tokens from TokenIssuer.Issue stay valid.
+const DefaultTokenTTL = 15 * time.Minute
+
+// SigningKey is one entry in a TokenIssuer keyring. Build it with
+// NewHS256Key, NewEd25519Key or NewEd25519VerifyKey.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+
+	secret  []byte
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+// NewHS256Key returns an HMAC-SHA256 key. The secret must be at least
+// MinHS256SecretSize random bytes: a short one can be brute-forced offline
+// from any token.
+func NewHS256Key(id string, secret []byte) (SigningKey, error) {
+	if len(secret) < MinHS256SecretSize {
+		return SigningKey{}, fmt.Errorf("%w: HS256 secret is %d bytes, need at least %d", ErrInvalidKey, len(secret), MinHS256SecretSize)
+	}
+	return SigningKey{ID: id, Algorithm: HS256, secret: bytes.Clone(secret)}, nil
+}
+
+// NewEd25519Key returns a key that can both sign and verify.
+func NewEd25519Key(id string, private ed25519.PrivateKey) (SigningKey, error) {
+	if len(private) != ed25519.PrivateKeySize {
+		return SigningKey{}, fmt.Errorf("%w: Ed25519 private key is %d bytes, want %d", ErrInvalidKey, len(private), ed25519.PrivateKeySize)
+	}
+	return SigningKey{
+		ID:        id,
+		Algorithm: EdDSA,
+		private:   private,
+		public:    private.Public().(ed25519.PublicKey),
+	}, nil
+}
+
+// NewEd25519VerifyKey returns a key that can only verify, for services
+// that accept tokens but never mint them.
+func NewEd25519VerifyKey(id string, public ed25519.PublicKey) SigningKey {
+	return SigningKey{ID: id, Algorithm: EdDSA, public: public}
+}
+
+func (k SigningKey) sign(input []byte) ([]byte, error) {
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case EdDSA:
+		if k.private == nil {
+			return nil, ErrVerifyOnlyKey
+		}
+		return ed25519.Sign(k.private, input), nil
+	}
+	return nil, ErrUnsupportedAlg
+}
+
+func (k SigningKey) verify(input, sig []byte) bool {
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(input)
+		return hmac.Equal(sig, mac.Sum(nil))
+	case EdDSA:
+		return len(k.public) == ed25519.PublicKeySize && ed25519.Verify(k.public, input, sig)
+	}
+	return false
+}
+
+// Audience is the JWT "aud" claim, which may be encoded either as a single
+// string or as an array of strings.
+type Audience []string
+
+// MarshalJSON encodes a single audience as a plain string.
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// UnmarshalJSON accepts both the string and array forms.
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = Audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// Claims is the payload of an access token. Times are Unix seconds.
+type Claims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+
+	Email string `json:"email,omitempty"`
+	Name  string `json:"name,omitempty"`
+}
+
+// User returns the user described by the claims.
+func (c Claims) User() user.User {
+	return user.User{Email: c.Email, Name: c.Name}
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// TokenOptions configures a TokenIssuer. Zero fields take their defaults.
+type TokenOptions struct {
+	// Issuer is written to "iss" and required on verification.
+	Issuer string
+	// Audience is written to "aud"; a verified token must name at least
+	// one of these.
+	Audience []string
+	// TTL is the lifetime of issued tokens. It defaults to DefaultTokenTTL.
+	TTL time.Duration
+	// Leeway tolerates clock skew when checking "exp" and "nbf".
+	Leeway time.Duration
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// TokenIssuer signs and verifies compact JWS tokens (the JWT wire format)
+// using HS256 or EdDSA keys identified by "kid". Several keys can be
+// loaded at once so that tokens signed before a rotation stay valid until
+// the old key is removed. It is safe for concurrent use.
+type TokenIssuer struct {
+	opts TokenOptions
+
+	mu      sync.RWMutex
+	keys    map[string]SigningKey
+	current string
+}
+
+// NewTokenIssuer returns an issuer that signs with key.
+func NewTokenIssuer(opts TokenOptions, key SigningKey) *TokenIssuer {
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTokenTTL
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	t := &TokenIssuer{opts: opts, keys: make(map[string]SigningKey)}
+	t.Rotate(key)
+	return t
+}
+
+// AddKey makes key available for verification without signing with it.
+func (t *TokenIssuer) AddKey(key SigningKey) {
+	t.mu.Lock()
+	t.keys[key.ID] = key
+	t.mu.Unlock()
+}
+
+// Rotate adds key and makes it the one used for new tokens. Previously
+// added keys continue to verify.
+func (t *TokenIssuer) Rotate(key SigningKey) {
+	t.mu.Lock()
+	t.keys[key.ID] = key
+	t.current = key.ID
+	t.mu.Unlock()
+}
+
+// RemoveKey retires a key; tokens signed with it no longer verify.
+func (t *TokenIssuer) RemoveKey(id string) {
+	t.mu.Lock()
+	delete(t.keys, id)
+	if t.current == id {
+		t.current = ""
+	}
+	t.mu.Unlock()
+}
+
+// Issue returns a signed token for u carrying its email and name.
+func (t *TokenIssuer) Issue(u user.User) (string, error) {
+	now := t.opts.Now()
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	return t.Sign(Claims{
+		Issuer:    t.opts.Issuer,
+		Subject:   u.Email,
+		Audience:  t.opts.Audience,
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		ExpiresAt: now.Add(t.opts.TTL).Unix(),
+		ID:        base64.RawURLEncoding.EncodeToString(jti),
+		Email:     u.Email,
+		Name:      u.Name,
+	})
+}
+
+// Sign encodes and signs claims with the current key as-is.
+func (t *TokenIssuer) Sign(claims Claims) (string, error) {
+	t.mu.RLock()
+	key, ok := t.keys[t.current]
+	t.mu.RUnlock()
+	if !ok {
+		return "", ErrNoSigningKey
+	}
+
+	header, err := json.Marshal(tokenHeader{Alg: key.Algorithm, Typ: "JWT", Kid: key.ID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	sig, err := key.sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + enc.EncodeToString(sig), nil
+}
+
+// Verify checks the signature and the registered claims of token and
+// returns its claims. The header's "alg" must match the algorithm of the
+// key named by "kid", so "none" and algorithm-substitution tokens are
+// rejected.
+func (t *TokenIssuer) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrTokenMalformed
+	}
+	enc := base64.RawURLEncoding
+
+	rawHeader, err := enc.DecodeString(parts[0])
+	if err != nil {
+		return Claims{}, ErrTokenMalformed
+	}
+	var header tokenHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return Claims{}, ErrTokenMalformed
+	}
+	if header.Alg != HS256 && header.Alg != EdDSA {
+		return Claims{}, fmt.Errorf("%w: %q", ErrUnsupportedAlg, header.Alg)
+	}
+
+	t.mu.RLock()
+	key, ok := t.keys[header.Kid]
+	t.mu.RUnlock()
+	if !ok {
+		return Claims{}, fmt.Errorf("%w: %q", ErrUnknownKey, header.Kid)
+	}
+	if key.Algorithm != header.Alg {
+		return Claims{}, ErrTokenSignature
+	}
+
+	sig, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, ErrTokenMalformed
+	}
+	if !key.verify([]byte(parts[0]+"."+parts[1]), sig) {
+		return Claims{}, ErrTokenSignature
+	}
+
+	rawClaims, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return Claims{}, ErrTokenMalformed
+	}
+	var claims Claims
+	if err := json.Unmarshal(rawClaims, &claims); err != nil {
+		return Claims{}, ErrTokenMalformed
+	}
+	if err := t.validate(claims); err != nil {
+		return Claims{}, err
+	}
+	return claims, nil
+}
+
+func (t *TokenIssuer) validate(c Claims) error {
+	now := t.opts.Now()
+	if c.ExpiresAt == 0 || !now.Before(time.Unix(c.ExpiresAt, 0).Add(t.opts.Leeway)) {
+		return ErrTokenExpired
+	}
+	if c.NotBefore != 0 && now.Add(t.opts.Leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return ErrTokenNotYetValid
+	}
+	if t.opts.Issuer != "" && c.Issuer != t.opts.Issuer {
+		return ErrTokenIssuer
+	}
+	if len(t.opts.Audience) > 0 && !slices.ContainsFunc(c.Audience, func(aud string) bool {
+		return slices.Contains(t.opts.Audience, aud)
+	}) {
+		return ErrTokenAudience
+	}
+	return nil
+}
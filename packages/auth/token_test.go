@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/user"
+)
+
+var testHS256Secret = bytes.Repeat([]byte("k"), MinHS256SecretSize)
+
+func hs256Key(t *testing.T, id string, secret []byte) SigningKey {
+	t.Helper()
+	key, err := NewHS256Key(id, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func ed25519Key(t *testing.T, id string, private ed25519.PrivateKey) SigningKey {
+	t.Helper()
+	key, err := NewEd25519Key(id, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newTestIssuer(t *testing.T, clock *fakeClock, key SigningKey) *TokenIssuer {
+	t.Helper()
+	return NewTokenIssuer(TokenOptions{Issuer: "shop", Audience: []string{"api"}, TTL: time.Hour, Now: clock.Now}, key)
+}
+
+// forge builds a token with any header and claims, signed by sign (nil
+// leaves the signature empty), as an attacker would.
+func forge(t *testing.T, header tokenHeader, claims Claims, sign func(input []byte) []byte) string {
+	t.Helper()
+	enc := base64.RawURLEncoding
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	input := enc.EncodeToString(h) + "." + enc.EncodeToString(c)
+	var sig []byte
+	if sign != nil {
+		sig = sign([]byte(input))
+	}
+	return input + "." + enc.EncodeToString(sig)
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	_, private, _ := ed25519.GenerateKey(nil)
+	keys := map[string]SigningKey{
+		HS256: hs256Key(t, "hs", testHS256Secret),
+		EdDSA: ed25519Key(t, "ed", private),
+	}
+	for alg, key := range keys {
+		t.Run(alg, func(t *testing.T) {
+			tokens := newTestIssuer(t, newFakeClock(), key)
+			token, err := tokens.Issue(user.User{Email: "ann@example.com", Name: "Ann"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := tokens.Verify(token)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if claims.Subject != "ann@example.com" || claims.Name != "Ann" || claims.Issuer != "shop" {
+				t.Fatalf("claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestTokenVerifyRejects(t *testing.T) {
+	clock := newFakeClock()
+	_, private, _ := ed25519.GenerateKey(nil)
+	edKey := ed25519Key(t, "ed", private)
+	tokens := newTestIssuer(t, clock, edKey)
+	now := clock.Now().Unix()
+	valid := Claims{Issuer: "shop", Audience: Audience{"api"}, Subject: "ann@example.com", NotBefore: now, ExpiresAt: now + 60}
+	signed := func(c Claims) string {
+		token, err := tokens.Sign(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	with := func(change func(*Claims)) Claims {
+		c := valid
+		change(&c)
+		return c
+	}
+	public := private.Public().(ed25519.PublicKey)
+	hmacWithPublicKey := func(input []byte) []byte {
+		return hs256Key(t, "ed", public).mustSign(t, input)
+	}
+	tampered := signed(valid)
+	parts := strings.Split(tampered, ".")
+	admin, _ := json.Marshal(with(func(c *Claims) { c.Subject = "admin@example.com" }))
+	parts[1] = base64.RawURLEncoding.EncodeToString(admin)
+	tampered = strings.Join(parts, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"alg none", forge(t, tokenHeader{Alg: "none", Kid: "ed"}, valid, nil), ErrUnsupportedAlg},
+		{"alg none, no kid", forge(t, tokenHeader{Alg: "none"}, valid, nil), ErrUnsupportedAlg},
+		{"HS256 signed with the Ed25519 public key", forge(t, tokenHeader{Alg: HS256, Kid: "ed"}, valid, hmacWithPublicKey), ErrTokenSignature},
+		{"payload swapped after signing", tampered, ErrTokenSignature},
+		{"unknown kid", forge(t, tokenHeader{Alg: EdDSA, Kid: "other"}, valid, func(in []byte) []byte { return ed25519.Sign(private, in) }), ErrUnknownKey},
+		{"expired", signed(with(func(c *Claims) { c.ExpiresAt = now })), ErrTokenExpired},
+		{"no expiry", signed(with(func(c *Claims) { c.ExpiresAt = 0 })), ErrTokenExpired},
+		{"not yet valid", signed(with(func(c *Claims) { c.NotBefore = now + 1 })), ErrTokenNotYetValid},
+		{"wrong issuer", signed(with(func(c *Claims) { c.Issuer = "elsewhere" })), ErrTokenIssuer},
+		{"wrong audience", signed(with(func(c *Claims) { c.Audience = Audience{"admin"} })), ErrTokenAudience},
+		{"no audience", signed(with(func(c *Claims) { c.Audience = nil })), ErrTokenAudience},
+		{"two parts", "a.b", ErrTokenMalformed},
+		{"bad base64", "!!.!!.!!", ErrTokenMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tokens.Verify(tt.token); !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if _, err := tokens.Verify(signed(valid)); err != nil {
+		t.Fatalf("the untouched token does not verify: %v", err)
+	}
+}
+
+func (k SigningKey) mustSign(t *testing.T, input []byte) []byte {
+	t.Helper()
+	sig, err := k.sign(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func TestTokenKeyRotation(t *testing.T) {
+	clock := newFakeClock()
+	old := hs256Key(t, "2025-01", testHS256Secret)
+	tokens := newTestIssuer(t, clock, old)
+	before, _ := tokens.Issue(user.User{Email: "ann@example.com"})
+
+	tokens.Rotate(hs256Key(t, "2025-02", bytes.Repeat([]byte("n"), MinHS256SecretSize)))
+	after, _ := tokens.Issue(user.User{Email: "ann@example.com"})
+	for name, token := range map[string]string{"old key": before, "new key": after} {
+		if _, err := tokens.Verify(token); err != nil {
+			t.Errorf("%s after rotation: %v", name, err)
+		}
+	}
+
+	tokens.RemoveKey("2025-01")
+	if _, err := tokens.Verify(before); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("token of a removed key = %v, want ErrUnknownKey", err)
+	}
+	if _, err := tokens.Verify(after); err != nil {
+		t.Fatalf("new key after removing the old one: %v", err)
+	}
+	tokens.RemoveKey("2025-02")
+	if _, err := tokens.Issue(user.User{Email: "ann@example.com"}); !errors.Is(err, ErrNoSigningKey) {
+		t.Fatalf("Issue without a current key = %v, want ErrNoSigningKey", err)
+	}
+}
+
+func TestNewKeysRejectBadMaterial(t *testing.T) {
+	_, private, _ := ed25519.GenerateKey(nil)
+	tests := []struct {
+		name string
+		err  func() error
+	}{
+		{"empty HS256 secret", func() error { _, err := NewHS256Key("k", nil); return err }},
+		{"short HS256 secret", func() error { _, err := NewHS256Key("k", testHS256Secret[:MinHS256SecretSize-1]); return err }},
+		{"nil Ed25519 key", func() error { _, err := NewEd25519Key("k", nil); return err }},
+		{"short Ed25519 key", func() error { _, err := NewEd25519Key("k", private[:ed25519.SeedSize]); return err }},
+	}
+	for _, tt := range tests {
+		if err := tt.err(); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("%s: err = %v, want ErrInvalidKey", tt.name, err)
+		}
+	}
+	if _, err := NewHS256Key("k", testHS256Secret); err != nil {
+		t.Errorf("32-byte HS256 secret: %v", err)
+	}
+}
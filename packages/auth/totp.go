@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HMAC algorithms accepted in TOTPOptions.Algorithm. SHA1 is what almost
+// every authenticator app expects.
+const (
+	OTPSHA1   = "SHA1"
+	OTPSHA256 = "SHA256"
+	OTPSHA512 = "SHA512"
+)
+
+// Defaults used by NewTOTPVerifier.
+const (
+	DefaultOTPDigits = 6
+	DefaultOTPPeriod = 30 * time.Second
+	DefaultOTPSkew   = 1
+
+	// MaxOTPDigits is the longest code a TOTPVerifier issues. The truncated
+	// HMAC is a 31-bit number, so longer codes would only add leading
+	// zeros.
+	MaxOTPDigits = 9
+)
+
+var (
+	// ErrInvalidOTP is returned when a one-time code is wrong or outside
+	// the accepted window.
+	ErrInvalidOTP = errors.New("auth: invalid one-time code")
+
+	// ErrOTPReplayed is returned when a code that was already accepted is
+	// presented again.
+	ErrOTPReplayed = errors.New("auth: one-time code already used")
+
+	// ErrSecondFactorRequired is returned by Login when the user has
+	// two-factor authentication enrolled but no code was supplied.
+	ErrSecondFactorRequired = errors.New("auth: second factor required")
+)
+
+var otpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// HOTP returns the RFC 4226 HMAC-SHA1 one-time password for counter.
+func HOTP(secret []byte, counter uint64, digits int) string {
+	return hotp(sha1.New, secret, counter, digits)
+}
+
+func hotp(h func() hash.Hash, secret []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(h, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, RFC 4226 section 5.3.
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint64(1)
+	for range min(digits, 19) { // 10^19 is the largest power of ten in a uint64
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, uint64(code)%mod)
+}
+
+// GenerateTOTPSecret returns a new random 160-bit secret.
+func GenerateTOTPSecret() ([]byte, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// EncodeTOTPSecret returns secret in the unpadded base32 form users type
+// into authenticator apps.
+func EncodeTOTPSecret(secret []byte) string {
+	return otpEncoding.EncodeToString(secret)
+}
+
+// DecodeTOTPSecret parses the output of EncodeTOTPSecret.
+func DecodeTOTPSecret(s string) ([]byte, error) {
+	return otpEncoding.DecodeString(s)
+}
+
+// TOTPOptions configures a TOTPVerifier. Zero fields take their defaults.
+type TOTPOptions struct {
+	// Digits is the code length, normally 6 or 8, and at most MaxOTPDigits.
+	Digits int
+	// Period is the time step, a whole number of seconds.
+	Period time.Duration
+	// Skew is how many steps either side of the current one are accepted
+	// to tolerate clock drift between server and device. Zero means
+	// DefaultOTPSkew; a negative value accepts only the current step.
+	Skew int
+	// Algorithm is one of OTPSHA1, OTPSHA256 or OTPSHA512.
+	Algorithm string
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// TOTPVerifier checks RFC 6238 time-based codes and remembers the last
+// accepted time step per account so a code cannot be used twice. It is
+// safe for concurrent use.
+type TOTPVerifier struct {
+	opts TOTPOptions
+	hash func() hash.Hash
+
+	mu       sync.Mutex
+	lastUsed map[string]uint64
+}
+
+// NewTOTPVerifier returns a verifier configured by opts. It panics if
+// opts.Algorithm is not supported, opts.Digits is above MaxOTPDigits or
+// opts.Period is not a whole number of seconds.
+func NewTOTPVerifier(opts TOTPOptions) *TOTPVerifier {
+	if opts.Digits <= 0 {
+		opts.Digits = DefaultOTPDigits
+	}
+	if opts.Period <= 0 {
+		opts.Period = DefaultOTPPeriod
+	}
+	if opts.Skew < 0 {
+		opts.Skew = 0
+	} else if opts.Skew == 0 {
+		opts.Skew = DefaultOTPSkew
+	}
+	if opts.Algorithm == "" {
+		opts.Algorithm = OTPSHA1
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.Digits > MaxOTPDigits {
+		panic(fmt.Sprintf("auth: TOTP codes cannot be longer than %d digits, got %d", MaxOTPDigits, opts.Digits))
+	}
+	if opts.Period < time.Second || opts.Period%time.Second != 0 {
+		panic(fmt.Sprintf("auth: TOTP period must be a whole number of seconds, got %s", opts.Period))
+	}
+	v := &TOTPVerifier{opts: opts, lastUsed: make(map[string]uint64)}
+	switch opts.Algorithm {
+	case OTPSHA1:
+		v.hash = sha1.New
+	case OTPSHA256:
+		v.hash = sha256.New
+	case OTPSHA512:
+		v.hash = sha512.New
+	default:
+		panic("auth: unsupported TOTP algorithm " + opts.Algorithm)
+	}
+	return v
+}
+
+// Code returns the code for secret at time t.
+func (v *TOTPVerifier) Code(secret []byte, t time.Time) string {
+	return hotp(v.hash, secret, v.step(t), v.opts.Digits)
+}
+
+// Verify checks code for the given account and secret. A code is accepted
+// if it matches any step within the skew window that is later than the
+// last step accepted for the account.
+func (v *TOTPVerifier) Verify(account string, secret []byte, code string) error {
+	if len(code) != v.opts.Digits {
+		return ErrInvalidOTP
+	}
+	if _, err := strconv.ParseUint(code, 10, 64); err != nil {
+		return ErrInvalidOTP
+	}
+
+	now := v.step(v.opts.Now())
+	var matched uint64
+	found := false
+	for d := -v.opts.Skew; d <= v.opts.Skew; d++ {
+		step := int64(now) + int64(d)
+		if step < 0 {
+			continue
+		}
+		want := hotp(v.hash, secret, uint64(step), v.opts.Digits)
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			matched, found = uint64(step), true
+		}
+	}
+	if !found {
+		return ErrInvalidOTP
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if last, ok := v.lastUsed[account]; ok && matched <= last {
+		return ErrOTPReplayed
+	}
+	v.lastUsed[account] = matched
+	return nil
+}
+
+// ProvisioningURI returns the otpauth:// URI for enrolling secret in an
+// authenticator app, usually rendered as a QR code.
+func (v *TOTPVerifier) ProvisioningURI(issuer, account string, secret []byte) string {
+	q := url.Values{}
+	q.Set("secret", EncodeTOTPSecret(secret))
+	q.Set("issuer", issuer)
+	q.Set("algorithm", v.opts.Algorithm)
+	q.Set("digits", strconv.Itoa(v.opts.Digits))
+	q.Set("period", strconv.Itoa(int(v.opts.Period/time.Second)))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
+
+func (v *TOTPVerifier) step(t time.Time) uint64 {
+	return uint64(t.Unix()) / uint64(v.opts.Period/time.Second)
+}
+
+// SecondFactorStore reports whether a user, identified by email, has
+// enrolled a TOTP secret.
+type SecondFactorStore interface {
+	TOTPSecret(email string) (secret []byte, enrolled bool, err error)
+}
+
+// MemorySecondFactors is an in-memory SecondFactorStore. It is safe for
+// concurrent use.
+type MemorySecondFactors struct {
+	mu      sync.RWMutex
+	secrets map[string][]byte
+}
+
+// NewMemorySecondFactors returns an empty MemorySecondFactors.
+func NewMemorySecondFactors() *MemorySecondFactors {
+	return &MemorySecondFactors{secrets: make(map[string][]byte)}
+}
+
+// Enroll turns on two-factor authentication for email.
+func (m *MemorySecondFactors) Enroll(email string, secret []byte) {
+	m.mu.Lock()
+	m.secrets[email] = secret
+	m.mu.Unlock()
+}
+
+// Unenroll turns two-factor authentication off for email.
+func (m *MemorySecondFactors) Unenroll(email string) {
+	m.mu.Lock()
+	delete(m.secrets, email)
+	m.mu.Unlock()
+}
+
+// TOTPSecret implements SecondFactorStore.
+func (m *MemorySecondFactors) TOTPSecret(email string) ([]byte, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	secret, ok := m.secrets[email]
+	return secret, ok, nil
+}
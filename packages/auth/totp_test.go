@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHOTPKnownVectors(t *testing.T) {
+	// RFC 4226 appendix D.
+	secret := []byte("12345678901234567890")
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		if got := HOTP(secret, uint64(counter), 6); got != code {
+			t.Errorf("HOTP(counter %d) = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestTOTPKnownVectors(t *testing.T) {
+	// RFC 6238 appendix B. Each algorithm uses an ASCII seed as long as its
+	// digest.
+	seeds := map[string][]byte{
+		OTPSHA1:   []byte("12345678901234567890"),
+		OTPSHA256: []byte("12345678901234567890123456789012"),
+		OTPSHA512: []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	tests := []struct {
+		unix int64
+		want map[string]string
+	}{
+		{59, map[string]string{OTPSHA1: "94287082", OTPSHA256: "46119246", OTPSHA512: "90693936"}},
+		{1111111109, map[string]string{OTPSHA1: "07081804", OTPSHA256: "68084774", OTPSHA512: "25091201"}},
+		{1111111111, map[string]string{OTPSHA1: "14050471", OTPSHA256: "67062674", OTPSHA512: "99943326"}},
+		{1234567890, map[string]string{OTPSHA1: "89005924", OTPSHA256: "91819424", OTPSHA512: "93441116"}},
+		{2000000000, map[string]string{OTPSHA1: "69279037", OTPSHA256: "90698825", OTPSHA512: "38618901"}},
+		{20000000000, map[string]string{OTPSHA1: "65353130", OTPSHA256: "77737706", OTPSHA512: "47863826"}},
+	}
+	for _, alg := range []string{OTPSHA1, OTPSHA256, OTPSHA512} {
+		v := NewTOTPVerifier(TOTPOptions{Digits: 8, Algorithm: alg})
+		for _, tt := range tests {
+			if got := v.Code(seeds[alg], time.Unix(tt.unix, 0)); got != tt.want[alg] {
+				t.Errorf("%s at %d = %s, want %s", alg, tt.unix, got, tt.want[alg])
+			}
+		}
+	}
+}
+
+func TestTOTPVerify(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	at := time.Unix(1111111111, 0)
+	code := NewTOTPVerifier(TOTPOptions{}).Code(secret, at)
+	previous := NewTOTPVerifier(TOTPOptions{}).Code(secret, at.Add(-DefaultOTPPeriod))
+
+	tests := []struct {
+		name  string
+		skew  int
+		now   time.Time
+		codes []string // presented in order; all but the last must be accepted
+		want  error
+	}{
+		{"current step", 0, at, []string{code}, nil},
+		{"one step late within skew", 1, at.Add(DefaultOTPPeriod), []string{code}, nil},
+		{"one step late without skew", -1, at.Add(DefaultOTPPeriod), []string{code}, ErrInvalidOTP},
+		{"two steps late", 1, at.Add(2 * DefaultOTPPeriod), []string{code}, ErrInvalidOTP},
+		{"replayed", 1, at, []string{code, code}, ErrOTPReplayed},
+		{"older than the last accepted", 1, at, []string{code, previous}, ErrOTPReplayed},
+		{"wrong length", 1, at, []string{code[:5]}, ErrInvalidOTP},
+		{"not digits", 1, at, []string{"12345a"}, ErrInvalidOTP},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := tt.now
+			v := NewTOTPVerifier(TOTPOptions{Skew: tt.skew, Now: func() time.Time { return now }})
+			last := len(tt.codes) - 1
+			for _, c := range tt.codes[:last] {
+				if err := v.Verify("ann", secret, c); err != nil {
+					t.Fatalf("Verify(%s) = %v", c, err)
+				}
+			}
+			if err := v.Verify("ann", secret, tt.codes[last]); !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTOTPVerifierRejectsBadOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts TOTPOptions
+	}{
+		{"sub-second period", TOTPOptions{Period: 500 * time.Millisecond}},
+		{"fractional period", TOTPOptions{Period: 1500 * time.Millisecond}},
+		{"too many digits", TOTPOptions{Digits: MaxOTPDigits + 1}},
+		{"unknown algorithm", TOTPOptions{Algorithm: "MD5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("NewTOTPVerifier did not panic")
+				}
+			}()
+			NewTOTPVerifier(tt.opts)
+		})
+	}
+}
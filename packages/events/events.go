@@ -0,0 +1,472 @@
+// Package events is an in-process event bus.
+//
+// Code that changes something publishes a typed event ("order ORD-1 moved
+// from confirmed to prepared"); any number of subscribers react to it
+// without the publisher knowing they exist. Subscribers are either
+// synchronous, run inside Publish, or asynchronous, run on their own
+// goroutine. A subscriber that fails or panics never affects the others.
+//
+// With an Outbox, every event is written down BEFORE it is delivered and
+// crossed off per subscriber once that subscriber has handled it. After a
+// crash, Redeliver hands the events that were not fully handled to the
+// subscribers that missed them. Delivery is therefore at-least-once:
+// subscribers must tolerate seeing an event twice.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/golang/money"
+)
+
+var (
+	// ErrDuplicateSubscriber is returned by Subscribe for a name already in
+	// use. Names identify subscribers in the outbox, so they must be unique.
+	ErrDuplicateSubscriber = errors.New("events: subscriber already registered")
+
+	// ErrUnknownEventType is returned when decoding an event whose type
+	// this package does not know.
+	ErrUnknownEventType = errors.New("events: unknown event type")
+
+	// ErrClosed is returned by Publish and Subscribe after Close.
+	ErrClosed = errors.New("events: bus closed")
+)
+
+// Event is something that happened. EventType names it, e.g.
+// "order.created"; it is stored in the outbox to decode the event again.
+type Event interface {
+	EventType() string
+}
+
+// OrderCreated is published when a new order is created.
+type OrderCreated struct {
+	OrderID  string `json:"order_id"`
+	Customer string `json:"customer,omitempty"`
+}
+
+// StatusChanged is published when an order moves to a new status.
+type StatusChanged struct {
+	OrderID string `json:"order_id"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Actor   string `json:"actor,omitempty"` // who made the change
+}
+
+// PaymentCaptured is published when a charge has been captured.
+type PaymentCaptured struct {
+	ChargeID    string      `json:"charge_id"`
+	Gateway     string      `json:"gateway"`
+	Amount      money.Money `json:"amount"`
+	Description string      `json:"description,omitempty"`
+}
+
+func (OrderCreated) EventType() string    { return "order.created" }
+func (StatusChanged) EventType() string   { return "order.status_changed" }
+func (PaymentCaptured) EventType() string { return "payment.captured" }
+
+// decode rebuilds an event of type typ from its JSON payload.
+func decode(typ string, payload []byte) (Event, error) {
+	switch typ {
+	case OrderCreated{}.EventType():
+		return decodeAs[OrderCreated](payload)
+	case StatusChanged{}.EventType():
+		return decodeAs[StatusChanged](payload)
+	case PaymentCaptured{}.EventType():
+		return decodeAs[PaymentCaptured](payload)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownEventType, typ)
+}
+
+func decodeAs[E Event](payload []byte) (Event, error) {
+	var e E
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Envelope is an event as the bus stores and delivers it.
+type Envelope struct {
+	ID    string    // unique, assigned by Publish
+	Time  time.Time // when it was published
+	Event Event
+	Acked []string // subscribers that have handled it, in the outbox
+}
+
+type envelopeJSON struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Time    time.Time       `json:"time"`
+	Payload json.RawMessage `json:"payload"`
+	Acked   []string        `json:"acked,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. The event's type is written next
+// to it so UnmarshalJSON knows what to decode.
+func (env Envelope) MarshalJSON() ([]byte, error) {
+	payload, err := json.Marshal(env.Event)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelopeJSON{env.ID, env.Event.EventType(), env.Time, payload, env.Acked})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (env *Envelope) UnmarshalJSON(data []byte) error {
+	var raw envelopeJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	e, err := decode(raw.Type, raw.Payload)
+	if err != nil {
+		return err
+	}
+	*env = Envelope{ID: raw.ID, Time: raw.Time, Event: e, Acked: raw.Acked}
+	return nil
+}
+
+// Handler handles one event. A non-nil error (or a panic) counts as a
+// failed delivery: it is reported to Options.OnError and, with an outbox,
+// the event stays pending for that subscriber.
+type Handler func(ctx context.Context, env Envelope) error
+
+// On adapts a handler for one event type to a Handler; events of other
+// types are ignored (and count as handled).
+func On[E Event](h func(ctx context.Context, e E, env Envelope) error) Handler {
+	return func(ctx context.Context, env Envelope) error {
+		e, ok := env.Event.(E)
+		if !ok {
+			return nil
+		}
+		return h(ctx, e, env)
+	}
+}
+
+// Options configures a Bus.
+type Options struct {
+	// Outbox records events until every subscriber has handled them. Nil
+	// means events are only delivered once, from memory.
+	Outbox Outbox
+
+	// OnError is told about every failed delivery, and about events that
+	// could not be written to the outbox (subscriber is then ""). Nil
+	// ignores failures.
+	OnError func(subscriber string, env Envelope, err error)
+
+	// QueueSize is the number of events an asynchronous subscriber can
+	// have waiting before Publish blocks. It defaults to 64.
+	QueueSize int
+
+	// Now stamps published events. It defaults to time.Now.
+	Now func() time.Time
+}
+
+type subscriber struct {
+	name    string
+	handler Handler
+	queue   chan delivery // nil for synchronous subscribers
+}
+
+type delivery struct {
+	ctx context.Context
+	env Envelope
+}
+
+// Bus delivers events to subscribers. It is safe for concurrent use.
+// A nil *Bus is valid: publishing to it does nothing.
+type Bus struct {
+	opts Options
+
+	mu         sync.RWMutex
+	subs       []*subscriber
+	closed     bool
+	publishing sync.WaitGroup // Publish and Redeliver calls still dispatching
+	wg         sync.WaitGroup // asynchronous subscriber goroutines
+
+	// waiting is updated by subscribers while dispatching, which happens
+	// without mu, so it has a lock of its own.
+	waitMu  sync.Mutex
+	waiting map[string]*flight // event ID → its delivery in progress
+}
+
+// flight tracks one delivery of an event to a set of subscribers. While an
+// event has a flight, Redeliver leaves it alone.
+type flight struct {
+	left   int  // subscribers yet to handle it
+	failed bool // a subscriber failed: the event stays pending
+}
+
+// New returns a Bus with no subscribers.
+func New(opts Options) *Bus {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 64
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Bus{opts: opts, waiting: make(map[string]*flight)}
+}
+
+// Subscribe registers a synchronous subscriber: Publish calls h before it
+// returns, in the order subscribers were registered.
+func (b *Bus) Subscribe(name string, h Handler) error {
+	return b.subscribe(&subscriber{name: name, handler: h})
+}
+
+// SubscribeAsync registers an asynchronous subscriber: h runs on a
+// goroutine of its own, one event at a time, in publishing order.
+func (b *Bus) SubscribeAsync(name string, h Handler) error {
+	s := &subscriber{name: name, handler: h, queue: make(chan delivery, b.opts.QueueSize)}
+	if err := b.subscribe(s); err != nil {
+		return err
+	}
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for d := range s.queue {
+			b.deliver(d.ctx, s, d.env)
+		}
+	}()
+	return nil
+}
+
+func (b *Bus) subscribe(s *subscriber) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+	if slices.ContainsFunc(b.subs, func(o *subscriber) bool { return o.name == s.name }) {
+		return fmt.Errorf("%w: %s", ErrDuplicateSubscriber, s.name)
+	}
+	b.subs = append(b.subs, s)
+	return nil
+}
+
+// Publish records e in the outbox, if there is one, and delivers it to
+// every subscriber. It returns an error only if e could not be recorded;
+// subscriber failures go to Options.OnError and never reach the caller.
+func (b *Bus) Publish(ctx context.Context, e Event) error {
+	if b == nil {
+		return nil
+	}
+	env := Envelope{ID: newID(), Time: b.opts.Now(), Event: e}
+	subs, err := b.begin()
+	if err != nil {
+		return err
+	}
+	defer b.publishing.Done()
+
+	if b.opts.Outbox != nil {
+		// The flight starts before the event is in the outbox, so a
+		// concurrent Redeliver never sees it pending without one.
+		b.claim(env.ID, len(subs))
+		if err := b.opts.Outbox.Append(env); err != nil {
+			b.waitMu.Lock()
+			delete(b.waiting, env.ID)
+			b.waitMu.Unlock()
+			err = fmt.Errorf("events: recording %s: %w", e.EventType(), err)
+			b.report("", env, err)
+			return err
+		}
+	}
+	b.dispatch(ctx, env, subs)
+	return nil
+}
+
+// begin snapshots the subscribers for a Publish or Redeliver, which must
+// call b.publishing.Done when it has dispatched. Handlers then run without
+// b.mu held, so they may publish or subscribe themselves.
+func (b *Bus) begin() ([]*subscriber, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return nil, ErrClosed
+	}
+	b.publishing.Add(1) // Close waits for this before closing the queues
+	return slices.Clone(b.subs), nil
+}
+
+// Redeliver hands every event still pending in the outbox to the
+// subscribers that have not handled it yet. Call it after registering
+// subscribers on start-up, and from time to time to retry failures.
+func (b *Bus) Redeliver(ctx context.Context) error {
+	if b.opts.Outbox == nil {
+		return nil
+	}
+	pending, err := b.opts.Outbox.Pending()
+	if err != nil {
+		return err
+	}
+	subs, err := b.begin()
+	if err != nil {
+		return err
+	}
+	defer b.publishing.Done()
+
+	for _, env := range pending {
+		var missed []*subscriber
+		for _, s := range subs {
+			if !slices.Contains(env.Acked, s.name) {
+				missed = append(missed, s)
+			}
+		}
+		if !b.claim(env.ID, len(missed)) {
+			continue // still being delivered
+		}
+		b.dispatch(ctx, env, missed)
+	}
+	return nil
+}
+
+// claim starts a flight of env to n subscribers. It reports false, and
+// changes nothing, if env is already in flight.
+func (b *Bus) claim(id string, n int) bool {
+	b.waitMu.Lock()
+	defer b.waitMu.Unlock()
+	if _, busy := b.waiting[id]; busy {
+		return false
+	}
+	b.waiting[id] = &flight{left: n}
+	return true
+}
+
+// dispatch delivers env to subs. With an outbox, the caller has claimed env.
+func (b *Bus) dispatch(ctx context.Context, env Envelope, subs []*subscriber) {
+	if b.opts.Outbox != nil && len(subs) == 0 {
+		b.complete(env) // going to nobody: complete straight away
+		return
+	}
+	for _, s := range subs {
+		if s.queue == nil {
+			b.deliver(ctx, s, env)
+			continue
+		}
+		// Asynchronous subscribers outlive the publisher's request, so they
+		// keep its values but not its cancellation.
+		select {
+		case s.queue <- delivery{context.WithoutCancel(ctx), env}:
+		case <-ctx.Done():
+			b.report(s.name, env, ctx.Err())
+			b.settle(env, false)
+		}
+	}
+}
+
+// deliver runs one subscriber's handler for env, isolating the others
+// from its errors and panics.
+func (b *Bus) deliver(ctx context.Context, s *subscriber, env Envelope) {
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("events: subscriber panicked: %v", r)
+			}
+		}()
+		return s.handler(ctx, env)
+	}()
+	if err != nil {
+		b.report(s.name, env, err)
+		b.settle(env, false)
+		return
+	}
+	b.ack(s.name, env)
+}
+
+// ack crosses subscriber off env in the outbox.
+func (b *Bus) ack(subscriber string, env Envelope) {
+	if b.opts.Outbox == nil {
+		return
+	}
+	if err := b.opts.Outbox.Ack(env.ID, subscriber); err != nil {
+		b.report(subscriber, env, err)
+		b.settle(env, false)
+		return
+	}
+	b.settle(env, true)
+}
+
+// settle counts one subscriber's outcome for env's flight. When the last
+// one is in, env is completed if all of them handled it; otherwise the
+// flight ends and env stays pending for Redeliver.
+func (b *Bus) settle(env Envelope, ok bool) {
+	if b.opts.Outbox == nil {
+		return
+	}
+	b.waitMu.Lock()
+	f := b.waiting[env.ID]
+	if f == nil {
+		b.waitMu.Unlock()
+		return
+	}
+	f.left--
+	f.failed = f.failed || !ok
+	if f.left > 0 {
+		b.waitMu.Unlock()
+		return
+	}
+	if f.failed {
+		delete(b.waiting, env.ID)
+		b.waitMu.Unlock()
+		return
+	}
+	b.waitMu.Unlock()
+	b.complete(env)
+}
+
+// complete removes env from the outbox, then ends its flight. Until the
+// outbox has it gone, Redeliver must not pick it up again.
+func (b *Bus) complete(env Envelope) {
+	if err := b.opts.Outbox.Complete(env.ID); err != nil {
+		b.report("", env, err)
+	}
+	b.waitMu.Lock()
+	delete(b.waiting, env.ID)
+	b.waitMu.Unlock()
+}
+
+func (b *Bus) report(subscriber string, env Envelope, err error) {
+	if b.opts.OnError != nil {
+		b.opts.OnError(subscriber, env, err)
+	}
+}
+
+// Close stops accepting events, waits for asynchronous subscribers to
+// handle everything already queued, and closes the outbox. It must not be
+// called from a synchronous subscriber, which would wait for itself.
+func (b *Bus) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrClosed
+	}
+	b.closed = true
+	subs := b.subs
+	b.mu.Unlock()
+
+	b.publishing.Wait() // nothing may be sent on a queue once it is closed
+	for _, s := range subs {
+		if s.queue != nil {
+			close(s.queue)
+		}
+	}
+	b.wg.Wait()
+	if b.opts.Outbox != nil {
+		return b.opts.Outbox.Close()
+	}
+	return nil
+}
+
+// newID returns a random event ID such as "evt_3f9c…".
+func newID() string {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return "evt_" + hex.EncodeToString(buf)
+}
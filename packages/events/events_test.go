@@ -0,0 +1,192 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failures collects what OnError is told.
+type failures struct {
+	mu   sync.Mutex
+	subs []string
+}
+
+func (f *failures) onError(subscriber string, _ Envelope, _ error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subs = append(f.subs, subscriber)
+}
+
+func (f *failures) list() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.subs...)
+}
+
+// within fails the test if fn does not return in time, e.g. because of a
+// deadlock.
+func within(t *testing.T, what string, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("%s did not return", what)
+	}
+}
+
+func TestPublishIsolatesSubscribers(t *testing.T) {
+	var failed failures
+	outbox := NewMemoryOutbox()
+	bus := New(Options{Outbox: outbox, OnError: failed.onError})
+	var got []string
+	bus.Subscribe("ok", func(ctx context.Context, env Envelope) error {
+		got = append(got, env.Event.(OrderCreated).OrderID)
+		return nil
+	})
+	bus.Subscribe("fails", func(context.Context, Envelope) error { return errors.New("boom") })
+	bus.Subscribe("panics", func(context.Context, Envelope) error { panic("boom") })
+	bus.Subscribe("also ok", On(func(ctx context.Context, e StatusChanged, _ Envelope) error { return nil }))
+
+	if err := bus.Publish(context.Background(), OrderCreated{OrderID: "ORD-1"}); err != nil {
+		t.Fatalf("Publish = %v, want nil: subscriber failures are not the publisher's", err)
+	}
+	if len(got) != 1 || got[0] != "ORD-1" {
+		t.Fatalf("ok subscriber got %v", got)
+	}
+	if f := failed.list(); len(f) != 2 || f[0] != "fails" || f[1] != "panics" {
+		t.Fatalf("OnError told about %v, want [fails panics]", f)
+	}
+	pending, _ := outbox.Pending()
+	if len(pending) != 1 || len(pending[0].Acked) != 2 {
+		t.Fatalf("pending = %+v, want the event acked by the two good subscribers", pending)
+	}
+}
+
+func TestHandlersRunWithoutTheBusLocked(t *testing.T) {
+	bus := New(Options{})
+	bus.Subscribe("subscribes", func(ctx context.Context, env Envelope) error {
+		return bus.Subscribe("late", func(context.Context, Envelope) error { return nil })
+	})
+	within(t, "Publish with a handler that subscribes", func() {
+		bus.Publish(context.Background(), OrderCreated{OrderID: "ORD-1"})
+	})
+
+	// A publisher blocked on a full queue does not block Subscribe either.
+	release := make(chan struct{})
+	slow := New(Options{QueueSize: 1})
+	slow.SubscribeAsync("slow", func(context.Context, Envelope) error {
+		<-release
+		return nil
+	})
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for range 3 { // one in the handler, one queued, one blocked
+			slow.Publish(context.Background(), OrderCreated{OrderID: "ORD-1"})
+		}
+	}()
+	time.Sleep(10 * time.Millisecond) // let the third Publish block
+	within(t, "Subscribe while a Publish waits on a full queue", func() {
+		slow.Subscribe("other", func(context.Context, Envelope) error { return nil })
+	})
+	close(release)
+	<-published
+	within(t, "Close", func() { slow.Close() })
+}
+
+func TestRedeliver(t *testing.T) {
+	outbox := NewMemoryOutbox()
+	bus := New(Options{Outbox: outbox})
+	fail := true
+	calls := map[string]int{}
+	bus.Subscribe("flaky", func(context.Context, Envelope) error {
+		calls["flaky"]++
+		if fail {
+			return errors.New("down")
+		}
+		return nil
+	})
+	bus.Subscribe("steady", func(context.Context, Envelope) error {
+		calls["steady"]++
+		return nil
+	})
+
+	bus.Publish(context.Background(), OrderCreated{OrderID: "ORD-1"})
+	fail = false
+	if err := bus.Redeliver(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls["flaky"] != 2 || calls["steady"] != 1 {
+		t.Fatalf("calls = %v, want flaky retried and steady left alone", calls)
+	}
+	if pending, _ := outbox.Pending(); len(pending) != 0 {
+		t.Fatalf("%d events pending after everyone handled them", len(pending))
+	}
+}
+
+func TestRedeliverSkipsEventsInFlight(t *testing.T) {
+	outbox := NewMemoryOutbox()
+	bus := New(Options{Outbox: outbox})
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	var mu sync.Mutex
+	calls := 0
+	bus.SubscribeAsync("mailer", func(context.Context, Envelope) error {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+
+	bus.Publish(context.Background(), OrderCreated{OrderID: "ORD-1"})
+	<-started // the mailer is handling it: pending, but in flight
+	if err := bus.Redeliver(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	bus.Close()
+	if calls != 1 {
+		t.Fatalf("mailer called %d times, want 1: Redeliver resent an event in flight", calls)
+	}
+	if pending, _ := outbox.Pending(); len(pending) != 0 {
+		t.Fatalf("%d events pending after the mailer handled it", len(pending))
+	}
+}
+
+func TestClose(t *testing.T) {
+	bus := New(Options{})
+	var mu sync.Mutex
+	handled := 0
+	bus.SubscribeAsync("counter", func(context.Context, Envelope) error {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		handled++
+		mu.Unlock()
+		return nil
+	})
+	for range 5 {
+		bus.Publish(context.Background(), OrderCreated{OrderID: "ORD-1"})
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if handled != 5 {
+		t.Fatalf("Close returned with %d of 5 events handled", handled)
+	}
+	if err := bus.Publish(context.Background(), OrderCreated{}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Publish after Close = %v, want ErrClosed", err)
+	}
+	var nilBus *Bus
+	if err := nilBus.Publish(context.Background(), OrderCreated{}); err != nil {
+		t.Fatalf("Publish on a nil bus = %v", err)
+	}
+}
@@ -0,0 +1,356 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+var (
+	// ErrUnknownEvent is returned by Ack and Complete for an event the
+	// outbox does not hold.
+	ErrUnknownEvent = errors.New("events: unknown event")
+
+	// ErrOutboxFailed is returned by every write to a FileOutbox after a
+	// failed write left a partial line behind that could not be cut off.
+	// Reopening the outbox discards that line.
+	ErrOutboxFailed = errors.New("events: outbox failed")
+)
+
+// Outbox stores events until they have been handled. The Bus appends every
+// event before delivering it, acks it per subscriber, and completes it once
+// all subscribers have acked it.
+type Outbox interface {
+	Append(env Envelope) error
+	// Ack records that subscriber has handled the event with id.
+	Ack(id, subscriber string) error
+	// Complete removes the event with id.
+	Complete(id string) error
+	// Pending returns the events not yet completed, oldest first, with
+	// Acked listing the subscribers that have handled each.
+	Pending() ([]Envelope, error)
+	Close() error
+}
+
+// MemoryOutbox is an Outbox that does not survive a restart, for tests and
+// for code that wants redelivery of failures without persistence. It is
+// safe for concurrent use.
+type MemoryOutbox struct {
+	mu     sync.Mutex
+	order  []string // IDs in the order they were appended
+	events map[string]Envelope
+}
+
+// NewMemoryOutbox returns an empty MemoryOutbox.
+func NewMemoryOutbox() *MemoryOutbox {
+	return &MemoryOutbox{events: make(map[string]Envelope)}
+}
+
+// Append implements Outbox.
+func (o *MemoryOutbox) Append(env Envelope) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.append(env)
+	return nil
+}
+
+func (o *MemoryOutbox) append(env Envelope) {
+	if _, ok := o.events[env.ID]; !ok {
+		o.order = append(o.order, env.ID)
+	}
+	env.Acked = slices.Clone(env.Acked)
+	o.events[env.ID] = env
+}
+
+// Ack implements Outbox.
+func (o *MemoryOutbox) Ack(id, subscriber string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.ack(id, subscriber)
+}
+
+func (o *MemoryOutbox) ack(id, subscriber string) error {
+	env, ok := o.events[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownEvent, id)
+	}
+	if !slices.Contains(env.Acked, subscriber) {
+		env.Acked = append(env.Acked, subscriber)
+		o.events[id] = env
+	}
+	return nil
+}
+
+// Complete implements Outbox.
+func (o *MemoryOutbox) Complete(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.complete(id)
+}
+
+func (o *MemoryOutbox) complete(id string) error {
+	if _, ok := o.events[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownEvent, id)
+	}
+	delete(o.events, id)
+	o.order = slices.DeleteFunc(o.order, func(other string) bool { return other == id })
+	return nil
+}
+
+// Pending implements Outbox.
+func (o *MemoryOutbox) Pending() ([]Envelope, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	out := make([]Envelope, 0, len(o.order))
+	for _, id := range o.order {
+		env := o.events[id]
+		env.Acked = slices.Clone(env.Acked)
+		out = append(out, env)
+	}
+	return out, nil
+}
+
+func (o *MemoryOutbox) get(id string) (Envelope, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	env, ok := o.events[id]
+	if !ok {
+		return Envelope{}, fmt.Errorf("%w: %s", ErrUnknownEvent, id)
+	}
+	return env, nil
+}
+
+// Close implements Outbox. It does nothing.
+func (o *MemoryOutbox) Close() error { return nil }
+
+// FileOutbox is an Outbox persisted as an append-only JSON-lines journal:
+// every Append, Ack and Complete adds one line and syncs it to disk before
+// returning. A crash can only damage the line being written, which
+// OpenFileOutbox discards. It is safe for concurrent use within one
+// process.
+type FileOutbox struct {
+	mu     sync.Mutex
+	file   journalFile
+	size   int64 // length of the journal up to its last complete line
+	failed error // set once a torn line could not be cut off
+	mem    *MemoryOutbox
+}
+
+// journalFile is what FileOutbox needs from its file; *os.File is one.
+type journalFile interface {
+	io.Writer
+	io.Closer
+	Sync() error
+	Truncate(size int64) error
+}
+
+// journalLine is one line of a FileOutbox journal.
+type journalLine struct {
+	Op         string    `json:"op"` // "append", "ack" or "complete"
+	Envelope   *Envelope `json:"envelope,omitempty"`
+	ID         string    `json:"id,omitempty"`
+	Subscriber string    `json:"subscriber,omitempty"`
+}
+
+// OpenFileOutbox loads the journal at path, which need not exist yet.
+//
+// The journal is then compacted: the pending events are written to a
+// temporary file that atomically replaces the old journal, so completed
+// events do not pile up across restarts.
+func OpenFileOutbox(path string) (*FileOutbox, error) {
+	mem := NewMemoryOutbox()
+	if err := replayJournal(path, mem); err != nil {
+		return nil, fmt.Errorf("events: %s: %w", path, err)
+	}
+	if err := compact(path, mem); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &FileOutbox{file: f, size: info.Size(), mem: mem}, nil
+}
+
+// replayJournal applies every complete line of the journal at path to mem.
+// A damaged last line is the trace of a crash mid-write and is skipped;
+// damage anywhere else is an error.
+func replayJournal(path string, mem *MemoryOutbox) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rd := bufio.NewReader(f)
+	for lineNo := 1; ; lineNo++ {
+		line, err := rd.ReadBytes('\n')
+		if err == io.EOF {
+			return nil // a last line without its newline was cut short
+		}
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var jl journalLine
+		err = json.Unmarshal(line, &jl)
+		if err == nil {
+			err = jl.apply(mem)
+		}
+		if err != nil {
+			if _, peekErr := rd.Peek(1); peekErr == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+}
+
+func (jl journalLine) apply(mem *MemoryOutbox) error {
+	switch jl.Op {
+	case "append":
+		if jl.Envelope == nil {
+			return errors.New("append without envelope")
+		}
+		mem.append(*jl.Envelope)
+		return nil
+	case "ack":
+		return mem.ack(jl.ID, jl.Subscriber)
+	case "complete":
+		return mem.complete(jl.ID)
+	}
+	return fmt.Errorf("unknown op %q", jl.Op)
+}
+
+// compact rewrites the journal at path to hold only mem's pending events,
+// through a temporary file and an atomic rename.
+func compact(path string, mem *MemoryOutbox) error {
+	pending, _ := mem.Pending()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for i := range pending {
+		if err := enc.Encode(journalLine{Op: "append", Envelope: &pending[i]}); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// write appends jl to the journal and syncs it. The caller holds o.mu.
+//
+// If the write or sync fails, part of the line may be in the file, and the
+// next line would be appended to it, corrupting both. So the journal is
+// truncated back to its last complete line; if that fails too, the outbox
+// refuses all further writes.
+func (o *FileOutbox) write(jl journalLine) error {
+	if o.failed != nil {
+		return o.failed
+	}
+	line, err := json.Marshal(jl)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	_, err = o.file.Write(line)
+	if err == nil {
+		err = o.file.Sync()
+	}
+	if err != nil {
+		if truncErr := o.file.Truncate(o.size); truncErr != nil {
+			o.failed = fmt.Errorf("%w: %w", ErrOutboxFailed, truncErr)
+		}
+		return err
+	}
+	o.size += int64(len(line))
+	return nil
+}
+
+// Append implements Outbox.
+func (o *FileOutbox) Append(env Envelope) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := o.write(journalLine{Op: "append", Envelope: &env}); err != nil {
+		return err
+	}
+	return o.mem.Append(env)
+}
+
+// Ack implements Outbox.
+func (o *FileOutbox) Ack(id, subscriber string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, err := o.mem.get(id); err != nil {
+		return err
+	}
+	if err := o.write(journalLine{Op: "ack", ID: id, Subscriber: subscriber}); err != nil {
+		return err
+	}
+	return o.mem.Ack(id, subscriber)
+}
+
+// Complete implements Outbox.
+func (o *FileOutbox) Complete(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, err := o.mem.get(id); err != nil {
+		return err
+	}
+	if err := o.write(journalLine{Op: "complete", ID: id}); err != nil {
+		return err
+	}
+	return o.mem.Complete(id)
+}
+
+// Pending implements Outbox.
+func (o *FileOutbox) Pending() ([]Envelope, error) {
+	return o.mem.Pending()
+}
+
+// Close implements Outbox.
+func (o *FileOutbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.file.Close()
+}
+
+// Compile-time checks that both types satisfy the interface.
+var (
+	_ Outbox = (*MemoryOutbox)(nil)
+	_ Outbox = (*FileOutbox)(nil)
+)
@@ -0,0 +1,144 @@
+package events
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// faultyJournal wraps the journal file and fails the next Write (after
+// writing half of it) or Sync, or every Truncate, on request.
+type faultyJournal struct {
+	*os.File
+	failWrite, failSync, failTruncate bool
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (f *faultyJournal) Write(p []byte) (int, error) {
+	if f.failWrite {
+		f.failWrite = false
+		n, _ := f.File.Write(p[:len(p)/2])
+		return n, errDiskFull
+	}
+	return f.File.Write(p)
+}
+
+func (f *faultyJournal) Sync() error {
+	if f.failSync {
+		f.failSync = false
+		return errDiskFull
+	}
+	return f.File.Sync()
+}
+
+func (f *faultyJournal) Truncate(size int64) error {
+	if f.failTruncate {
+		return errDiskFull
+	}
+	return f.File.Truncate(size)
+}
+
+func testEnvelope(id string) Envelope {
+	return Envelope{ID: id, Time: time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC), Event: OrderCreated{OrderID: "ORD-" + id}}
+}
+
+func openTestOutbox(t *testing.T, path string) *FileOutbox {
+	t.Helper()
+	o, err := OpenFileOutbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { o.Close() })
+	return o
+}
+
+func pendingIDs(t *testing.T, o Outbox) []string {
+	t.Helper()
+	pending, err := o.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, env := range pending {
+		ids = append(ids, env.ID)
+	}
+	return ids
+}
+
+func TestFileOutboxSurvivesReopening(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	o := openTestOutbox(t, path)
+	for _, id := range []string{"1", "2", "3"} {
+		if err := o.Append(testEnvelope(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	o.Ack("1", "mailer")
+	o.Complete("2")
+	if err := o.Ack("9", "mailer"); !errors.Is(err, ErrUnknownEvent) {
+		t.Fatalf("Ack of an unknown event = %v, want ErrUnknownEvent", err)
+	}
+	o.Close()
+
+	reopened := openTestOutbox(t, path)
+	pending, _ := reopened.Pending()
+	if len(pending) != 2 || pending[0].ID != "1" || pending[1].ID != "3" {
+		t.Fatalf("pending after reopening = %v, want [1 3]", pendingIDs(t, reopened))
+	}
+	if acked := pending[0].Acked; len(acked) != 1 || acked[0] != "mailer" {
+		t.Fatalf("event 1 acked by %v, want [mailer]", acked)
+	}
+	if e, ok := pending[1].Event.(OrderCreated); !ok || e.OrderID != "ORD-3" {
+		t.Fatalf("event 3 decoded as %#v", pending[1].Event)
+	}
+}
+
+func TestFileOutboxUndoesFailedWrites(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault func(*faultyJournal)
+	}{
+		{"short write", func(f *faultyJournal) { f.failWrite = true }},
+		{"failed sync", func(f *faultyJournal) { f.failSync = true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "outbox.jsonl")
+			o := openTestOutbox(t, path)
+			o.Append(testEnvelope("1"))
+			file := &faultyJournal{File: o.file.(*os.File)}
+			o.file = file
+
+			tt.fault(file)
+			if err := o.Append(testEnvelope("2")); !errors.Is(err, errDiskFull) {
+				t.Fatalf("Append = %v, want errDiskFull", err)
+			}
+			if err := o.Append(testEnvelope("3")); err != nil {
+				t.Fatalf("Append after a rolled back failure: %v", err)
+			}
+			o.Close()
+
+			// A fragment left in the middle would make reopening fail.
+			reopened := openTestOutbox(t, path)
+			if ids := pendingIDs(t, reopened); len(ids) != 2 || ids[0] != "1" || ids[1] != "3" {
+				t.Fatalf("pending after reopening = %v, want [1 3]", ids)
+			}
+		})
+	}
+}
+
+func TestFileOutboxFailsWhenRollbackFails(t *testing.T) {
+	o := openTestOutbox(t, filepath.Join(t.TempDir(), "outbox.jsonl"))
+	o.Append(testEnvelope("1"))
+	o.file = &faultyJournal{File: o.file.(*os.File), failWrite: true, failTruncate: true}
+
+	if err := o.Append(testEnvelope("2")); !errors.Is(err, errDiskFull) {
+		t.Fatalf("Append = %v, want errDiskFull", err)
+	}
+	if err := o.Ack("1", "mailer"); !errors.Is(err, ErrOutboxFailed) {
+		t.Fatalf("Ack after a torn line was left behind = %v, want ErrOutboxFailed", err)
+	}
+}
@@ -0,0 +1,302 @@
+// Package ledger is a double-entry bookkeeping ledger.
+//
+// Money is never created or destroyed, only moved between accounts. Every
+// journal entry is a set of postings whose amounts add up to zero in each
+// currency: debits are positive amounts, credits negative. As a result the
+// balances of all accounts together are always zero, which Check verifies.
+//
+// Entries are append-only. A mistake is corrected by posting a reversing
+// entry, never by editing history, so the ledger doubles as an audit trail.
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/money"
+)
+
+var (
+	// ErrUnknownAccount is returned for postings to an account that was
+	// never opened.
+	ErrUnknownAccount = errors.New("ledger: unknown account")
+
+	// ErrDuplicateAccount is returned by Open for a code already in use.
+	ErrDuplicateAccount = errors.New("ledger: account already exists")
+
+	// ErrUnbalanced is returned for entries whose postings do not add up to
+	// zero.
+	ErrUnbalanced = errors.New("ledger: entry does not balance")
+
+	// ErrInvalidEntry is returned for entries that are malformed in any
+	// other way: too few postings, zero amounts, wrong currencies.
+	ErrInvalidEntry = errors.New("ledger: invalid entry")
+)
+
+// AccountType classifies an account. It decides the account's normal
+// balance: assets and expenses normally have debit (positive) balances,
+// the others credit (negative) ones.
+type AccountType int
+
+const (
+	Asset AccountType = iota
+	Liability
+	Equity
+	Revenue
+	Expense
+)
+
+func (t AccountType) String() string {
+	switch t {
+	case Asset:
+		return "asset"
+	case Liability:
+		return "liability"
+	case Equity:
+		return "equity"
+	case Revenue:
+		return "revenue"
+	case Expense:
+		return "expense"
+	}
+	return fmt.Sprintf("AccountType(%d)", int(t))
+}
+
+// DebitNormal reports whether accounts of type t normally have a debit
+// balance.
+func (t AccountType) DebitNormal() bool {
+	return t == Asset || t == Expense
+}
+
+// Account is a named bucket of money in a single currency.
+type Account struct {
+	Code     string // unique key, e.g. "assets:stripe:INR"
+	Name     string
+	Type     AccountType
+	Currency string // ISO 4217 code; every posting must be in it
+}
+
+// Posting moves Amount into (positive, debit) or out of (negative, credit)
+// one account.
+type Posting struct {
+	Account string
+	Amount  money.Money
+}
+
+// Entry is one balanced journal entry.
+type Entry struct {
+	ID          string // assigned by Post
+	Time        time.Time
+	Description string
+	Reference   string // external ID this entry records, e.g. a charge ID
+	Postings    []Posting
+}
+
+// Options configures a Ledger.
+type Options struct {
+	// Now returns the time given to entries posted without one. It
+	// defaults to time.Now.
+	Now func() time.Time
+}
+
+// Ledger holds accounts and their journal entries. It is safe for
+// concurrent use.
+type Ledger struct {
+	now func() time.Time
+
+	mu       sync.RWMutex
+	accounts map[string]Account
+	entries  []Entry
+}
+
+// New returns an empty Ledger.
+func New(opts Options) *Ledger {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Ledger{now: opts.Now, accounts: make(map[string]Account)}
+}
+
+// Open adds an account.
+func (l *Ledger) Open(a Account) error {
+	if a.Code == "" {
+		return fmt.Errorf("%w: empty account code", ErrInvalidEntry)
+	}
+	if _, err := money.Zero(a.Currency); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.accounts[a.Code]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateAccount, a.Code)
+	}
+	l.accounts[a.Code] = a
+	return nil
+}
+
+// Account returns the account with code.
+func (l *Ledger) Account(code string) (Account, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	a, ok := l.accounts[code]
+	if !ok {
+		return Account{}, fmt.Errorf("%w: %s", ErrUnknownAccount, code)
+	}
+	return a, nil
+}
+
+// Accounts returns every account, ordered by code.
+func (l *Ledger) Accounts() []Account {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := make([]Account, 0, len(l.accounts))
+	for _, a := range l.accounts {
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out
+}
+
+// Post validates e and appends it to the journal, returning it with its ID
+// and, if e.Time was zero, the current time filled in.
+func (l *Ledger) Post(e Entry) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.validate(e); err != nil {
+		return Entry{}, err
+	}
+	e.ID = fmt.Sprintf("je_%06d", len(l.entries)+1)
+	if e.Time.IsZero() {
+		e.Time = l.now()
+	}
+	e.Postings = slices.Clone(e.Postings)
+	l.entries = append(l.entries, e)
+	return cloneEntry(e), nil
+}
+
+// Entries returns the journal entries that touch account, or every entry
+// if account is empty, in the order they were posted.
+func (l *Ledger) Entries(account string) []Entry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var out []Entry
+	for _, e := range l.entries {
+		if account == "" || slices.ContainsFunc(e.Postings, func(p Posting) bool { return p.Account == account }) {
+			out = append(out, cloneEntry(e))
+		}
+	}
+	return out
+}
+
+// Balance returns the current balance of account: debits minus credits.
+func (l *Ledger) Balance(account string) (money.Money, error) {
+	return l.BalanceAt(account, l.now())
+}
+
+// BalanceAt returns the balance of account counting only entries dated at
+// or before at.
+func (l *Ledger) BalanceAt(account string, at time.Time) (money.Money, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	a, ok := l.accounts[account]
+	if !ok {
+		return money.Money{}, fmt.Errorf("%w: %s", ErrUnknownAccount, account)
+	}
+	balance, _ := money.Zero(a.Currency)
+	for _, e := range l.entries {
+		if e.Time.After(at) {
+			continue
+		}
+		for _, p := range e.Postings {
+			if p.Account != account {
+				continue
+			}
+			var err error
+			if balance, err = balance.Add(p.Amount); err != nil {
+				return money.Money{}, err
+			}
+		}
+	}
+	return balance, nil
+}
+
+// Check verifies the ledger's invariants: every entry is well formed and
+// balances, and the balances of all accounts add up to zero in every
+// currency. It returns nil for a healthy ledger and otherwise every
+// violation found, joined.
+func (l *Ledger) Check() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var errs []error
+	totals := make(map[string]money.Money)
+	for _, e := range l.entries {
+		if err := l.validate(e); err != nil {
+			errs = append(errs, fmt.Errorf("entry %s: %w", e.ID, err))
+			continue
+		}
+		for _, p := range e.Postings {
+			sum, err := add(totals[p.Amount.Currency()], p.Amount)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("entry %s: %w", e.ID, err))
+				continue
+			}
+			totals[p.Amount.Currency()] = sum
+		}
+	}
+	for currency, total := range totals {
+		if !total.IsZero() {
+			errs = append(errs, fmt.Errorf("%w: accounts in %s add up to %s", ErrUnbalanced, currency, total))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validate checks e against the chart of accounts. The caller must hold
+// l.mu.
+func (l *Ledger) validate(e Entry) error {
+	if len(e.Postings) < 2 {
+		return fmt.Errorf("%w: an entry needs at least two postings", ErrInvalidEntry)
+	}
+	sums := make(map[string]money.Money)
+	for _, p := range e.Postings {
+		a, ok := l.accounts[p.Account]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownAccount, p.Account)
+		}
+		if p.Amount.Currency() != a.Currency {
+			return fmt.Errorf("%w: %s posted to %s account %s", ErrInvalidEntry, p.Amount, a.Currency, a.Code)
+		}
+		if p.Amount.IsZero() {
+			return fmt.Errorf("%w: zero posting to %s", ErrInvalidEntry, a.Code)
+		}
+		sum, err := add(sums[a.Currency], p.Amount)
+		if err != nil {
+			return err
+		}
+		sums[a.Currency] = sum
+	}
+	for currency, sum := range sums {
+		if !sum.IsZero() {
+			return fmt.Errorf("%w: postings in %s add up to %s", ErrUnbalanced, currency, sum)
+		}
+	}
+	return nil
+}
+
+// add is Money.Add that treats the zero Money as zero of m's currency, so
+// sums can start from a missing map entry.
+func add(sum, m money.Money) (money.Money, error) {
+	if sum.Currency() == "" {
+		return m, nil
+	}
+	return sum.Add(m)
+}
+
+func cloneEntry(e Entry) Entry {
+	e.Postings = slices.Clone(e.Postings)
+	return e
+}
@@ -0,0 +1,145 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/money"
+)
+
+func newTestLedger(t *testing.T) *Ledger {
+	t.Helper()
+	l := New(Options{Now: func() time.Time { return time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC) }})
+	for _, a := range []Account{
+		{Code: "assets:bank:INR", Type: Asset, Currency: "INR"},
+		{Code: "revenue:sales:INR", Type: Revenue, Currency: "INR"},
+		{Code: "expenses:fees:INR", Type: Expense, Currency: "INR"},
+		{Code: "assets:bank:USD", Type: Asset, Currency: "USD"},
+		{Code: "revenue:sales:USD", Type: Revenue, Currency: "USD"},
+	} {
+		if err := l.Open(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return l
+}
+
+func post(account, amount string) Posting {
+	return Posting{Account: account, Amount: money.MustParse(amount)}
+}
+
+func TestPostValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		postings []Posting
+		want     error
+	}{
+		{"balanced", []Posting{post("assets:bank:INR", "100 INR"), post("revenue:sales:INR", "-100 INR")}, nil},
+		{"balanced with a fee", []Posting{
+			post("assets:bank:INR", "98 INR"), post("expenses:fees:INR", "2 INR"), post("revenue:sales:INR", "-100 INR"),
+		}, nil},
+		{"two currencies, each balanced", []Posting{
+			post("assets:bank:INR", "100 INR"), post("revenue:sales:INR", "-100 INR"),
+			post("assets:bank:USD", "5 USD"), post("revenue:sales:USD", "-5 USD"),
+		}, nil},
+		{"unbalanced", []Posting{post("assets:bank:INR", "100 INR"), post("revenue:sales:INR", "-99.99 INR")}, ErrUnbalanced},
+		{"balanced only across currencies", []Posting{post("assets:bank:INR", "5 INR"), post("revenue:sales:USD", "-5 USD")}, ErrUnbalanced},
+		{"one posting", []Posting{post("assets:bank:INR", "0.01 INR")}, ErrInvalidEntry},
+		{"zero posting", []Posting{post("assets:bank:INR", "0 INR"), post("revenue:sales:INR", "0 INR")}, ErrInvalidEntry},
+		{"wrong currency for the account", []Posting{post("assets:bank:INR", "5 USD"), post("revenue:sales:USD", "-5 USD")}, ErrInvalidEntry},
+		{"unknown account", []Posting{post("assets:cash:INR", "1 INR"), post("revenue:sales:INR", "-1 INR")}, ErrUnknownAccount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLedger(t)
+			_, err := l.Post(Entry{Postings: tt.postings})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Post = %v, want %v", err, tt.want)
+			}
+			if err != nil && len(l.Entries("")) != 0 {
+				t.Fatal("a rejected entry was recorded")
+			}
+		})
+	}
+}
+
+func TestBalances(t *testing.T) {
+	l := newTestLedger(t)
+	jan := func(day int) time.Time { return time.Date(2025, 1, day, 0, 0, 0, 0, time.UTC) }
+	entries := []Entry{
+		{Time: jan(1), Postings: []Posting{post("assets:bank:INR", "100 INR"), post("revenue:sales:INR", "-100 INR")}},
+		{Time: jan(2), Postings: []Posting{post("expenses:fees:INR", "2 INR"), post("assets:bank:INR", "-2 INR")}},
+		{Time: jan(3), Postings: []Posting{post("assets:bank:INR", "50.50 INR"), post("revenue:sales:INR", "-50.50 INR")}},
+	}
+	for _, e := range entries {
+		if _, err := l.Post(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		account string
+		at      time.Time
+		want    string
+	}{
+		{"assets:bank:INR", jan(31), "148.50 INR"},
+		{"revenue:sales:INR", jan(31), "-150.50 INR"},
+		{"expenses:fees:INR", jan(31), "2.00 INR"},
+		{"assets:bank:USD", jan(31), "0.00 USD"},
+		{"assets:bank:INR", jan(1), "100.00 INR"},
+		{"assets:bank:INR", jan(2), "98.00 INR"},
+		{"assets:bank:INR", jan(1).Add(-time.Nanosecond), "0.00 INR"},
+	}
+	for _, tt := range tests {
+		got, err := l.BalanceAt(tt.account, tt.at)
+		if err != nil {
+			t.Fatalf("BalanceAt(%s): %v", tt.account, err)
+		}
+		if !got.Equal(money.MustParse(tt.want)) {
+			t.Errorf("BalanceAt(%s, %s) = %s, want %s", tt.account, tt.at.Format(time.DateOnly), got, tt.want)
+		}
+	}
+	if got, _ := l.Balance("assets:bank:INR"); !got.Equal(money.MustParse("148.50 INR")) {
+		t.Errorf("Balance = %s, want 148.50 INR", got)
+	}
+	if _, err := l.Balance("assets:cash:INR"); !errors.Is(err, ErrUnknownAccount) {
+		t.Errorf("Balance of an unknown account = %v, want ErrUnknownAccount", err)
+	}
+	if got := len(l.Entries("expenses:fees:INR")); got != 1 {
+		t.Errorf("Entries(fees) = %d entries, want 1", got)
+	}
+	if err := l.Check(); err != nil {
+		t.Errorf("Check on a healthy ledger: %v", err)
+	}
+}
+
+func TestCheckFindsCorruption(t *testing.T) {
+	l := newTestLedger(t)
+	if _, err := l.Post(Entry{Postings: []Posting{post("assets:bank:INR", "100 INR"), post("revenue:sales:INR", "-100 INR")}}); err != nil {
+		t.Fatal(err)
+	}
+	// Bypass Post, as a bug or a bad import would.
+	l.entries = append(l.entries, Entry{ID: "je_bad", Postings: []Posting{post("assets:bank:INR", "1 INR"), post("revenue:sales:INR", "-2 INR")}})
+	if err := l.Check(); !errors.Is(err, ErrUnbalanced) {
+		t.Fatalf("Check = %v, want ErrUnbalanced", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	l := newTestLedger(t)
+	tests := []struct {
+		account Account
+		want    error
+	}{
+		{Account{Code: "assets:cash:INR", Currency: "INR"}, nil},
+		{Account{Code: "assets:bank:INR", Currency: "INR"}, ErrDuplicateAccount},
+		{Account{Code: "", Currency: "INR"}, ErrInvalidEntry},
+		{Account{Code: "assets:bank:XYZ", Currency: "XYZ"}, money.ErrUnknownCurrency},
+	}
+	for _, tt := range tests {
+		if err := l.Open(tt.account); !errors.Is(err, tt.want) {
+			t.Errorf("Open(%q) = %v, want %v", tt.account.Code, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+// Package money represents monetary amounts exactly, as an integer number
+// of minor units (paise, cents, ...) tagged with an ISO 4217 currency code.
+//
+// Binary floating point cannot represent most decimal fractions, so 0.1 +
+// 0.2 is not 0.3 and cents go missing. Money never converts through float:
+// amounts are parsed from and formatted to decimal strings, and every
+// operation that could produce a fraction of a minor unit (multiplying by
+// a rate, splitting a bill) either rounds half-to-even or distributes the
+// remainder explicitly.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+var (
+	// ErrUnknownCurrency is returned for codes missing from the currency
+	// table.
+	ErrUnknownCurrency = errors.New("money: unknown currency")
+
+	// ErrCurrencyMismatch is returned when combining amounts in different
+	// currencies.
+	ErrCurrencyMismatch = errors.New("money: currency mismatch")
+
+	// ErrInvalidAmount is returned when a string cannot be parsed.
+	ErrInvalidAmount = errors.New("money: invalid amount")
+
+	// ErrOverflow is returned when a result does not fit in int64 minor
+	// units.
+	ErrOverflow = errors.New("money: overflow")
+)
+
+// exponents maps ISO 4217 codes to the number of digits after the decimal
+// point of their minor unit.
+var exponents = map[string]int{
+	"AED": 2, "AUD": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2, "CNY": 2,
+	"EUR": 2, "GBP": 2, "HKD": 2, "IDR": 2, "INR": 2, "JOD": 3, "JPY": 0,
+	"KRW": 0, "KWD": 3, "MXN": 2, "NZD": 2, "OMR": 3, "SAR": 2, "SEK": 2,
+	"SGD": 2, "USD": 2, "VND": 0, "ZAR": 2,
+}
+
+// Exponent returns the number of minor-unit digits of currency.
+func Exponent(currency string) (int, error) {
+	exp, ok := exponents[currency]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+	return exp, nil
+}
+
+// Money is an exact amount in one currency. The zero value has no
+// currency and is only useful as a placeholder.
+type Money struct {
+	minor    int64
+	currency string
+}
+
+// New returns minor minor units of currency, e.g. New(1234, "INR") is
+// ₹12.34.
+func New(minor int64, currency string) (Money, error) {
+	if _, err := Exponent(currency); err != nil {
+		return Money{}, err
+	}
+	return Money{minor: minor, currency: currency}, nil
+}
+
+// MustNew is like New but panics on an unknown currency. It is meant for
+// constants in code.
+func MustNew(minor int64, currency string) Money {
+	m, err := New(minor, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Zero returns a zero amount of currency.
+func Zero(currency string) (Money, error) {
+	return New(0, currency)
+}
+
+// Parse reads an amount written as "<decimal> <code>", such as
+// "12.34 INR" or "-5 USD". Digits beyond the currency's minor unit are
+// rounded half-to-even, so "0.125 USD" is 0.12 USD.
+func Parse(s string) (Money, error) {
+	num, code, ok := strings.Cut(strings.TrimSpace(s), " ")
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q: want \"<amount> <currency>\"", ErrInvalidAmount, s)
+	}
+	code = strings.ToUpper(strings.TrimSpace(code))
+	exp, err := Exponent(code)
+	if err != nil {
+		return Money{}, err
+	}
+
+	if !isDecimal(num) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	r, ok := new(big.Rat).SetString(num)
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	minor, err := roundHalfEven(r.Mul(r, new(big.Rat).SetInt(pow10(exp))))
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{minor: minor, currency: code}, nil
+}
+
+// MustParse is like Parse but panics on error.
+func MustParse(s string) Money {
+	m, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Minor returns the amount in minor units.
+func (m Money) Minor() int64 { return m.minor }
+
+// Currency returns the ISO 4217 code.
+func (m Money) Currency() string { return m.currency }
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool { return m.minor == 0 }
+
+// IsPositive reports whether the amount is greater than zero.
+func (m Money) IsPositive() bool { return m.minor > 0 }
+
+// IsNegative reports whether the amount is less than zero.
+func (m Money) IsNegative() bool { return m.minor < 0 }
+
+// Add returns m + o.
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.same(o); err != nil {
+		return Money{}, err
+	}
+	sum := m.minor + o.minor
+	if (sum > m.minor) != (o.minor > 0) {
+		return Money{}, ErrOverflow
+	}
+	return Money{minor: sum, currency: m.currency}, nil
+}
+
+// Sub returns m - o.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.minor == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return m.Add(o.Neg())
+}
+
+// Neg returns -m.
+func (m Money) Neg() Money {
+	return Money{minor: -m.minor, currency: m.currency}
+}
+
+// Mul returns m multiplied by a whole number, such as a quantity.
+func (m Money) Mul(n int64) (Money, error) {
+	p := new(big.Int).Mul(big.NewInt(m.minor), big.NewInt(n))
+	if !p.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{minor: p.Int64(), currency: m.currency}, nil
+}
+
+// MulRat returns m * num / den rounded half-to-even to a whole minor unit.
+// Use it for rates: 18% tax is MulRat(18, 100).
+func (m Money) MulRat(num, den int64) (Money, error) {
+	if den == 0 {
+		return Money{}, fmt.Errorf("%w: zero denominator", ErrInvalidAmount)
+	}
+	r := new(big.Rat).SetFrac(new(big.Int).Mul(big.NewInt(m.minor), big.NewInt(num)), big.NewInt(den))
+	minor, err := roundHalfEven(r)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{minor: minor, currency: m.currency}, nil
+}
+
+// Allocate splits m into len(ratios) parts proportional to ratios. The
+// parts always add up to exactly m: minor units left over after rounding
+// down are handed out one at a time, starting with the first part.
+func (m Money) Allocate(ratios ...int64) ([]Money, error) {
+	var total int64
+	for _, r := range ratios {
+		if r < 0 {
+			return nil, fmt.Errorf("%w: negative ratio", ErrInvalidAmount)
+		}
+		total += r
+	}
+	if total <= 0 {
+		return nil, fmt.Errorf("%w: ratios must add up to more than zero", ErrInvalidAmount)
+	}
+
+	parts := make([]Money, len(ratios))
+	remainder := m.minor
+	for i, r := range ratios {
+		share := new(big.Int).Mul(big.NewInt(m.minor), big.NewInt(r))
+		share.Quo(share, big.NewInt(total)) // truncates toward zero
+		parts[i] = Money{minor: share.Int64(), currency: m.currency}
+		remainder -= share.Int64()
+	}
+	step := int64(1)
+	if remainder < 0 {
+		step = -1
+	}
+	for i := 0; remainder != 0; i = (i + 1) % len(parts) {
+		if ratios[i] == 0 {
+			continue
+		}
+		parts[i].minor += step
+		remainder -= step
+	}
+	return parts, nil
+}
+
+// Split divides m into n equal parts as far as possible; see Allocate.
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: cannot split into %d parts", ErrInvalidAmount, n)
+	}
+	ratios := make([]int64, n)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	return m.Allocate(ratios...)
+}
+
+// Compare returns -1, 0 or +1 as m is less than, equal to or greater
+// than o.
+func (m Money) Compare(o Money) (int, error) {
+	if err := m.same(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.minor < o.minor:
+		return -1, nil
+	case m.minor > o.minor:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Equal reports whether m and o have the same amount and currency.
+func (m Money) Equal(o Money) bool {
+	return m == o
+}
+
+// String formats m as Parse expects it, e.g. "12.34 INR".
+func (m Money) String() string {
+	if m.currency == "" {
+		return fmt.Sprintf("%d", m.minor)
+	}
+	exp := exponents[m.currency]
+	sign := ""
+	abs := new(big.Int).Abs(big.NewInt(m.minor))
+	if m.minor < 0 {
+		sign = "-"
+	}
+	digits := fmt.Sprintf("%0*s", exp+1, abs.String())
+	if exp == 0 {
+		return sign + digits + " " + m.currency
+	}
+	cut := len(digits) - exp
+	return sign + digits[:cut] + "." + digits[cut:] + " " + m.currency
+}
+
+// MarshalText implements encoding.TextMarshaler, so Money is written to
+// JSON as a string like "12.34 INR". The zero value, which has no
+// currency, is written as an empty string.
+func (m Money) MarshalText() ([]byte, error) {
+	if m.currency == "" {
+		return []byte{}, nil
+	}
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using Parse. Empty
+// text gives the zero value, mirroring MarshalText.
+func (m *Money) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*m = Money{}
+		return nil
+	}
+	v, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+func (m Money) same(o Money) error {
+	if m.currency != o.currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency, o.currency)
+	}
+	return nil
+}
+
+// roundHalfEven rounds r to the nearest integer, ties to even.
+func roundHalfEven(r *big.Rat) (int64, error) {
+	num, den := r.Num(), r.Denom()
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	// Compare 2*|rem| with den to find out which side of .5 we are on.
+	twice := new(big.Int).Abs(rem)
+	twice.Lsh(twice, 1)
+	switch c := twice.Cmp(den); {
+	case c > 0, c == 0 && q.Bit(0) == 1:
+		if num.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	if !q.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return q.Int64(), nil
+}
+
+// isDecimal reports whether s is a plain decimal number: an optional sign,
+// digits, and an optional fractional part.
+func isDecimal(s string) bool {
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return false
+	}
+	for _, part := range []string{whole, frac} {
+		for _, c := range part {
+			if c < '0' || c > '9' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
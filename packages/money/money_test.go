@@ -0,0 +1,162 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		minor   int64
+		cur     string
+		wantErr error
+	}{
+		{"12.34 INR", 1234, "INR", nil},
+		{"-5 USD", -500, "USD", nil},
+		{"+5 usd", 500, "USD", nil},
+		{"  7 JPY ", 7, "JPY", nil},
+		{".5 USD", 50, "USD", nil},
+		{"1.234 KWD", 1234, "KWD", nil},
+
+		{"-+5 INR", 0, "", ErrInvalidAmount},
+		{"+-5 INR", 0, "", ErrInvalidAmount},
+		{"--5 INR", 0, "", ErrInvalidAmount},
+		{"+ INR", 0, "", ErrInvalidAmount},
+		{". USD", 0, "", ErrInvalidAmount},
+		{"1.2.3 USD", 0, "", ErrInvalidAmount},
+		{"1e3 USD", 0, "", ErrInvalidAmount},
+		{"1/2 USD", 0, "", ErrInvalidAmount},
+		{"12.34", 0, "", ErrInvalidAmount},
+		{"", 0, "", ErrInvalidAmount},
+		{"12 XYZ", 0, "", ErrUnknownCurrency},
+		{"92233720368547758.08 USD", 0, "", ErrOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			m, err := Parse(tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+			if err == nil && (m.Minor() != tt.minor || m.Currency() != tt.cur) {
+				t.Fatalf("Parse(%q) = %d %s, want %d %s", tt.in, m.Minor(), m.Currency(), tt.minor, tt.cur)
+			}
+		})
+	}
+}
+
+func TestRoundHalfEven(t *testing.T) {
+	// Parse and MulRat share the rounding rule: ties go to the even
+	// neighbour, away from zero otherwise.
+	parse := []struct {
+		in   string
+		want int64
+	}{
+		{"0.125 USD", 12},
+		{"0.135 USD", 14},
+		{"0.1251 USD", 13},
+		{"-0.125 USD", -12},
+		{"-0.135 USD", -14},
+		{"1.5 JPY", 2},
+		{"2.5 JPY", 2},
+		{"-2.5 JPY", -2},
+	}
+	for _, tt := range parse {
+		if got := MustParse(tt.in).Minor(); got != tt.want {
+			t.Errorf("Parse(%q) = %d minor units, want %d", tt.in, got, tt.want)
+		}
+	}
+
+	mulRat := []struct {
+		minor, num, den int64
+		want            int64
+	}{
+		{1000, 18, 100, 180},
+		{125, 1, 10, 12},
+		{135, 1, 10, 14},
+		{-125, 1, 10, -12},
+		{1, 1, 3, 0},
+		{2, 1, 3, 1},
+	}
+	for _, tt := range mulRat {
+		got, err := MustNew(tt.minor, "INR").MulRat(tt.num, tt.den)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Minor() != tt.want {
+			t.Errorf("%d × %d/%d = %d, want %d", tt.minor, tt.num, tt.den, got.Minor(), tt.want)
+		}
+	}
+	if _, err := MustNew(1, "INR").MulRat(1, 0); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("MulRat with zero denominator = %v, want ErrInvalidAmount", err)
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		minor   int64
+		ratios  []int64
+		want    []int64
+		wantErr error
+	}{
+		{"even", 90, []int64{1, 1, 1}, []int64{30, 30, 30}, nil},
+		{"remainder goes first", 100, []int64{1, 1, 1}, []int64{34, 33, 33}, nil},
+		{"negative amount", -100, []int64{1, 1, 1}, []int64{-34, -33, -33}, nil},
+		{"weighted", 1000, []int64{70, 20, 10}, []int64{700, 200, 100}, nil},
+		{"zero ratio gets nothing", 5, []int64{0, 1, 1}, []int64{0, 3, 2}, nil},
+		{"no ratios", 100, nil, nil, ErrInvalidAmount},
+		{"all zero", 100, []int64{0, 0}, nil, ErrInvalidAmount},
+		{"negative ratio", 100, []int64{2, -1}, nil, ErrInvalidAmount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts, err := MustNew(tt.minor, "INR").Allocate(tt.ratios...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Allocate error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := make([]int64, len(parts))
+			var sum int64
+			for i, p := range parts {
+				got[i] = p.Minor()
+				sum += p.Minor()
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Allocate = %v, want %v", got, tt.want)
+			}
+			if sum != tt.minor {
+				t.Fatalf("parts add up to %d, want %d", sum, tt.minor)
+			}
+		})
+	}
+}
+
+func TestTextRoundTrip(t *testing.T) {
+	type invoice struct {
+		Total    Money `json:"total"`
+		Discount Money `json:"discount"`
+	}
+	tests := []invoice{
+		{Total: MustParse("12.34 INR"), Discount: MustParse("-0.50 INR")},
+		{Total: MustParse("0 USD")},
+		{Total: MustParse("1000 JPY"), Discount: Money{}}, // zero value has no currency
+	}
+	for _, in := range tests {
+		data, err := json.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out invoice
+		if err := json.Unmarshal(data, &out); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", data, err)
+		}
+		if out != in {
+			t.Fatalf("round trip of %s = %+v, want %+v", data, out, in)
+		}
+	}
+}
@@ -3,22 +3,427 @@ package main
 //Syntax
 // go mod init github.com/golang
 
-
 //to install package
 //go get package_name
 //go mod tidy
+
+// pac is a small user management CLI built on the auth and user packages:
+//
+//	go run . register -name Anurag -email anurag@gmail.com
+//	go run . login -email anurag@gmail.com
+//	go run . whoami
+//	go run . passwd
+//	go run . list-users -limit 10
+//	go run . logout
+//
+// Data lives in the directory given by -dir, $PAC_DIR, or pac/ under the
+// user config directory, in that order.
 import (
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
 	"github.com/golang/auth"
 	"github.com/golang/user"
 )
 
+// Exit codes.
+const (
+	exitOK    = 0
+	exitError = 1 // something went wrong
+	exitUsage = 2 // bad command line
+	exitAuth  = 3 // not logged in, or wrong credentials
+
+	exitInterrupted = 130 // Ctrl-C at a password prompt (128 + SIGINT)
+)
+
+const usage = `usage: pac [-dir path] <command> [flags]
+
+commands:
+  register    create a user
+  login       log in and remember the session
+  logout      end the session
+  whoami      show the logged-in user
+  passwd      change the logged-in user's password and end all sessions
+  list-users  list registered users
+`
+
+// errNotLoggedIn is returned by commands that need a session when there is
+// none.
+var errNotLoggedIn = errors.New("not logged in")
+
+// usageError marks errors caused by the command line rather than the
+// operation.
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+type app struct {
+	dir      string
+	users    *user.FileRepository
+	tokens   *auth.TokenIssuer
+	sessions sessionList
+	out      io.Writer
+	// prompt reads a password; it is readPassword outside tests.
+	prompt func(prompt string) (string, error)
+}
+
+type command struct {
+	name string
+	run  func(a *app, args []string) error
+}
+
+var commands = []command{
+	{"register", (*app).register},
+	{"login", (*app).login},
+	{"logout", (*app).logout},
+	{"whoami", (*app).whoami},
+	{"passwd", (*app).passwd},
+	{"list-users", (*app).listUsers},
+}
+
 func main() {
-	auth.LoginWithCredentials("Anurag","772002")
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	fs := flag.NewFlagSet("pac", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	dir := fs.String("dir", defaultDir(), "data directory")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	name, rest := fs.Arg(0), fs.Args()[1:]
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		a, err := openApp(*dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "pac:", err)
+			return exitError
+		}
+		return exitCode(cmd.run(a, rest))
+	}
+	fmt.Fprintf(os.Stderr, "pac: unknown command %q\n\n%s", name, usage)
+	return exitUsage
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return exitUsage
+	}
+	fmt.Fprintln(os.Stderr, "pac:", err)
+	var uerr usageError
+	switch {
+	case errors.As(err, &uerr):
+		return exitUsage
+	case errors.Is(err, errNotLoggedIn), errors.Is(err, auth.ErrInvalidCredentials):
+		return exitAuth
+	default:
+		return exitError
+	}
+}
 
-	user:=user.User{
-		Email: "Anurag@gmail.com",
-		Name:"Anurag",
+func defaultDir() string {
+	if dir := os.Getenv("PAC_DIR"); dir != "" {
+		return dir
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "pac")
+	}
+	return ".pac"
+}
+
+// openApp loads the user file and the token signing key from dir,
+// creating both on first use.
+func openApp(dir string) (*app, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	users, err := user.OpenFileRepository(filepath.Join(dir, "users.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	key, err := loadOrCreateKey(filepath.Join(dir, "token.key"))
+	if err != nil {
+		return nil, err
+	}
+	signingKey, err := auth.NewHS256Key("pac-1", key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Join(dir, "token.key"), err)
+	}
+	tokens := auth.NewTokenIssuer(auth.TokenOptions{
+		Issuer:   "github.com/golang",
+		Audience: []string{"pac"},
+		TTL:      12 * time.Hour,
+	}, signingKey)
+	return &app{
+		dir:      dir,
+		users:    users,
+		tokens:   tokens,
+		sessions: sessionList{path: filepath.Join(dir, "sessions.json"), now: time.Now},
+		out:      os.Stdout,
+		prompt:   readPassword,
+	}, nil
+}
+
+func loadOrCreateKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err == nil {
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, os.WriteFile(path, key, 0o600)
+}
+
+func (a *app) sessionPath() string {
+	return filepath.Join(a.dir, "session")
+}
+
+// session returns the claims of the saved session's token, which must
+// verify and must not have been revoked.
+func (a *app) session() (auth.Claims, error) {
+	token, err := os.ReadFile(a.sessionPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return auth.Claims{}, errNotLoggedIn
+	}
+	if err != nil {
+		return auth.Claims{}, err
+	}
+	claims, err := a.tokens.Verify(strings.TrimSpace(string(token)))
+	if err != nil {
+		return auth.Claims{}, fmt.Errorf("%w: %v", errNotLoggedIn, err)
+	}
+	live, err := a.sessions.live(claims.ID)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+	if !live {
+		return auth.Claims{}, fmt.Errorf("%w: session was ended", errNotLoggedIn)
+	}
+	return claims, nil
+}
+
+// current returns the user of the saved session.
+func (a *app) current() (user.User, error) {
+	claims, err := a.session()
+	if err != nil {
+		return user.User{}, err
+	}
+	u, err := a.users.GetByEmail(claims.Email)
+	if errors.Is(err, user.ErrNotFound) {
+		return user.User{}, fmt.Errorf("%w: account no longer exists", errNotLoggedIn)
+	}
+	return u, err
+}
+
+// parseFlags parses the flags of a subcommand, which takes no positional
+// arguments.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{err.Error()}
+	}
+	if fs.NArg() > 0 {
+		return usageError{fmt.Sprintf("%s: unexpected argument %q", fs.Name(), fs.Arg(0))}
 	}
+	return nil
+}
 
-	println(user.Email)
-}
\ No newline at end of file
+func (a *app) register(args []string) error {
+	fs := flag.NewFlagSet("register", flag.ContinueOnError)
+	name := fs.String("name", "", "display name")
+	email := fs.String("email", "", "email address")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	u, err := user.New(*name, *email)
+	if err != nil {
+		return err
+	}
+	password, err := a.newPassword("Password: ")
+	if err != nil {
+		return err
+	}
+	if err := auth.SetPassword(&u, password); err != nil {
+		return err
+	}
+	if err := a.users.Create(u); err != nil {
+		return err
+	}
+	fmt.Fprintln(a.out, "registered", u.Email)
+	return nil
+}
+
+func (a *app) login(args []string) error {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	email := fs.String("email", "", "email address")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *email == "" {
+		return usageError{"login: -email is required"}
+	}
+
+	password, err := a.prompt("Password: ")
+	if err != nil {
+		return err
+	}
+	res, err := auth.NewAuthenticator(a.users, auth.WithTokenIssuer(a.tokens)).
+		LoginWithCredentials(user.NormalizeEmail(*email), password)
+	if err != nil {
+		return err
+	}
+	claims, err := a.tokens.Verify(res.AccessToken)
+	if err != nil {
+		return err
+	}
+	if err := a.sessions.add(claims.ID, claims.Email, claims.ExpiresAt); err != nil {
+		return err
+	}
+	if err := os.WriteFile(a.sessionPath(), []byte(res.AccessToken+"\n"), 0o600); err != nil {
+		return err
+	}
+	fmt.Fprintln(a.out, "logged in as", res.User.Name)
+	return nil
+}
+
+// logout revokes the saved session's token and forgets it. A token that
+// no longer verifies is forgotten all the same.
+func (a *app) logout(args []string) error {
+	if err := parseFlags(flag.NewFlagSet("logout", flag.ContinueOnError), args); err != nil {
+		return err
+	}
+	claims, err := a.session()
+	if err == nil {
+		err = a.sessions.revoke(claims.ID)
+	}
+	if err != nil && !errors.Is(err, errNotLoggedIn) {
+		return err
+	}
+	err = os.Remove(a.sessionPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return errNotLoggedIn
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(a.out, "logged out")
+	return nil
+}
+
+func (a *app) whoami(args []string) error {
+	if err := parseFlags(flag.NewFlagSet("whoami", flag.ContinueOnError), args); err != nil {
+		return err
+	}
+	u, err := a.current()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "%s <%s>\n", u.Name, u.Email)
+	return nil
+}
+
+func (a *app) passwd(args []string) error {
+	if err := parseFlags(flag.NewFlagSet("passwd", flag.ContinueOnError), args); err != nil {
+		return err
+	}
+	u, err := a.current()
+	if err != nil {
+		return err
+	}
+	old, err := a.prompt("Current password: ")
+	if err != nil {
+		return err
+	}
+	if err := auth.VerifyPassword(u.PasswordHash, old); err != nil {
+		if errors.Is(err, auth.ErrPasswordMismatch) {
+			return auth.ErrInvalidCredentials
+		}
+		return err
+	}
+	password, err := a.newPassword("New password: ")
+	if err != nil {
+		return err
+	}
+	if err := auth.SetPassword(&u, password); err != nil {
+		return err
+	}
+	if err := a.users.Update(u); err != nil {
+		return err
+	}
+	// Whoever else holds a token for the account must log in again.
+	if err := a.sessions.revokeUser(u.Email); err != nil {
+		return err
+	}
+	if err := os.Remove(a.sessionPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	fmt.Fprintln(a.out, "password changed; log in again")
+	return nil
+}
+
+func (a *app) listUsers(args []string) error {
+	fs := flag.NewFlagSet("list-users", flag.ContinueOnError)
+	offset := fs.Int("offset", 0, "number of users to skip")
+	limit := fs.Int("limit", 20, "maximum number of users to show (0 for all)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if _, err := a.current(); err != nil {
+		return err
+	}
+
+	users, total, err := a.users.List(*offset, *limit)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		fmt.Fprintf(a.out, "%-30s %s\n", u.Email, u.Name)
+	}
+	fmt.Fprintf(a.out, "(%d of %d)\n", len(users), total)
+	return nil
+}
+
+// newPassword prompts for a password twice and checks that both match.
+func (a *app) newPassword(prompt string) (string, error) {
+	password, err := a.prompt(prompt)
+	if err != nil {
+		return "", err
+	}
+	if password == "" {
+		return "", errors.New("password must not be empty")
+	}
+	again, err := a.prompt("Repeat " + strings.ToLower(prompt))
+	if err != nil {
+		return "", err
+	}
+	if again != password {
+		return "", errors.New("passwords do not match")
+	}
+	return password, nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/golang/auth"
+)
+
+// testApp is an app in a temporary directory whose password prompts are
+// answered from answers and whose output goes to out.
+type testApp struct {
+	*app
+	out     bytes.Buffer
+	answers []string
+}
+
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+	a, err := openApp(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ta := &testApp{app: a}
+	a.out = &ta.out
+	a.prompt = func(string) (string, error) {
+		if len(ta.answers) == 0 {
+			t.Fatal("unexpected password prompt")
+		}
+		answer := ta.answers[0]
+		ta.answers = ta.answers[1:]
+		return answer, nil
+	}
+	return ta
+}
+
+// exec runs the command line args, typing passwords at the prompts.
+func (ta *testApp) exec(t *testing.T, passwords []string, args ...string) error {
+	t.Helper()
+	ta.out.Reset()
+	ta.answers = passwords
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			err := cmd.run(ta.app, args[1:])
+			if len(ta.answers) > 0 {
+				t.Fatalf("%v: %d password prompt(s) never shown", args, len(ta.answers))
+			}
+			return err
+		}
+	}
+	t.Fatalf("no command %q", args[0])
+	return nil
+}
+
+func (ta *testApp) mustExec(t *testing.T, passwords []string, args ...string) string {
+	t.Helper()
+	if err := ta.exec(t, passwords, args...); err != nil {
+		t.Fatalf("%v: %v", args, err)
+	}
+	return ta.out.String()
+}
+
+func (ta *testApp) savedToken(t *testing.T) []byte {
+	t.Helper()
+	token, err := os.ReadFile(ta.sessionPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestRegisterLoginWhoami(t *testing.T) {
+	ta := newTestApp(t)
+	if err := ta.exec(t, nil, "whoami"); exitCode(err) != exitAuth {
+		t.Fatalf("whoami before login = %v, want exit code %d", err, exitAuth)
+	}
+
+	out := ta.mustExec(t, []string{"s3cret", "s3cret"}, "register", "-name", " Ann  Lee ", "-email", "Ann@Example.com")
+	if out != "registered ann@example.com\n" {
+		t.Fatalf("register printed %q", out)
+	}
+	if err := ta.exec(t, []string{"x", "x"}, "register", "-name", "Ann", "-email", "ann@example.com"); exitCode(err) != exitError {
+		t.Fatalf("second register = %v, want exit code %d", err, exitError)
+	}
+	if err := ta.exec(t, []string{"wrong"}, "login", "-email", "ann@example.com"); exitCode(err) != exitAuth {
+		t.Fatalf("login with a wrong password = %v, want exit code %d", err, exitAuth)
+	}
+
+	ta.mustExec(t, []string{"s3cret"}, "login", "-email", "ANN@example.com")
+	if out := ta.mustExec(t, nil, "whoami"); out != "Ann Lee <ann@example.com>\n" {
+		t.Fatalf("whoami printed %q", out)
+	}
+	if out := ta.mustExec(t, nil, "list-users"); !strings.Contains(out, "ann@example.com") || !strings.Contains(out, "(1 of 1)") {
+		t.Fatalf("list-users printed %q", out)
+	}
+}
+
+func TestLogoutRevokesToken(t *testing.T) {
+	ta := newTestApp(t)
+	ta.mustExec(t, []string{"s3cret", "s3cret"}, "register", "-name", "Ann", "-email", "ann@example.com")
+	ta.mustExec(t, []string{"s3cret"}, "login", "-email", "ann@example.com")
+	stolen := ta.savedToken(t)
+
+	ta.mustExec(t, nil, "logout")
+	if err := ta.exec(t, nil, "logout"); !errors.Is(err, errNotLoggedIn) {
+		t.Fatalf("second logout = %v, want errNotLoggedIn", err)
+	}
+	// A copy of the token taken before logout is no longer accepted.
+	if err := os.WriteFile(ta.sessionPath(), stolen, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.exec(t, nil, "whoami"); !errors.Is(err, errNotLoggedIn) {
+		t.Fatalf("whoami with a logged-out token = %v, want errNotLoggedIn", err)
+	}
+}
+
+func TestPasswdRevokesEverySession(t *testing.T) {
+	ta := newTestApp(t)
+	ta.mustExec(t, []string{"s3cret", "s3cret"}, "register", "-name", "Ann", "-email", "ann@example.com")
+	ta.mustExec(t, []string{"s3cret", "s3cret"}, "register", "-name", "Bob", "-email", "bob@example.com")
+	ta.mustExec(t, []string{"s3cret"}, "login", "-email", "bob@example.com")
+	bob := ta.savedToken(t)
+	ta.mustExec(t, []string{"s3cret"}, "login", "-email", "ann@example.com")
+	otherDevice := ta.savedToken(t)
+	ta.mustExec(t, []string{"s3cret"}, "login", "-email", "ann@example.com")
+
+	if err := ta.exec(t, []string{"wrong"}, "passwd"); !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Fatalf("passwd with a wrong current password = %v, want ErrInvalidCredentials", err)
+	}
+	if err := ta.exec(t, []string{"s3cret", "new", "typo"}, "passwd"); err == nil {
+		t.Fatal("passwd accepted two different new passwords")
+	}
+	ta.mustExec(t, []string{"s3cret", "n3w", "n3w"}, "passwd")
+
+	if err := ta.exec(t, nil, "whoami"); !errors.Is(err, errNotLoggedIn) {
+		t.Fatalf("whoami after passwd = %v, want errNotLoggedIn", err)
+	}
+	for name, token := range map[string][]byte{"ann's other session": otherDevice, "bob's session": bob} {
+		os.WriteFile(ta.sessionPath(), token, 0o600)
+		err := ta.exec(t, nil, "whoami")
+		if live := err == nil; live != (name == "bob's session") {
+			t.Errorf("%s after ann's passwd: whoami = %v", name, err)
+		}
+	}
+
+	if err := ta.exec(t, []string{"s3cret"}, "login", "-email", "ann@example.com"); !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Fatalf("login with the old password = %v, want ErrInvalidCredentials", err)
+	}
+	ta.mustExec(t, []string{"n3w"}, "login", "-email", "ann@example.com")
+}
+
+func TestCommandLineErrors(t *testing.T) {
+	ta := newTestApp(t)
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"login"}, exitUsage},                                // -email is required
+		{[]string{"whoami", "extra"}, exitUsage},                      // no positional arguments
+		{[]string{"list-users", "-limit", "x"}, exitUsage},            // bad flag value
+		{[]string{"register", "-name", "", "-email", "x"}, exitError}, // validation
+		{[]string{"list-users"}, exitAuth},                            // needs a session
+	}
+	for _, tt := range tests {
+		if got := exitCode(ta.exec(t, nil, tt.args...)); got != tt.want {
+			t.Errorf("%v: exit code %d, want %d", tt.args, got, tt.want)
+		}
+	}
+	if got := run([]string{"-dir", t.TempDir(), "nope"}); got != exitUsage {
+		t.Errorf("unknown command: exit code %d, want %d", got, exitUsage)
+	}
+}
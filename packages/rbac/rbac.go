@@ -0,0 +1,175 @@
+// Package rbac answers "may this user do that?" from a role-based access
+// policy.
+//
+// A policy maps each user.Role to the permissions it grants and to the
+// roles it inherits from. Permissions are written "action:resource"
+// (see user.Permission), with "*" as a wildcard for either half:
+//
+//	{
+//	  "roles": {
+//	    "customer": {"permissions": ["create:orders", "read:orders"]},
+//	    "staff":    {"inherits": ["customer"], "permissions": ["update:orders"]},
+//	    "admin":    {"inherits": ["staff"], "permissions": ["*:payments", "*:orders"]}
+//	  }
+//	}
+package rbac
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/golang/user"
+)
+
+var (
+	// ErrForbidden is returned by Require when the user lacks permission.
+	ErrForbidden = errors.New("rbac: forbidden")
+
+	// ErrUnknownRole is returned when a role inherits from a role the
+	// policy does not define.
+	ErrUnknownRole = errors.New("rbac: unknown role")
+
+	// ErrInheritanceCycle is returned when roles inherit from each other
+	// in a loop.
+	ErrInheritanceCycle = errors.New("rbac: role inheritance cycle")
+
+	// ErrInvalidPermission is returned for permissions that are not of the
+	// form "action:resource".
+	ErrInvalidPermission = errors.New("rbac: invalid permission")
+)
+
+// RoleDefinition is one entry of a Policy.
+type RoleDefinition struct {
+	Inherits    []user.Role       `json:"inherits,omitempty"`
+	Permissions []user.Permission `json:"permissions"`
+}
+
+// Policy is the serialisable form of an access policy.
+type Policy struct {
+	Roles map[user.Role]RoleDefinition `json:"roles"`
+}
+
+// Engine evaluates a Policy. It is immutable once built and therefore safe
+// for concurrent use.
+type Engine struct {
+	// grants holds the effective permissions of every role, with
+	// inheritance already flattened.
+	grants map[user.Role][]grant
+}
+
+type grant struct {
+	action, resource string
+}
+
+// NewEngine validates p and resolves role inheritance.
+func NewEngine(p Policy) (*Engine, error) {
+	e := &Engine{grants: make(map[user.Role][]grant, len(p.Roles))}
+	for role := range p.Roles {
+		if _, err := e.resolve(p, role, nil); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// LoadPolicy decodes a JSON policy from r and builds an Engine from it.
+func LoadPolicy(r io.Reader) (*Engine, error) {
+	var p Policy
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("rbac: decode policy: %w", err)
+	}
+	return NewEngine(p)
+}
+
+// LoadPolicyFile is LoadPolicy for the file at path.
+func LoadPolicyFile(path string) (*Engine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadPolicy(f)
+}
+
+// resolve computes and caches the effective grants of role. path holds the
+// roles currently being resolved, to detect cycles.
+func (e *Engine) resolve(p Policy, role user.Role, path []user.Role) ([]grant, error) {
+	if g, ok := e.grants[role]; ok {
+		return g, nil
+	}
+	path = append(slices.Clip(path), role)
+	if slices.Index(path, role) < len(path)-1 {
+		return nil, fmt.Errorf("%w: %v", ErrInheritanceCycle, path)
+	}
+	def, ok := p.Roles[role]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownRole, role)
+	}
+
+	var grants []grant
+	for _, perm := range def.Permissions {
+		g, err := parsePermission(perm)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	for _, parent := range def.Inherits {
+		inherited, err := e.resolve(p, parent, path)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, inherited...)
+	}
+	e.grants[role] = grants
+	return grants, nil
+}
+
+func parsePermission(p user.Permission) (grant, error) {
+	action, resource, ok := strings.Cut(string(p), ":")
+	if !ok || action == "" || resource == "" {
+		return grant{}, fmt.Errorf("%w: %q", ErrInvalidPermission, p)
+	}
+	return grant{action: action, resource: resource}, nil
+}
+
+// Can reports whether any of u's roles grants action on resource. Roles
+// missing from the policy grant nothing.
+func (e *Engine) Can(u user.User, action, resource string) bool {
+	for _, role := range u.Roles {
+		for _, g := range e.grants[role] {
+			if match(g.action, action) && match(g.resource, resource) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Require is like Can but returns an error wrapping ErrForbidden instead
+// of false.
+func (e *Engine) Require(u user.User, action, resource string) error {
+	if !e.Can(u, action, resource) {
+		return fmt.Errorf("%w: %s may not %s %s", ErrForbidden, u.Email, action, resource)
+	}
+	return nil
+}
+
+// match reports whether value is covered by pattern, which is either "*",
+// an exact value, or a prefix ending in "/*".
+func match(pattern, value string) bool {
+	if pattern == "*" || pattern == value {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(value, prefix)
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package rbac
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/golang/user"
+)
+
+const testPolicy = `{
+  "roles": {
+    "customer": {"permissions": ["create:orders", "read:orders/*"]},
+    "staff":    {"inherits": ["customer"], "permissions": ["update:orders"]},
+    "admin":    {"inherits": ["staff"], "permissions": ["*:payments", "*:orders"]},
+    "auditor":  {"permissions": ["read:*"]}
+  }
+}`
+
+func TestEngineCan(t *testing.T) {
+	e, err := LoadPolicy(strings.NewReader(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		role             user.Role
+		action, resource string
+		want             bool
+	}{
+		{"customer", "create", "orders", true},
+		{"customer", "update", "orders", false},
+		{"customer", "read", "orders/1001", true},
+		{"customer", "read", "orders/1001/items", true},
+		{"customer", "read", "orders", false},       // "orders/*" covers what is under orders, not orders itself
+		{"customer", "read", "ordersheet/1", false}, // the prefix ends at the slash
+		{"staff", "update", "orders", true},
+		{"staff", "create", "orders", true}, // inherited from customer
+		{"staff", "read", "orders/7", true},
+		{"staff", "refund", "payments", false},
+		{"admin", "refund", "payments", true},
+		{"admin", "create", "orders", true},   // two levels up
+		{"admin", "read", "orders/7", true},   // two levels up
+		{"admin", "delete", "users", false},   // "*" is per half
+		{"auditor", "read", "anything", true}, // "*" resource
+		{"auditor", "update", "anything", false},
+		{"stranger", "read", "orders/7", false}, // roles missing from the policy grant nothing
+	}
+	for _, tt := range tests {
+		u := user.User{Email: "ann@example.com", Roles: []user.Role{tt.role}}
+		if got := e.Can(u, tt.action, tt.resource); got != tt.want {
+			t.Errorf("%s Can(%s, %s) = %v, want %v", tt.role, tt.action, tt.resource, got, tt.want)
+		}
+	}
+	if u := (user.User{Roles: []user.Role{"stranger", "auditor"}}); !e.Can(u, "read", "x") {
+		t.Error("a user is granted what any of their roles grants")
+	}
+}
+
+func TestEngineRequire(t *testing.T) {
+	e, err := LoadPolicy(strings.NewReader(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	staff := user.User{Email: "sam@example.com", Roles: []user.Role{"staff"}}
+	if err := e.Require(staff, "update", "orders"); err != nil {
+		t.Fatalf("Require = %v, want nil", err)
+	}
+	err = e.Require(staff, "refund", "payments")
+	if !errors.Is(err, ErrForbidden) {
+		t.Fatalf("Require = %v, want ErrForbidden", err)
+	}
+	if !strings.Contains(err.Error(), "sam@example.com may not refund payments") {
+		t.Errorf("error %q does not say who was refused what", err)
+	}
+}
+
+func TestNewEngineRejectsBadPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		want   error
+	}{
+		{"self cycle", `{"roles": {"a": {"inherits": ["a"], "permissions": []}}}`, ErrInheritanceCycle},
+		{"long cycle", `{"roles": {
+			"a": {"inherits": ["b"], "permissions": []},
+			"b": {"inherits": ["c"], "permissions": []},
+			"c": {"inherits": ["a"], "permissions": []}}}`, ErrInheritanceCycle},
+		{"unknown parent", `{"roles": {"a": {"inherits": ["ghost"], "permissions": []}}}`, ErrUnknownRole},
+		{"no colon", `{"roles": {"a": {"permissions": ["orders"]}}}`, ErrInvalidPermission},
+		{"empty action", `{"roles": {"a": {"permissions": [":orders"]}}}`, ErrInvalidPermission},
+		{"empty resource", `{"roles": {"a": {"permissions": ["read:"]}}}`, ErrInvalidPermission},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadPolicy(strings.NewReader(tt.policy)); !errors.Is(err, tt.want) {
+				t.Fatalf("LoadPolicy = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// A diamond shares a parent without being a cycle.
+	diamond := `{"roles": {
+		"base":  {"permissions": ["read:orders"]},
+		"left":  {"inherits": ["base"], "permissions": []},
+		"right": {"inherits": ["base"], "permissions": []},
+		"both":  {"inherits": ["left", "right"], "permissions": []}}}`
+	if _, err := LoadPolicy(strings.NewReader(diamond)); err != nil {
+		t.Fatalf("diamond inheritance: %v", err)
+	}
+}
+
+func TestLoadPolicyRejectsUnknownFields(t *testing.T) {
+	tests := []string{
+		`{"roles": {}, "version": 2}`,
+		`{"roles": {"admin": {"permissions": ["*:*"], "inherit": ["staff"]}}}`, // typo of "inherits"
+	}
+	for _, policy := range tests {
+		_, err := LoadPolicy(strings.NewReader(policy))
+		if err == nil || !strings.Contains(err.Error(), "unknown field") {
+			t.Errorf("LoadPolicy(%s) = %v, want an unknown field error", policy, err)
+		}
+	}
+}
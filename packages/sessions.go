@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/user"
+)
+
+// sessionList is the server side of pac's logins: the IDs ("jti") of the
+// access tokens that have not been revoked. A token is only accepted while
+// it is listed, so logout and passwd end sessions at once instead of
+// leaving the tokens valid until they expire.
+type sessionList struct {
+	path string
+	now  func() time.Time
+}
+
+// liveToken is one entry of the list.
+type liveToken struct {
+	Email   string `json:"email"`
+	Expires int64  `json:"exp"` // Unix seconds; the entry is dropped after
+}
+
+func (l sessionList) load() (map[string]liveToken, error) {
+	data, err := os.ReadFile(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]liveToken{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tokens := map[string]liveToken{}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// save writes tokens, minus the expired ones, through a temporary file and
+// an atomic rename.
+func (l sessionList) save(tokens map[string]liveToken) error {
+	now := l.now().Unix()
+	for id, t := range tokens {
+		if t.Expires <= now {
+			delete(tokens, id)
+		}
+	}
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), l.path)
+}
+
+// update loads the list, applies fn and saves the result.
+func (l sessionList) update(fn func(map[string]liveToken)) error {
+	tokens, err := l.load()
+	if err != nil {
+		return err
+	}
+	fn(tokens)
+	return l.save(tokens)
+}
+
+// add lists the token with the given ID.
+func (l sessionList) add(id, email string, expires int64) error {
+	return l.update(func(tokens map[string]liveToken) {
+		tokens[id] = liveToken{Email: email, Expires: expires}
+	})
+}
+
+// live reports whether the token with the given ID is listed.
+func (l sessionList) live(id string) (bool, error) {
+	tokens, err := l.load()
+	if err != nil {
+		return false, err
+	}
+	_, ok := tokens[id]
+	return ok, nil
+}
+
+// revoke ends the session of one token.
+func (l sessionList) revoke(id string) error {
+	return l.update(func(tokens map[string]liveToken) { delete(tokens, id) })
+}
+
+// revokeUser ends every session of the user with email.
+func (l sessionList) revokeUser(email string) error {
+	return l.update(func(tokens map[string]liveToken) {
+		for id, t := range tokens {
+			if user.NormalizeEmail(t.Email) == user.NormalizeEmail(email) {
+				delete(tokens, id)
+			}
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// readLine reads one line from standard input without its line ending.
+func readLine() (string, error) {
+	line, err := stdin.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// isTerminal reports whether f is an interactive terminal rather than a
+// pipe or file.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// readPassword prints prompt to standard error and reads a password from
+// standard input. On a terminal the password is never echoed: where echo
+// cannot be turned off it fails instead.
+func readPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if !isTerminal(os.Stdin) {
+		return readLine()
+	}
+	restore, err := disableEcho()
+	if err != nil {
+		fmt.Fprintln(os.Stderr)
+		return "", err
+	}
+
+	// Ctrl-C would otherwise leave the shell with echo still off.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-sigs:
+			restore()
+			fmt.Fprintln(os.Stderr)
+			os.Exit(exitInterrupted)
+		case <-done:
+		}
+	}()
+
+	password, err := readLine()
+	signal.Stop(sigs)
+	close(done)
+	restore()
+	fmt.Fprintln(os.Stderr)
+	return password, err
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+// disableEcho is not supported without stty(1). Rather than let the
+// password show on screen, refuse; it can still be piped in.
+func disableEcho() (restore func(), err error) {
+	return nil, errors.New("cannot hide password input on this platform; pipe the password to standard input instead")
+}
@@ -0,0 +1,287 @@
+package user
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrNotFound is returned when no user has the requested email.
+	ErrNotFound = errors.New("user: not found")
+
+	// ErrDuplicateEmail is returned by Create when the email is taken.
+	ErrDuplicateEmail = errors.New("user: email already registered")
+)
+
+// Repository stores users keyed by email. Emails are compared
+// case-insensitively. Create and Update return ValidationErrors for a User
+// that fails Validate.
+type Repository interface {
+	Create(u User) error
+	GetByEmail(email string) (User, error)
+	// Update replaces the stored user with the same email.
+	Update(u User) error
+	Delete(email string) error
+	// List returns up to limit users ordered by email, skipping the first
+	// offset, together with the total number of users.
+	List(offset, limit int) (users []User, total int, err error)
+}
+
+// key returns the map key for email.
+func key(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// MemoryRepository is an in-memory Repository. It is safe for concurrent
+// use.
+type MemoryRepository struct {
+	mu    sync.RWMutex
+	users map[string]User
+}
+
+// NewMemoryRepository returns an empty MemoryRepository.
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{users: make(map[string]User)}
+}
+
+// Create implements Repository.
+func (r *MemoryRepository) Create(u User) error {
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.create(u)
+}
+
+func (r *MemoryRepository) create(u User) error {
+	k := key(u.Email)
+	if _, ok := r.users[k]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateEmail, u.Email)
+	}
+	r.users[k] = clone(u)
+	return nil
+}
+
+// GetByEmail implements Repository.
+func (r *MemoryRepository) GetByEmail(email string) (User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	u, ok := r.users[key(email)]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return clone(u), nil
+}
+
+// Update implements Repository.
+func (r *MemoryRepository) Update(u User) error {
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.update(u)
+}
+
+func (r *MemoryRepository) update(u User) error {
+	k := key(u.Email)
+	if _, ok := r.users[k]; !ok {
+		return ErrNotFound
+	}
+	r.users[k] = clone(u)
+	return nil
+}
+
+// Delete implements Repository.
+func (r *MemoryRepository) Delete(email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.delete(email)
+}
+
+func (r *MemoryRepository) delete(email string) error {
+	k := key(email)
+	if _, ok := r.users[k]; !ok {
+		return ErrNotFound
+	}
+	delete(r.users, k)
+	return nil
+}
+
+// List implements Repository.
+func (r *MemoryRepository) List(offset, limit int) ([]User, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	keys := make([]string, 0, len(r.users))
+	for k := range r.users {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	total := len(keys)
+	offset = min(max(offset, 0), total)
+	end := total
+	if limit > 0 {
+		end = min(offset+limit, total)
+	}
+	out := make([]User, 0, end-offset)
+	for _, k := range keys[offset:end] {
+		out = append(out, clone(r.users[k]))
+	}
+	return out, total, nil
+}
+
+func clone(u User) User {
+	u.Roles = slices.Clone(u.Roles)
+	return u
+}
+
+// FileRepository is a Repository persisted as JSON lines, one user per
+// line. Every change rewrites the file through a temporary file and an
+// atomic rename, so a crash leaves either the old or the new contents.
+// It is safe for concurrent use within one process.
+type FileRepository struct {
+	path string
+
+	mu  sync.Mutex
+	mem *MemoryRepository
+}
+
+// fileRecord is the on-disk form of a User. It has the same fields, so the
+// two convert into each other, but it also encodes PasswordHash.
+type fileRecord struct {
+	Email         string `json:"email"`
+	Name          string `json:"name"`
+	Roles         []Role `json:"roles,omitempty"`
+	PasswordHash  string `json:"password_hash,omitempty"`
+	EmailVerified bool   `json:"email_verified,omitempty"`
+}
+
+// OpenFileRepository loads the users stored at path, which need not exist
+// yet.
+func OpenFileRepository(path string) (*FileRepository, error) {
+	r := &FileRepository{path: path, mem: NewMemoryRepository()}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		if len(strings.TrimSpace(sc.Text())) == 0 {
+			continue
+		}
+		var rec fileRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("user: %s:%d: %w", path, line, err)
+		}
+		if err := r.mem.create(User(rec)); err != nil {
+			return nil, fmt.Errorf("user: %s:%d: %w", path, line, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Create implements Repository.
+func (r *FileRepository) Create(u User) error {
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	return r.mutate(func(m *MemoryRepository) error { return m.create(u) })
+}
+
+// GetByEmail implements Repository.
+func (r *FileRepository) GetByEmail(email string) (User, error) {
+	return r.mem.GetByEmail(email)
+}
+
+// Update implements Repository.
+func (r *FileRepository) Update(u User) error {
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	return r.mutate(func(m *MemoryRepository) error { return m.update(u) })
+}
+
+// Delete implements Repository.
+func (r *FileRepository) Delete(email string) error {
+	return r.mutate(func(m *MemoryRepository) error { return m.delete(email) })
+}
+
+// List implements Repository.
+func (r *FileRepository) List(offset, limit int) ([]User, int, error) {
+	return r.mem.List(offset, limit)
+}
+
+// mutate applies fn to a copy of the current users, writes the result to
+// disk and only then makes it visible.
+func (r *FileRepository) mutate(fn func(*MemoryRepository) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.mem.mu.RLock()
+	next := &MemoryRepository{users: make(map[string]User, len(r.mem.users))}
+	for k, u := range r.mem.users {
+		next.users[k] = u
+	}
+	r.mem.mu.RUnlock()
+
+	if err := fn(next); err != nil {
+		return err
+	}
+	if err := r.write(next); err != nil {
+		return err
+	}
+
+	r.mem.mu.Lock()
+	r.mem.users = next.users
+	r.mem.mu.Unlock()
+	return nil
+}
+
+func (r *FileRepository) write(m *MemoryRepository) error {
+	users, _, _ := m.List(0, 0)
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, u := range users {
+		if err := enc.Encode(fileRecord(u)); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
@@ -0,0 +1,137 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// repositories returns one empty instance of every Repository.
+func repositories(t *testing.T) map[string]Repository {
+	t.Helper()
+	file, err := OpenFileRepository(filepath.Join(t.TempDir(), "users.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]Repository{"memory": NewMemoryRepository(), "file": file}
+}
+
+func TestRepositoryRejectsDuplicateEmail(t *testing.T) {
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := repo.Create(User{Email: "ann@example.com", Name: "Ann"}); err != nil {
+				t.Fatal(err)
+			}
+			for _, email := range []string{"ann@example.com", "Ann@Example.COM"} {
+				if err := repo.Create(User{Email: email, Name: "Impostor"}); !errors.Is(err, ErrDuplicateEmail) {
+					t.Errorf("Create(%q) = %v, want ErrDuplicateEmail", email, err)
+				}
+			}
+			if u, err := repo.GetByEmail("ANN@example.com"); err != nil || u.Name != "Ann" {
+				t.Fatalf("GetByEmail = %+v, %v; want the first Ann", u, err)
+			}
+		})
+	}
+}
+
+func TestRepositoryList(t *testing.T) {
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, email := range []string{"dan@example.com", "ann@example.com", "cy@example.com", "bob@example.com", "eve@example.com"} {
+				if err := repo.Create(User{Email: email, Name: "x"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tests := []struct {
+				offset, limit int
+				want          string
+			}{
+				{0, 0, "ann bob cy dan eve"}, // limit 0 means all
+				{0, 2, "ann bob"},
+				{2, 2, "cy dan"},
+				{4, 2, "eve"},
+				{5, 2, ""},
+				{9, 2, ""},
+				{-1, 1, "ann"},
+				{3, -1, "dan eve"},
+			}
+			for _, tt := range tests {
+				users, total, err := repo.List(tt.offset, tt.limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []string
+				for _, u := range users {
+					local, _, _ := strings.Cut(u.Email, "@")
+					got = append(got, local)
+				}
+				if strings.Join(got, " ") != tt.want || total != 5 {
+					t.Errorf("List(%d, %d) = %v, %d; want [%s], 5", tt.offset, tt.limit, got, total, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFileRepositoryReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.jsonl")
+	repo, err := OpenFileRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ann := User{Email: "ann@example.com", Name: "Ann", Roles: []Role{"admin"}, PasswordHash: "hash", EmailVerified: true}
+	for _, u := range []User{ann, {Email: "bob@example.com", Name: "Bob"}, {Email: "cy@example.com", Name: "Cy"}} {
+		if err := repo.Create(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ann.Name = "Ann Lee"
+	if err := repo.Update(ann); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete("bob@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenFileRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := reopened.GetByEmail("ann@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Ann Lee" || !got.HasRole("admin") || got.PasswordHash != "hash" || !got.EmailVerified {
+		t.Fatalf("reloaded %+v, want %+v", got, ann)
+	}
+	if _, err := reopened.GetByEmail("bob@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleted user after reload: %v", err)
+	}
+	if _, total, _ := reopened.List(0, 0); total != 2 {
+		t.Fatalf("%d users after reload, want 2", total)
+	}
+}
+
+func TestFileRepositoryRejectsCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.jsonl")
+	data := `{"email":"ann@example.com","name":"Ann"}` + "\n" + `{"email":"ANN@example.com","name":"Ann"}` + "\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFileRepository(path); !errors.Is(err, ErrDuplicateEmail) || !strings.Contains(err.Error(), ":2:") {
+		t.Fatalf("OpenFileRepository = %v, want ErrDuplicateEmail on line 2", err)
+	}
+}
+
+func TestUserJSONOmitsPasswordHash(t *testing.T) {
+	data, err := json.Marshal(User{Email: "ann@example.com", Name: "Ann", PasswordHash: "secret-hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret-hash") {
+		t.Fatalf("User JSON %s contains the password hash", data)
+	}
+}
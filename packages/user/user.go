@@ -1,6 +1,30 @@
 package user
 
-type User struct{
-	Email string
-	Name string
-}
\ No newline at end of file
+import "slices"
+
+type User struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	Roles []Role `json:"roles,omitempty"`
+	// PasswordHash is the output of auth.HashPassword. It is empty for
+	// users who cannot log in with a password. It is never encoded to
+	// JSON, so a User can be written to a response or a log as-is;
+	// FileRepository stores it through its own record type.
+	PasswordHash string `json:"-"`
+	// EmailVerified is set once the user has proven they own Email.
+	EmailVerified bool `json:"email_verified,omitempty"`
+}
+
+// Role names a set of permissions, such as "admin" or "customer". What a
+// role allows is decided by the access policy, not by the user.
+type Role string
+
+// Permission grants an action on a resource, written "action:resource",
+// for example "refund:payments". Either half may be "*", and a resource
+// ending in "/*" covers everything below it.
+type Permission string
+
+// HasRole reports whether u has been assigned role.
+func (u User) HasRole(role Role) bool {
+	return slices.Contains(u.Roles, role)
+}
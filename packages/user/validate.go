@@ -0,0 +1,162 @@
+package user
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits enforced by Validate.
+const (
+	MaxNameLength  = 100 // in characters
+	MaxEmailLength = 254 // in bytes, per RFC 5321
+	maxLocalLength = 64
+	maxLabelLength = 63
+)
+
+// FieldError describes one problem with one field.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors lists every problem found in a User. It is returned as
+// a whole so callers can report all of them at once.
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.Error()
+	}
+	return "user: invalid: " + strings.Join(msgs, "; ")
+}
+
+func (v *ValidationErrors) add(field, format string, args ...any) {
+	*v = append(*v, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// New returns a User with a normalized name and email, or
+// ValidationErrors if either is unacceptable.
+func New(name, email string) (User, error) {
+	u := User{Name: NormalizeName(name), Email: NormalizeEmail(email)}
+	if err := u.Validate(); err != nil {
+		return User{}, err
+	}
+	return u, nil
+}
+
+// NormalizeEmail trims surrounding space and lower-cases the address.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizeName trims the name, collapses runs of white space into single
+// spaces and drops invisible formatting characters such as zero-width
+// spaces. The standard library has no Unicode normalization tables, so
+// names are not recomposed to NFC; this only removes the differences that
+// cannot be seen.
+func NormalizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, name)
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// Validate checks every field of u and returns ValidationErrors listing
+// all problems, or nil.
+func (u User) Validate() error {
+	var errs ValidationErrors
+
+	switch n := utf8.RuneCountInString(u.Name); {
+	case !utf8.ValidString(u.Name):
+		errs.add("name", "is not valid UTF-8")
+	case strings.TrimSpace(u.Name) == "":
+		errs.add("name", "is required")
+	case n > MaxNameLength:
+		errs.add("name", "must be at most %d characters, got %d", MaxNameLength, n)
+	case strings.IndexFunc(u.Name, unicode.IsControl) >= 0:
+		errs.add("name", "must not contain control characters")
+	}
+
+	if msg := checkEmail(u.Email); msg != "" {
+		errs.add("email", "%s", msg)
+	}
+
+	for i, r := range u.Roles {
+		if strings.TrimSpace(string(r)) == "" {
+			errs.add(fmt.Sprintf("roles[%d]", i), "is empty")
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkEmail applies the common subset of RFC 5322 addr-spec rules: a
+// dot-atom local part and a domain of at least two DNS labels. Quoted
+// local parts and address literals are not accepted.
+func checkEmail(email string) string {
+	if email == "" {
+		return "is required"
+	}
+	if len(email) > MaxEmailLength {
+		return fmt.Sprintf("must be at most %d bytes", MaxEmailLength)
+	}
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || strings.Contains(domain, "@") {
+		return "must contain exactly one @"
+	}
+
+	if local == "" || len(local) > maxLocalLength {
+		return fmt.Sprintf("local part must be 1 to %d bytes", maxLocalLength)
+	}
+	for _, atom := range strings.Split(local, ".") {
+		if atom == "" {
+			return "local part must not start or end with a dot or contain two in a row"
+		}
+		if i := strings.IndexFunc(atom, func(r rune) bool { return !isAtext(r) }); i >= 0 {
+			return fmt.Sprintf("local part contains invalid character %q", atom[i:i+1])
+		}
+	}
+
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return "domain must contain a dot"
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > maxLabelLength {
+			return fmt.Sprintf("domain labels must be 1 to %d bytes", maxLabelLength)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return "domain labels must not start or end with a hyphen"
+		}
+		for _, r := range label {
+			if !(r == '-' || r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))) {
+				return fmt.Sprintf("domain contains invalid character %q", r)
+			}
+		}
+	}
+	return ""
+}
+
+// isAtext reports whether r may appear unquoted in a local part.
+func isAtext(r rune) bool {
+	if r >= utf8.RuneSelf {
+		return false
+	}
+	if unicode.IsLetter(r) || unicode.IsDigit(r) {
+		return true
+	}
+	return strings.ContainsRune("!#$%&'*+-/=?^_`{|}~", r)
+}
@@ -0,0 +1,138 @@
+package user
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string // substring of the error, "" for valid
+	}{
+		{"Ann", ""},
+		{"Anurag Singh", ""},
+		{"Zoë Ōtsuka", ""},
+		{strings.Repeat("é", MaxNameLength), ""}, // counted in characters, not bytes
+		{"", "is required"},
+		{"   ", "is required"},
+		{strings.Repeat("a", MaxNameLength+1), "at most 100 characters, got 101"},
+		{"Ann\x00", "control characters"},
+		{"Ann\nLee", "control characters"},
+		{"Ann\xff", "not valid UTF-8"},
+	}
+	for _, tt := range tests {
+		checkField(t, User{Name: tt.name, Email: "ann@example.com"}, "name", tt.want)
+	}
+}
+
+func TestValidateEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"ann@example.com", ""},
+		{"ann.lee+shop@mail.example.co.in", ""},
+		{"o'brien@example.com", ""},
+		{"ANN@EXAMPLE.COM", ""},
+		{"a@" + strings.Repeat("b", maxLabelLength) + ".com", ""},
+		{"", "is required"},
+		{"ann.example.com", "exactly one @"},
+		{"ann@b@example.com", "exactly one @"},
+		{"@example.com", "local part must be 1 to 64"},
+		{strings.Repeat("a", maxLocalLength+1) + "@example.com", "local part must be 1 to 64"},
+		{".ann@example.com", "dot"},
+		{"ann..lee@example.com", "dot"},
+		{"ann lee@example.com", `invalid character " "`},
+		{`"ann"@example.com`, "invalid character"},
+		{"ann@localhost", "domain must contain a dot"},
+		{"ann@example..com", "domain labels must be 1 to 63"},
+		{"ann@" + strings.Repeat("b", maxLabelLength+1) + ".com", "domain labels must be 1 to 63"},
+		{"ann@-example.com", "hyphen"},
+		{"ann@example_shop.com", "invalid character"},
+		{"ann@exämple.com", "invalid character"},
+		{"ann@[127.0.0.1]", "invalid character"},
+		{strings.Repeat("a", 60) + "@" + strings.Repeat(strings.Repeat("b", 60)+".", 4) + "com", "at most 254 bytes"},
+	}
+	for _, tt := range tests {
+		checkField(t, User{Name: "Ann", Email: tt.email}, "email", tt.want)
+	}
+}
+
+func TestValidateRoles(t *testing.T) {
+	tests := []struct {
+		roles []Role
+		field string
+		want  string
+	}{
+		{nil, "", ""},
+		{[]Role{"admin", "staff"}, "", ""},
+		{[]Role{"admin", ""}, "roles[1]", "is empty"},
+		{[]Role{" "}, "roles[0]", "is empty"},
+	}
+	for _, tt := range tests {
+		checkField(t, User{Name: "Ann", Email: "ann@example.com", Roles: tt.roles}, tt.field, tt.want)
+	}
+}
+
+// checkField validates u and checks that field is reported with a message
+// containing want, or that u is valid if want is empty.
+func checkField(t *testing.T, u User, field, want string) {
+	t.Helper()
+	err := u.Validate()
+	if want == "" {
+		if err != nil {
+			t.Errorf("Validate(%+v) = %v, want nil", u, err)
+		}
+		return
+	}
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != field || !strings.Contains(errs[0].Message, want) {
+		t.Errorf("Validate(%+v) = %v, want one %s error containing %q", u, err, field, want)
+	}
+}
+
+func TestValidateReportsEveryField(t *testing.T) {
+	err := User{Name: "", Email: "nope", Roles: []Role{""}}.Validate()
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 3 {
+		t.Fatalf("Validate = %v, want name, email and role errors together", err)
+	}
+}
+
+func TestNewNormalizes(t *testing.T) {
+	u, err := New("  Ann \t  Lee\u200b ", "  Ann.Lee@Example.COM ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "Ann Lee" || u.Email != "ann.lee@example.com" {
+		t.Fatalf("New = %+v, want name %q and email %q", u, "Ann Lee", "ann.lee@example.com")
+	}
+	if _, err := New("\u200b", "ann@example.com"); err == nil {
+		t.Fatal("New accepted a name that is only invisible characters")
+	}
+}
+
+func TestRepositoryRejectsInvalidUsers(t *testing.T) {
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			var errs ValidationErrors
+			if err := repo.Create(User{Email: "not an email", Name: "Ann"}); !errors.As(err, &errs) {
+				t.Fatalf("Create = %v, want ValidationErrors", err)
+			}
+			if err := repo.Create(User{Email: "ann@example.com", Name: "Ann"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.Update(User{Email: "ann@example.com", Name: ""}); !errors.As(err, &errs) {
+				t.Fatalf("Update = %v, want ValidationErrors", err)
+			}
+			if _, total, _ := repo.List(0, 0); total != 1 {
+				t.Fatalf("%d users stored, want 1", total)
+			}
+			if u, _ := repo.GetByEmail("ann@example.com"); u.Name != "Ann" {
+				t.Fatalf("stored name %q, want the valid one", u.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+// ── Audit Trail ───────────────────────────────────────────────────────────────
+// When a customer calls support asking "why was my order cancelled?", the
+// status history says WHAT happened but not WHO did it. Every mutating method
+// therefore appends an auditEntry per field it changes, with the field's
+// whole value before and after:
+//
+//	10:04:11  system             items    "2 × PZ-MARG-L" → "2 × PZ-MARG-L, 1 × GB-01"
+//	10:04:12  system             coupons  ""              → "WELCOME10"
+//	10:09:40  admin@example.com  status   "recieved"      → "cancelled"
+//
+// Changes made through changeStatusAs name the user; everything else is
+// recorded as systemActor.
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// systemActor is recorded for changes not made on behalf of a user.
+const systemActor = "system"
+
+// auditEntry records one field of an order changing.
+type auditEntry struct {
+	At    time.Time
+	Actor string // who made the change: a user's email, or systemActor
+	Field string // "status", "items", "coupons", ...
+	Old   string
+	New   string
+}
+
+// String formats e as one line of the trail.
+func (e auditEntry) String() string {
+	return fmt.Sprintf("%s  %-18s %-8s %q → %q", e.At.Format(time.TimeOnly), e.Actor, e.Field, e.Old, e.New)
+}
+
+// clock returns the order's current time. Orders built without newOrder
+// (struct literals, the repository) fall back to time.Now.
+func (o *order) clock() time.Time {
+	if o.now == nil {
+		return time.Now()
+	}
+	return o.now()
+}
+
+// record appends an audit entry stamped at and bumps updatedAt.
+func (o *order) record(at time.Time, actor, field, before, after string) {
+	o.audit = append(o.audit, auditEntry{At: at, Actor: actor, Field: field, Old: before, New: after})
+	o.updatedAt = at
+}
+
+// itemList is items as the audit trail shows them: "2 × TEA-01, 1 × GB-01".
+func itemList(items []lineItem) string {
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = fmt.Sprintf("%d × %s", item.quantity, item.sku)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// couponList is coupons as the audit trail shows them: "WELCOME10, FLAT5".
+func couponList(coupons []coupon) string {
+	codes := make([]string, len(coupons))
+	for i, c := range coupons {
+		codes[i] = c.code
+	}
+	return strings.Join(codes, ", ")
+}
+
+// auditTrail returns a copy of the order's audit trail, oldest first.
+func (o *order) auditTrail() []auditEntry {
+	return slices.Clone(o.audit)
+}
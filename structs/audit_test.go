@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/money"
+	"github.com/golang/rbac"
+	"github.com/golang/user"
+)
+
+// tickingClock returns a clock that moves one minute forward per call,
+// starting a minute after start.
+func tickingClock(start time.Time) func() time.Time {
+	now := start
+	return func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+}
+
+func TestAuditTrailRecordsEveryChange(t *testing.T) {
+	start := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	o, err := newOrder("ORD-1", orderOptions{Now: tickingClock(start)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(min int) time.Time { return start.Add(time.Duration(min) * time.Minute) }
+	if !o.createdAt.Equal(at(1)) || !o.updatedAt.Equal(at(1)) {
+		t.Fatalf("new order created %v, updated %v; want both %v", o.createdAt, o.updatedAt, at(1))
+	}
+
+	steps := []struct {
+		name string
+		do   func() error
+	}{
+		{"first item", func() error {
+			return o.addItem(lineItem{sku: "TEA-01", quantity: 2, unitPrice: money.MustParse("15.00 INR")})
+		}},
+		{"second item", func() error {
+			return o.addItem(lineItem{sku: "SAM-02", quantity: 1, unitPrice: money.MustParse("12.00 INR")})
+		}},
+		{"first coupon", func() error {
+			return o.applyCoupon(coupon{code: "FLAT5", kind: fixedOff, amount: money.MustParse("5.00 INR"), stackable: true})
+		}},
+		{"second coupon", func() error {
+			return o.applyCoupon(coupon{code: "TEN", kind: percentOff, bips: 1000, stackable: true})
+		}},
+		{"confirm", func() error { return o.changeStatus(Confirmed) }},
+	}
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+	}
+
+	want := []auditEntry{
+		{At: at(2), Actor: systemActor, Field: "items", Old: "", New: "2 × TEA-01"},
+		{At: at(3), Actor: systemActor, Field: "items", Old: "2 × TEA-01", New: "2 × TEA-01, 1 × SAM-02"},
+		{At: at(4), Actor: systemActor, Field: "coupons", Old: "", New: "FLAT5"},
+		{At: at(5), Actor: systemActor, Field: "coupons", Old: "FLAT5", New: "FLAT5, TEN"},
+		{At: at(6), Actor: systemActor, Field: "status", Old: "recieved", New: "confirmed"},
+	}
+	got := o.auditTrail()
+	if len(got) != len(want) {
+		t.Fatalf("audit trail has %d entries, want %d:\n%v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if !o.updatedAt.Equal(at(6)) || !o.createdAt.Equal(at(1)) {
+		t.Errorf("created %v, updated %v; want %v and %v", o.createdAt, o.updatedAt, at(1), at(6))
+	}
+
+	// Rejected changes record nothing and leave updatedAt alone.
+	if err := o.addItem(lineItem{sku: "LATE", quantity: 1, unitPrice: money.MustParse("1.00 INR")}); !errors.Is(err, ErrOrderLocked) {
+		t.Fatalf("addItem after confirming = %v, want ErrOrderLocked", err)
+	}
+	if err := o.changeStatus(Recieved); err == nil {
+		t.Fatal("illegal transition accepted")
+	}
+	if n := len(o.auditTrail()); n != len(want) || !o.updatedAt.Equal(at(6)) {
+		t.Fatalf("after rejected changes: %d entries, updated %v", n, o.updatedAt)
+	}
+
+	o.auditTrail()[0].Actor = "mallory"
+	if o.auditTrail()[0].Actor != systemActor {
+		t.Fatal("auditTrail returned the order's own slice")
+	}
+}
+
+func TestAuditTrailNamesTheAdmin(t *testing.T) {
+	policy, err := rbac.NewEngine(rbac.Policy{Roles: map[user.Role]rbac.RoleDefinition{
+		"admin": {Permissions: []user.Permission{"*:orders"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2025, 1, 15, 10, 9, 40, 0, time.UTC)
+	o, err := newOrder("ORD-2", orderOptions{Now: func() time.Time { return at }})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shopper := user.User{Email: "ann@example.com", Roles: []user.Role{"customer"}}
+	if err := o.changeStatusAs(shopper, policy, Cancelled); !errors.Is(err, rbac.ErrForbidden) {
+		t.Fatalf("shopper's change = %v, want rbac.ErrForbidden", err)
+	}
+	if n := len(o.auditTrail()); n != 0 {
+		t.Fatalf("a refused change left %d audit entries", n)
+	}
+
+	at = at.Add(90 * time.Second)
+	admin := user.User{Email: "admin@example.com", Roles: []user.Role{"admin"}}
+	if err := o.changeStatusAs(admin, policy, Cancelled); err != nil {
+		t.Fatal(err)
+	}
+	want := auditEntry{At: at, Actor: "admin@example.com", Field: "status", Old: "recieved", New: "cancelled"}
+	if trail := o.auditTrail(); len(trail) != 1 || trail[0] != want {
+		t.Fatalf("audit trail = %v, want [%v]", trail, want)
+	}
+	if !o.updatedAt.Equal(at) {
+		t.Fatalf("updatedAt = %v, want %v", o.updatedAt, at)
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+// ── Customers ─────────────────────────────────────────────────────────────────
+// A customer is the person an order is for. They log in as a user.User
+// (packages/user) — the two share an email address, which is the key of the
+// user repository — and keep an address book:
+//
+//	label    kinds               default for
+//	"home"   shipping, billing   shipping, billing
+//	"office" shipping            —
+//
+// Every order embeds a COPY of its customer (see order in structs.go), so
+// editing the address book later does not rewrite old orders.
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/golang/money"
+	"github.com/golang/user"
+)
+
+// Customer errors.
+var (
+	ErrInvalidCustomer  = errors.New("invalid customer")
+	ErrInvalidAddress   = errors.New("invalid address")
+	ErrDuplicateAddress = errors.New("address label already in use")
+	ErrUnknownAddress   = errors.New("no such address")
+)
+
+// addressKind says what an address may be used for. It is a BIT SET, so one
+// address can be both: shippingAddress | billingAddress.
+type addressKind int
+
+const (
+	shippingAddress addressKind = 1 << iota
+	billingAddress
+)
+
+func (k addressKind) String() string {
+	var kinds []string
+	if k&shippingAddress != 0 {
+		kinds = append(kinds, "shipping")
+	}
+	if k&billingAddress != 0 {
+		kinds = append(kinds, "billing")
+	}
+	return strings.Join(kinds, "+")
+}
+
+// address is one entry in a customer's address book.
+type address struct {
+	label      string // unique per customer, e.g. "home"
+	kinds      addressKind
+	line1      string
+	line2      string // optional
+	city       string
+	region     string // state or province; picks the tax rules (see pricing.go)
+	postalCode string
+	country    string // ISO 3166-1 alpha-2, e.g. "IN"
+}
+
+func (a address) String() string {
+	lines := []string{a.line1, a.line2, a.city + " " + a.postalCode, a.region, a.country}
+	return strings.Join(slices.DeleteFunc(lines, func(s string) bool { return strings.TrimSpace(s) == "" }), ", ")
+}
+
+// customer is a first-class customer: who they are, how to reach them, and
+// where to ship and bill. Only name is needed for a customer embedded in a
+// hand-built order.
+type customer struct {
+	id        string
+	name      string
+	email     string // contact address AND the link to their user.User account
+	phone     string // optional, e.g. "+91 98450 12345"
+	addresses []address
+
+	defaultShipping string // label of the default shipping address ("" = none)
+	defaultBilling  string // label of the default billing address ("" = none)
+}
+
+// newCustomer creates the customer for account. The name and email are taken
+// from the account, so the two always match.
+func newCustomer(id string, account user.User, phone string) (*customer, error) {
+	if strings.TrimSpace(id) == "" {
+		return nil, fmt.Errorf("%w: id is required", ErrInvalidCustomer)
+	}
+	if err := account.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCustomer, err)
+	}
+	phone = strings.TrimSpace(phone)
+	if phone != "" && !validPhone(phone) {
+		return nil, fmt.Errorf("%w: phone %q", ErrInvalidCustomer, phone)
+	}
+	return &customer{id: id, name: account.Name, email: user.NormalizeEmail(account.Email), phone: phone}, nil
+}
+
+// validPhone accepts an optional leading "+" and 7 to 15 digits (the E.164
+// limits), allowing spaces, dashes and brackets between them.
+func validPhone(phone string) bool {
+	digits := 0
+	for i, r := range phone {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '+' && i == 0:
+		case strings.ContainsRune(" -()", r):
+		default:
+			return false
+		}
+	}
+	return digits >= 7 && digits <= 15
+}
+
+// account looks up the user.User this customer logs in as.
+func (c *customer) account(users user.Repository) (user.User, error) {
+	return users.GetByEmail(c.email)
+}
+
+// ── Address Book ──────────────────────────────────────────────────────────────
+// addAddress adds a to the address book. The first address of each kind
+// becomes that kind's default.
+func (c *customer) addAddress(a address) error {
+	switch {
+	case strings.TrimSpace(a.label) == "":
+		return fmt.Errorf("%w: label is required", ErrInvalidAddress)
+	case a.kinds&(shippingAddress|billingAddress) == 0:
+		return fmt.Errorf("%w: %s: must be for shipping, billing or both", ErrInvalidAddress, a.label)
+	case a.line1 == "" || a.city == "" || a.postalCode == "" || a.country == "":
+		return fmt.Errorf("%w: %s: line1, city, postal code and country are required", ErrInvalidAddress, a.label)
+	}
+	if _, ok := c.address(a.label); ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateAddress, a.label)
+	}
+	c.addresses = append(c.addresses, a)
+	if a.kinds&shippingAddress != 0 && c.defaultShipping == "" {
+		c.defaultShipping = a.label
+	}
+	if a.kinds&billingAddress != 0 && c.defaultBilling == "" {
+		c.defaultBilling = a.label
+	}
+	return nil
+}
+
+// address returns the address with label (case-insensitive).
+func (c *customer) address(label string) (address, bool) {
+	i := slices.IndexFunc(c.addresses, func(a address) bool { return strings.EqualFold(a.label, label) })
+	if i < 0 {
+		return address{}, false
+	}
+	return c.addresses[i], true
+}
+
+// setDefault makes the address with label the default for kind, which must
+// be exactly one of shippingAddress and billingAddress.
+func (c *customer) setDefault(kind addressKind, label string) error {
+	a, ok := c.address(label)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownAddress, label)
+	}
+	if a.kinds&kind == 0 {
+		return fmt.Errorf("%w: %s is not a %s address", ErrInvalidAddress, a.label, kind)
+	}
+	switch kind {
+	case shippingAddress:
+		c.defaultShipping = a.label
+	case billingAddress:
+		c.defaultBilling = a.label
+	default:
+		return fmt.Errorf("%w: kind %s", ErrInvalidAddress, kind)
+	}
+	return nil
+}
+
+// defaultAddress returns the default address for kind, if there is one.
+func (c *customer) defaultAddress(kind addressKind) (address, bool) {
+	switch kind {
+	case shippingAddress:
+		return c.address(c.defaultShipping)
+	case billingAddress:
+		return c.address(c.defaultBilling)
+	}
+	return address{}, false
+}
+
+// clone returns a copy of c that shares no memory with it.
+func (c customer) clone() customer {
+	c.addresses = slices.Clone(c.addresses)
+	return c
+}
+
+// ── Order History ─────────────────────────────────────────────────────────────
+// orderHistory is a customer's orders as support sees them.
+type orderHistory struct {
+	orders   []*order            // newest first
+	byStatus map[OrderStatus]int // number of orders in each status
+
+	// lifetimeSpend is the total of every confirmed order that was not
+	// cancelled or refunded, per currency — rupees and dollars cannot be
+	// added up. An order still Recieved is a cart, not spend.
+	lifetimeSpend map[string]money.Money
+}
+
+// history loads all of c's orders from repo. A customer without an id
+// (one embedded in a hand-built order) has no history: an empty CustomerID
+// would match every order in the repository.
+func (c *customer) history(repo OrderRepository) (orderHistory, error) {
+	if c.id == "" {
+		return orderHistory{}, fmt.Errorf("%w: history needs a customer id", ErrInvalidCustomer)
+	}
+	orders, _, err := repo.List(orderQuery{CustomerID: c.id})
+	if err != nil {
+		return orderHistory{}, err
+	}
+	slices.Reverse(orders)
+
+	h := orderHistory{orders: orders, byStatus: make(map[OrderStatus]int), lifetimeSpend: make(map[string]money.Money)}
+	for _, o := range orders {
+		h.byStatus[o.status]++
+		if o.status == Recieved || o.status == Cancelled || o.status == Refunded {
+			continue
+		}
+		r, err := o.receipt()
+		if err != nil {
+			return orderHistory{}, fmt.Errorf("order %s: %w", o.id, err)
+		}
+		if len(r.lines) == 0 {
+			continue // nothing ordered yet
+		}
+		cur := r.total.Currency()
+		spent, ok := h.lifetimeSpend[cur]
+		if !ok {
+			h.lifetimeSpend[cur] = r.total
+			continue
+		}
+		if h.lifetimeSpend[cur], err = spent.Add(r.total); err != nil {
+			return orderHistory{}, err
+		}
+	}
+	return h, nil
+}
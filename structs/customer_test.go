@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang/money"
+	"github.com/golang/user"
+)
+
+func testAddress(label string, kinds addressKind) address {
+	return address{label: label, kinds: kinds, line1: "12 MG Road", city: "Bengaluru", region: "KA", postalCode: "560001", country: "IN"}
+}
+
+func TestNewCustomer(t *testing.T) {
+	ann := user.User{Name: "Ann", Email: "Ann@Example.com"}
+	c, err := newCustomer("CUS-1", ann, " +91 98450-12345 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.name != "Ann" || c.email != "ann@example.com" || c.phone != "+91 98450-12345" {
+		t.Fatalf("newCustomer = %+v", c)
+	}
+	tests := []struct {
+		name    string
+		id      string
+		account user.User
+		phone   string
+	}{
+		{"no id", " ", ann, ""},
+		{"invalid account", "CUS-1", user.User{Name: "Ann", Email: "ann"}, ""},
+		{"letters in phone", "CUS-1", ann, "call me"},
+		{"short phone", "CUS-1", ann, "12345"},
+		{"plus inside phone", "CUS-1", ann, "91+9845012345"},
+	}
+	for _, tt := range tests {
+		if _, err := newCustomer(tt.id, tt.account, tt.phone); !errors.Is(err, ErrInvalidCustomer) {
+			t.Errorf("%s: err = %v, want ErrInvalidCustomer", tt.name, err)
+		}
+	}
+}
+
+func TestAddressBook(t *testing.T) {
+	c := &customer{id: "CUS-1", name: "Ann"}
+	if _, ok := c.defaultAddress(shippingAddress); ok {
+		t.Fatal("empty address book has a default")
+	}
+
+	// The first address of each kind becomes its default.
+	for _, a := range []address{
+		testAddress("office", shippingAddress),
+		testAddress("home", shippingAddress|billingAddress),
+		testAddress("parents", billingAddress),
+	} {
+		if err := c.addAddress(a); err != nil {
+			t.Fatalf("addAddress(%s): %v", a.label, err)
+		}
+	}
+	if c.defaultShipping != "office" || c.defaultBilling != "home" {
+		t.Fatalf("defaults = %q/%q, want office/home", c.defaultShipping, c.defaultBilling)
+	}
+
+	invalid := []struct {
+		name string
+		a    address
+		want error
+	}{
+		{"duplicate label", testAddress("HOME", billingAddress), ErrDuplicateAddress},
+		{"no label", testAddress(" ", shippingAddress), ErrInvalidAddress},
+		{"no kind", testAddress("cabin", 0), ErrInvalidAddress},
+		{"no city", func() address { a := testAddress("cabin", shippingAddress); a.city = ""; return a }(), ErrInvalidAddress},
+	}
+	for _, tt := range invalid {
+		if err := c.addAddress(tt.a); !errors.Is(err, tt.want) {
+			t.Errorf("%s: addAddress = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if len(c.addresses) != 3 {
+		t.Fatalf("%d addresses, want 3", len(c.addresses))
+	}
+
+	defaults := []struct {
+		kind  addressKind
+		label string
+		want  error
+	}{
+		{shippingAddress, "Home", nil}, // labels are case-insensitive
+		{billingAddress, "parents", nil},
+		{billingAddress, "office", ErrInvalidAddress},   // shipping only
+		{shippingAddress, "parents", ErrInvalidAddress}, // billing only
+		{shippingAddress | billingAddress, "home", ErrInvalidAddress},
+		{shippingAddress, "cabin", ErrUnknownAddress},
+	}
+	for _, tt := range defaults {
+		if err := c.setDefault(tt.kind, tt.label); !errors.Is(err, tt.want) {
+			t.Errorf("setDefault(%s, %s) = %v, want %v", tt.kind, tt.label, err, tt.want)
+		}
+	}
+	ship, _ := c.defaultAddress(shippingAddress)
+	bill, _ := c.defaultAddress(billingAddress)
+	if ship.label != "home" || bill.label != "parents" {
+		t.Fatalf("defaults = %s/%s, want home/parents", ship.label, bill.label)
+	}
+}
+
+func TestCustomerHistory(t *testing.T) {
+	repo := newMemoryOrderRepository()
+	ann := &customer{id: "CUS-1", name: "Ann"}
+	bob := &customer{id: "CUS-2", name: "Bob"}
+	start := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	place := func(id string, c *customer, currency string, price string, path ...OrderStatus) {
+		t.Helper()
+		start = start.Add(time.Hour)
+		at := start
+		o, err := newOrder(id, orderOptions{Customer: c, Currency: currency, Now: func() time.Time { return at }})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if price != "" {
+			if err := o.addItem(lineItem{sku: "SKU", quantity: 1, unitPrice: money.MustParse(price)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, s := range path {
+			if err := o.changeStatus(s); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := repo.Save(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	place("ORD-1", ann, "INR", "100.00 INR", Confirmed)
+	place("ORD-2", ann, "INR", "250.00 INR", Confirmed, Prepared, Delivered)
+	place("ORD-3", ann, "INR", "999.00 INR", Confirmed, Cancelled)
+	place("ORD-4", ann, "INR", "80.00 INR", Confirmed, Prepared, Delivered, Refunded)
+	place("ORD-5", ann, "INR", "40.00 INR") // still a cart
+	place("ORD-6", ann, "USD", "12.50 USD", Confirmed)
+	place("ORD-7", ann, "INR", "", Confirmed) // confirmed with nothing in it
+	place("ORD-8", bob, "INR", "500.00 INR", Confirmed)
+
+	h, err := ann.history(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, o := range h.orders {
+		ids = append(ids, o.id)
+	}
+	if want := []string{"ORD-7", "ORD-6", "ORD-5", "ORD-4", "ORD-3", "ORD-2", "ORD-1"}; !slices.Equal(ids, want) {
+		t.Fatalf("orders = %v, want %v (newest first, none of bob's)", ids, want)
+	}
+	wantCounts := map[OrderStatus]int{Confirmed: 3, Delivered: 1, Cancelled: 1, Refunded: 1, Recieved: 1}
+	for s, n := range wantCounts {
+		if h.byStatus[s] != n {
+			t.Errorf("byStatus[%s] = %d, want %d", s, h.byStatus[s], n)
+		}
+	}
+	if len(h.byStatus) != len(wantCounts) {
+		t.Errorf("byStatus = %v, want %v", h.byStatus, wantCounts)
+	}
+	wantSpend := map[string]string{"INR": "350.00 INR", "USD": "12.50 USD"}
+	if len(h.lifetimeSpend) != len(wantSpend) {
+		t.Fatalf("lifetimeSpend = %v, want %v", h.lifetimeSpend, wantSpend)
+	}
+	for cur, want := range wantSpend {
+		if got := h.lifetimeSpend[cur]; !got.Equal(money.MustParse(want)) {
+			t.Errorf("lifetimeSpend[%s] = %s, want %s", cur, got, want)
+		}
+	}
+
+	if h, err := (&customer{id: "CUS-9"}).history(repo); err != nil || len(h.orders) != 0 {
+		t.Fatalf("history of a customer without orders = %v, %v", h.orders, err)
+	}
+	if _, err := (&customer{name: "Walk-in"}).history(repo); !errors.Is(err, ErrInvalidCustomer) {
+		t.Fatalf("history without an id = %v, want ErrInvalidCustomer", err)
+	}
+}
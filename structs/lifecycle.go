@@ -0,0 +1,168 @@
+package main
+
+// ── Order Lifecycle (a State Machine) ─────────────────────────────────────────
+// An order moves through a fixed set of statuses, and only some moves make
+// sense: a delivered order cannot go back to "recieved". This file encodes
+// those rules so changeStatus can refuse anything else.
+//
+//	recieved ──► confirmed ──► prepared ──► delivered ──► refunded
+//	    │            │             │
+//	    └────────────┴─────────────┴──────► cancelled
+//
+// The values, "recieved" spelling included, are the ones the OrderStatus enum
+// in Enums/enums.go uses. Each directory is its own program (package main),
+// so the type cannot be shared; instead it gets the same checks at every
+// boundary: ParseOrderStatus for text, and JSON that rejects unknown values.
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// OrderStatus is a TYPED string — a plain string is not an OrderStatus, so
+// changeStatus("shipped") no longer compiles unless converted on purpose.
+type OrderStatus string
+
+const (
+	Recieved  OrderStatus = "recieved"
+	Confirmed OrderStatus = "confirmed"
+	Prepared  OrderStatus = "prepared"
+	Delivered OrderStatus = "delivered"
+	Cancelled OrderStatus = "cancelled" // terminal — the order will not be fulfilled
+	Refunded  OrderStatus = "refunded"  // terminal — the customer got their money back
+)
+
+// transitions lists, for every status, the statuses it may move to.
+// A status with no entry (or an empty list) is TERMINAL.
+var transitions = map[OrderStatus][]OrderStatus{
+	Recieved:  {Confirmed, Cancelled},
+	Confirmed: {Prepared, Cancelled},
+	Prepared:  {Delivered, Cancelled},
+	Delivered: {Refunded},
+}
+
+// isValid reports whether s is one of the statuses above.
+func (s OrderStatus) isValid() bool {
+	switch s {
+	case Recieved, Confirmed, Prepared, Delivered, Cancelled, Refunded:
+		return true
+	}
+	return false
+}
+
+// ErrInvalidOrderStatus is returned for any value that is not one of the
+// statuses above, whether it comes from a string or from JSON.
+var ErrInvalidOrderStatus = errors.New("invalid order status")
+
+// ParseOrderStatus converts text to an OrderStatus. Case and surrounding
+// space are ignored; anything unknown is an error.
+func ParseOrderStatus(text string) (OrderStatus, error) {
+	s := OrderStatus(strings.ToLower(strings.TrimSpace(text)))
+	if !s.isValid() {
+		return "", fmt.Errorf("%w: %q", ErrInvalidOrderStatus, text)
+	}
+	return s, nil
+}
+
+// MarshalText implements encoding.TextMarshaler, which encoding/json uses
+// for the status field of stored orders. An invalid status is never written.
+func (s OrderStatus) MarshalText() ([]byte, error) {
+	if !s.isValid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidOrderStatus, string(s))
+	}
+	return []byte(s), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler with ParseOrderStatus,
+// so decoding JSON with an unknown status fails instead of producing an
+// order that no transition can move.
+func (s *OrderStatus) UnmarshalText(text []byte) error {
+	parsed, err := ParseOrderStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// canTransitionTo reports whether moving from s to next is allowed.
+func (s OrderStatus) canTransitionTo(next OrderStatus) bool {
+	return slices.Contains(transitions[s], next)
+}
+
+// ── Typed Error ───────────────────────────────────────────────────────────────
+// ErrIllegalTransition is the sentinel every *TransitionError matches:
+//
+//	errors.Is(err, ErrIllegalTransition) → "was it a bad move?"
+//	errors.As(err, &transitionErr)       → "which move exactly?"
+var ErrIllegalTransition = errors.New("illegal order status transition")
+
+// TransitionError says which move was refused.
+type TransitionError struct {
+	OrderID string
+	From    OrderStatus
+	To      OrderStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("order %s: %v: %s → %s", e.OrderID, ErrIllegalTransition, e.From, e.To)
+}
+
+// Is makes errors.Is(err, ErrIllegalTransition) true for a *TransitionError.
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrIllegalTransition
+}
+
+// ── History ───────────────────────────────────────────────────────────────────
+// statusChange is one entry in an order's history. The first entry of every
+// order has an empty From: it records the order being created.
+type statusChange struct {
+	From OrderStatus `json:",omitempty"` // left out for the creation entry
+	To   OrderStatus
+	At   time.Time
+}
+
+// ── Hooks ─────────────────────────────────────────────────────────────────────
+// transitionHook is a function run when an order leaves or enters a status,
+// e.g. "on entering refunded, pay the customer back".
+type transitionHook func(o *order, change statusChange)
+
+// lifecycle holds the hooks shared by a group of orders. A nil *lifecycle is
+// valid: the transition rules still apply, there are just no hooks.
+type lifecycle struct {
+	onExit  map[OrderStatus][]transitionHook
+	onEnter map[OrderStatus][]transitionHook
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{
+		onExit:  make(map[OrderStatus][]transitionHook),
+		onEnter: make(map[OrderStatus][]transitionHook),
+	}
+}
+
+// OnExit registers h to run whenever an order leaves status.
+func (lc *lifecycle) OnExit(status OrderStatus, h transitionHook) {
+	lc.onExit[status] = append(lc.onExit[status], h)
+}
+
+// OnEnter registers h to run whenever an order enters status.
+func (lc *lifecycle) OnEnter(status OrderStatus, h transitionHook) {
+	lc.onEnter[status] = append(lc.onEnter[status], h)
+}
+
+// run calls the exit hooks of change.From, then the enter hooks of change.To.
+func (lc *lifecycle) run(o *order, change statusChange) {
+	if lc == nil {
+		return
+	}
+	for _, h := range lc.onExit[change.From] {
+		h(o, change)
+	}
+	for _, h := range lc.onEnter[change.To] {
+		h(o, change)
+	}
+}
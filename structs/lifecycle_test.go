@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang/events"
+)
+
+func TestParseOrderStatus(t *testing.T) {
+	tests := []struct {
+		in   string
+		want OrderStatus
+		err  error
+	}{
+		{"confirmed", Confirmed, nil},
+		{"  Delivered ", Delivered, nil},
+		{"recieved", Recieved, nil},
+		{"received", "", ErrInvalidOrderStatus}, // the enum keeps the original spelling
+		{"shipped", "", ErrInvalidOrderStatus},
+		{"", "", ErrInvalidOrderStatus},
+	}
+	for _, tt := range tests {
+		got, err := ParseOrderStatus(tt.in)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("ParseOrderStatus(%q) = %q, %v; want %q, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestOrderStatusJSON(t *testing.T) {
+	type record struct {
+		Status OrderStatus `json:"status"`
+	}
+	tests := []struct {
+		in   string
+		want OrderStatus
+		err  bool
+	}{
+		{`{"status":"prepared"}`, Prepared, false},
+		{`{"status":"Refunded"}`, Refunded, false},
+		{`{"status":"shipped"}`, "", true},
+		{`{"status":""}`, "", true},
+		{`{"status":3}`, "", true},
+	}
+	for _, tt := range tests {
+		var r record
+		err := json.Unmarshal([]byte(tt.in), &r)
+		if (err != nil) != tt.err || r.Status != tt.want {
+			t.Errorf("Unmarshal(%s) = %q, %v; want %q, error %v", tt.in, r.Status, err, tt.want, tt.err)
+		}
+	}
+
+	if _, err := json.Marshal(record{Status: "shipped"}); !errors.Is(err, ErrInvalidOrderStatus) {
+		t.Errorf("Marshal of an unknown status = %v, want ErrInvalidOrderStatus", err)
+	}
+	data, err := json.Marshal(record{Status: Delivered})
+	if err != nil || string(data) != `{"status":"delivered"}` {
+		t.Errorf("Marshal(Delivered) = %s, %v", data, err)
+	}
+}
+
+func TestStatusHistoryJSON(t *testing.T) {
+	at := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	history := []statusChange{{To: Recieved, At: at}, {From: Recieved, To: Confirmed, At: at}}
+	data, err := json.Marshal(history)
+	if err != nil {
+		t.Fatalf("Marshal of a new order's history: %v", err)
+	}
+	var got []statusChange
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal(%s): %v", data, err)
+	}
+	if !slices.Equal(got, history) {
+		t.Fatalf("round trip = %+v, want %+v", got, history)
+	}
+}
+
+func TestTransitionNeedsItsEventRecorded(t *testing.T) {
+	bus := events.New(events.Options{})
+	var heard []string
+	bus.Subscribe("test", events.On(func(ctx context.Context, e events.StatusChanged, _ events.Envelope) error {
+		heard = append(heard, e.To)
+		return nil
+	}))
+	lc := newLifecycle()
+	hooks := 0
+	lc.OnEnter(Confirmed, func(*order, statusChange) { hooks++ })
+
+	o, err := newOrder("ORD-1", orderOptions{Events: bus, Lifecycle: lc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.changeStatus(Confirmed); err != nil {
+		t.Fatal(err)
+	}
+	bus.Close()
+
+	if err := o.changeStatus(Prepared); !errors.Is(err, events.ErrClosed) {
+		t.Fatalf("changeStatus with a closed bus = %v, want events.ErrClosed", err)
+	}
+	if o.status != Confirmed || len(o.history) != 2 || hooks != 1 || !slices.Equal(heard, []string{"confirmed"}) {
+		t.Fatalf("after a move the bus refused: status %s, %d history entries, %d hooks, heard %v; want confirmed, 2, 1, [confirmed]",
+			o.status, len(o.history), hooks, heard)
+	}
+	if _, err := newOrder("ORD-2", orderOptions{Events: bus}); !errors.Is(err, events.ErrClosed) {
+		t.Fatalf("newOrder with a closed bus = %v, want events.ErrClosed", err)
+	}
+}
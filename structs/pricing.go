@@ -0,0 +1,251 @@
+package main
+
+// ── Pricing: Line Items, Taxes and Coupons ────────────────────────────────────
+// An order's amount is no longer a number someone typed in — it is COMPUTED
+// from what is in the order:
+//
+//	subtotal = Σ quantity × unit price              (per line item)
+//	discount = percentage coupons, then fixed ones  (never below zero)
+//	tax      = Σ rate(category, region) × (line − its share of the discount)
+//	total    = subtotal − discount + tax
+//
+// Every step works in exact minor units (money.Money). Wherever a fraction
+// of a paisa appears it is rounded half-to-even, and a discount is spread
+// over the lines with money.Allocate, so the same order always prices to
+// the same total, to the paisa.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang/money"
+)
+
+// defaultCurrency is the currency of an order created without one.
+const defaultCurrency = "INR"
+
+// Pricing errors.
+var (
+	ErrInvalidItem        = errors.New("invalid line item")
+	ErrCurrencyMismatch   = errors.New("all prices in an order must share one currency")
+	ErrDuplicateCoupon    = errors.New("coupon already applied")
+	ErrCouponNotStackable = errors.New("coupon cannot be combined with other coupons")
+	ErrInvalidCoupon      = errors.New("invalid coupon")
+	ErrNoTaxRule          = errors.New("no tax rule matches")
+	ErrOrderLocked        = errors.New("order can no longer be edited")
+)
+
+// lineItem is one product in an order.
+type lineItem struct {
+	sku       string      // stock keeping unit, e.g. "PZ-MARG-L"
+	name      string      // shown on the receipt
+	category  string      // picks the tax rule, e.g. "food"
+	quantity  int64       // how many
+	unitPrice money.Money // price of one, before tax
+}
+
+// total returns quantity × unit price.
+func (li lineItem) total() (money.Money, error) {
+	return li.unitPrice.Mul(li.quantity)
+}
+
+// ── Taxes ─────────────────────────────────────────────────────────────────────
+// taxRule is a tax rate for a category in a region. An empty Category or
+// Region matches any. Rates are in BASIS POINTS (1/100 of a percent), so
+// 18% GST is 1800 — an integer, never a float.
+type taxRule struct {
+	category string
+	region   string
+	bips     int64
+}
+
+// taxTable is a list of rules. For each line the MOST SPECIFIC matching rule
+// wins (category and region > one of them > neither); on a tie, the earlier
+// rule wins.
+type taxTable []taxRule
+
+// rate returns the rate for category in region. An empty table means no tax.
+func (t taxTable) rate(category, region string) (int64, error) {
+	if len(t) == 0 {
+		return 0, nil
+	}
+	best, bestScore := int64(0), -1
+	for _, r := range t {
+		if (r.category != "" && r.category != category) || (r.region != "" && r.region != region) {
+			continue
+		}
+		score := 0
+		if r.category != "" {
+			score += 2 // a category rule beats a region-wide one
+		}
+		if r.region != "" {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = r.bips, score
+		}
+	}
+	if bestScore < 0 {
+		return 0, fmt.Errorf("%w: category %q in region %q", ErrNoTaxRule, category, region)
+	}
+	return best, nil
+}
+
+// ── Coupons ───────────────────────────────────────────────────────────────────
+type couponKind int
+
+const (
+	percentOff couponKind = iota // value in bips: 1000 = 10% off
+	fixedOff                     // a fixed amount off
+)
+
+// coupon is a discount code.
+//
+// Stacking rules: a stackable coupon combines with other stackable ones; a
+// non-stackable coupon must be the ONLY coupon on the order. Percentage
+// coupons are applied first, each to what is left after the previous one,
+// then fixed ones; the discount never exceeds the subtotal.
+type coupon struct {
+	code      string
+	kind      couponKind
+	bips      int64       // for percentOff
+	amount    money.Money // for fixedOff
+	stackable bool
+}
+
+// ── Receipt ───────────────────────────────────────────────────────────────────
+// receiptLine is one line of an itemized receipt.
+type receiptLine struct {
+	item     lineItem
+	total    money.Money // quantity × unit price
+	discount money.Money // this line's share of the order discount
+	tax      money.Money
+}
+
+// receipt is the fully priced order.
+type receipt struct {
+	lines    []receiptLine
+	coupons  []string
+	subtotal money.Money
+	discount money.Money
+	tax      money.Money
+	total    money.Money
+}
+
+// priceOrder computes the receipt for items, coupons and taxes in region.
+// Every price must be in currency; an order without items prices to zero in
+// it, so even an empty cart has a total that says what it is counted in.
+func priceOrder(currency string, items []lineItem, coupons []coupon, taxes taxTable, region string) (receipt, error) {
+	zero, err := money.Zero(currency)
+	if err != nil {
+		return receipt{}, err
+	}
+
+	r := receipt{subtotal: zero, discount: zero, tax: zero, total: zero}
+	if len(items) == 0 {
+		return r, nil
+	}
+	weights := make([]int64, len(items))
+	for i, item := range items {
+		if item.unitPrice.Currency() != currency {
+			return receipt{}, fmt.Errorf("%w: %s item in a %s order", ErrCurrencyMismatch, item.unitPrice.Currency(), currency)
+		}
+		total, err := item.total()
+		if err != nil {
+			return receipt{}, err
+		}
+		if r.subtotal, err = r.subtotal.Add(total); err != nil {
+			return receipt{}, err
+		}
+		r.lines = append(r.lines, receiptLine{item: item, total: total, discount: zero, tax: zero})
+		weights[i] = total.Minor()
+	}
+
+	if r.discount, err = discountFor(r.subtotal, coupons); err != nil {
+		return receipt{}, err
+	}
+	for _, c := range coupons {
+		r.coupons = append(r.coupons, c.code)
+	}
+	if r.discount.IsPositive() {
+		shares, err := r.discount.Allocate(weights...)
+		if err != nil {
+			return receipt{}, err
+		}
+		for i := range r.lines {
+			r.lines[i].discount = shares[i]
+		}
+	}
+
+	for i := range r.lines {
+		line := &r.lines[i]
+		taxable, err := line.total.Sub(line.discount)
+		if err != nil {
+			return receipt{}, err
+		}
+		rate, err := taxes.rate(line.item.category, region)
+		if err != nil {
+			return receipt{}, err
+		}
+		if line.tax, err = taxable.MulRat(rate, 10_000); err != nil {
+			return receipt{}, err
+		}
+		if r.tax, err = r.tax.Add(line.tax); err != nil {
+			return receipt{}, err
+		}
+	}
+
+	if r.total, err = r.subtotal.Sub(r.discount); err != nil {
+		return receipt{}, err
+	}
+	if r.total, err = r.total.Add(r.tax); err != nil {
+		return receipt{}, err
+	}
+	return r, nil
+}
+
+// discountFor applies coupons to subtotal: percentages first, then fixed
+// amounts, capped so the discount never exceeds the subtotal.
+func discountFor(subtotal money.Money, coupons []coupon) (money.Money, error) {
+	remaining := subtotal
+	for _, kind := range []couponKind{percentOff, fixedOff} {
+		for _, c := range coupons {
+			if c.kind != kind {
+				continue
+			}
+			off := c.amount
+			if kind == percentOff {
+				var err error
+				if off, err = remaining.MulRat(c.bips, 10_000); err != nil {
+					return money.Money{}, err
+				}
+			}
+			if cmp, err := off.Compare(remaining); err != nil {
+				return money.Money{}, err
+			} else if cmp > 0 {
+				off = remaining // cap: the order cannot go below zero
+			}
+			var err error
+			if remaining, err = remaining.Sub(off); err != nil {
+				return money.Money{}, err
+			}
+		}
+	}
+	return subtotal.Sub(remaining)
+}
+
+// String formats r as an itemized receipt.
+func (r receipt) String() string {
+	var b strings.Builder
+	for _, l := range r.lines {
+		fmt.Fprintf(&b, "%-10s %-22s %3d × %12s %14s\n", l.item.sku, l.item.name, l.item.quantity, l.item.unitPrice, l.total)
+	}
+	fmt.Fprintf(&b, "%50s %14s\n", "subtotal", r.subtotal)
+	if r.discount.IsPositive() {
+		fmt.Fprintf(&b, "%50s %14s\n", "discount ("+strings.Join(r.coupons, ", ")+")", r.discount.Neg())
+	}
+	fmt.Fprintf(&b, "%50s %14s\n", "tax", r.tax)
+	fmt.Fprintf(&b, "%50s %14s", "TOTAL", r.total)
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/money"
+)
+
+func TestPriceOrder(t *testing.T) {
+	gst := taxTable{{bips: 1800}, {category: "food", bips: 500}}
+	tea := lineItem{sku: "TEA-01", category: "food", quantity: 4, unitPrice: money.MustParse("15.00 INR")}
+	mug := lineItem{sku: "MUG-LOGO", quantity: 1, unitPrice: money.MustParse("199.99 INR")}
+	tests := []struct {
+		name     string
+		currency string
+		items    []lineItem
+		coupons  []coupon
+		taxes    taxTable
+		want     string
+		err      error
+	}{
+		{"empty order", "INR", nil, nil, gst, "0.00 INR", nil},
+		{"empty order in yen", "JPY", nil, nil, gst, "0 JPY", nil},
+		{"food at 5%", "INR", []lineItem{tea}, nil, gst, "63.00 INR", nil},
+		{"percentage then fixed", "INR", []lineItem{tea, mug}, []coupon{
+			{code: "FLAT50", kind: fixedOff, amount: money.MustParse("50.00 INR")},
+			{code: "WELCOME10", kind: percentOff, bips: 1000},
+		}, gst, "211.59 INR", nil},
+		{"discount capped at the subtotal", "INR", []lineItem{tea}, []coupon{
+			{code: "FLAT500", kind: fixedOff, amount: money.MustParse("500.00 INR")},
+		}, gst, "0.00 INR", nil},
+		{"item in another currency", "USD", []lineItem{tea}, nil, gst, "", ErrCurrencyMismatch},
+		{"unknown currency", "XYZ", nil, nil, gst, "", money.ErrUnknownCurrency},
+		{"no tax rule", "INR", []lineItem{tea}, nil, taxTable{{category: "merch", bips: 1800}}, "", ErrNoTaxRule},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := priceOrder(tt.currency, tt.items, tt.coupons, tt.taxes, "KA")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("priceOrder = %v, want %v", err, tt.err)
+			}
+			if err == nil && !r.total.Equal(money.MustParse(tt.want)) {
+				t.Fatalf("total = %s, want %s", r.total, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderAmount(t *testing.T) {
+	usd, _ := newOrder("ORD-1", orderOptions{Currency: "USD"})
+	if got, err := usd.getAmount(); err != nil || !got.Equal(money.MustParse("0.00 USD")) {
+		t.Fatalf("empty USD order: getAmount = %s, %v; want 0.00 USD", got, err)
+	}
+	if err := usd.addItem(lineItem{sku: "TEA-01", quantity: 1, unitPrice: money.MustParse("15.00 INR")}); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("INR item in a USD order: addItem = %v, want ErrCurrencyMismatch", err)
+	}
+	inr, _ := newOrder("ORD-2", orderOptions{})
+	if got, _ := inr.getAmount(); got.Currency() != defaultCurrency {
+		t.Fatalf("empty default order is in %s, want %s", got.Currency(), defaultCurrency)
+	}
+
+	// An order that skipped addItem, e.g. one built by hand or loaded from
+	// a damaged file, reports the pricing error instead of a zero total.
+	bad := order{items: []lineItem{{sku: "TEA-01", category: "food", quantity: 1, unitPrice: money.MustParse("15.00 INR")}}, taxes: taxTable{{category: "merch", bips: 1800}}}
+	if _, err := bad.getAmount(); !errors.Is(err, ErrNoTaxRule) {
+		t.Fatalf("getAmount of an unpriceable order = %v, want ErrNoTaxRule", err)
+	}
+}
@@ -0,0 +1,443 @@
+package main
+
+// ── Order Repository ──────────────────────────────────────────────────────────
+// newOrder only builds a value in memory; this file makes orders OUTLIVE the
+// program. OrderRepository is an interface with two implementations:
+//
+//	memoryOrderRepository  a map — for demos and tests
+//	fileOrderRepository    an append-only JSON-lines file, one snapshot per Save
+//
+// Why append-only? Each Save writes ONE new line at the end of the file and
+// syncs it to disk; nothing already written is ever touched. If the program
+// crashes half-way through a write, only that last line is damaged, and
+// opening the file again simply cuts it off. Replaying the lines in order
+// gives every order's latest snapshot.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/money"
+)
+
+// Repository errors.
+var (
+	ErrOrderNotFound    = errors.New("order not found")
+	ErrRepositoryFailed = errors.New("order repository failed") // a torn write could not be undone
+)
+
+// OrderRepository stores orders by ID.
+type OrderRepository interface {
+	// Save stores a snapshot of o, replacing any earlier one with its ID.
+	Save(o *order) error
+	// Get returns the latest snapshot of the order with id.
+	Get(id string) (*order, error)
+	// List returns the orders matching q, oldest first, together with the
+	// number of matches before pagination.
+	List(q orderQuery) (orders []*order, total int, err error)
+}
+
+// orderQuery filters and pages List. Zero fields do not filter.
+type orderQuery struct {
+	CustomerID  string      // the customer's id
+	Customer    string      // exact customer name (case-insensitive)
+	Status      OrderStatus // current status
+	CreatedFrom time.Time   // createdAt ≥ CreatedFrom
+	CreatedTo   time.Time   // createdAt < CreatedTo
+	Offset      int         // matches to skip
+	Limit       int         // max orders to return; 0 means all
+}
+
+func (q orderQuery) matches(r orderRecord) bool {
+	switch {
+	case q.CustomerID != "" && q.CustomerID != r.CustomerID:
+		return false
+	case q.Customer != "" && !strings.EqualFold(q.Customer, r.Customer):
+		return false
+	case q.Status != "" && q.Status != r.Status:
+		return false
+	case !q.CreatedFrom.IsZero() && r.CreatedAt.Before(q.CreatedFrom):
+		return false
+	case !q.CreatedTo.IsZero() && !r.CreatedAt.Before(q.CreatedTo):
+		return false
+	}
+	return true
+}
+
+// ── Records ───────────────────────────────────────────────────────────────────
+// order's fields are unexported, so encoding/json cannot see them. These
+// record types are the on-disk shape, with explicit JSON names.
+//
+// The customer is stored in full, as the order had it: a reloaded order can
+// still be shipped and billed even if the address book has changed since.
+// Records written before that have only customer_id and customer.
+type orderRecord struct {
+	ID              string          `json:"id"`
+	CustomerID      string          `json:"customer_id,omitempty"`
+	Customer        string          `json:"customer"`
+	CustomerEmail   string          `json:"customer_email,omitempty"`
+	CustomerPhone   string          `json:"customer_phone,omitempty"`
+	Addresses       []addressRecord `json:"addresses,omitempty"`
+	DefaultShipping string          `json:"default_shipping,omitempty"` // an address label
+	DefaultBilling  string          `json:"default_billing,omitempty"`
+	Currency        string          `json:"currency,omitempty"` // older records: the items' currency
+	Items           []itemRecord    `json:"items"`
+	Coupons         []couponRecord  `json:"coupons,omitempty"`
+	Region          string          `json:"region,omitempty"`
+	Taxes           []taxRecord     `json:"taxes,omitempty"`
+	Status          OrderStatus     `json:"status"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	History         []statusChange  `json:"history"`
+	Audit           []auditEntry    `json:"audit,omitempty"`
+}
+
+type addressRecord struct {
+	Label      string `json:"label"`
+	Shipping   bool   `json:"shipping,omitempty"`
+	Billing    bool   `json:"billing,omitempty"`
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city"`
+	Region     string `json:"region,omitempty"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+}
+
+type itemRecord struct {
+	SKU       string      `json:"sku"`
+	Name      string      `json:"name"`
+	Category  string      `json:"category,omitempty"`
+	Quantity  int64       `json:"quantity"`
+	UnitPrice money.Money `json:"unit_price"` // "349.00 INR"
+}
+
+type couponRecord struct {
+	Code      string      `json:"code"`
+	Percent   bool        `json:"percent"`
+	Bips      int64       `json:"bips,omitempty"`
+	Amount    money.Money `json:"amount,omitzero"`
+	Stackable bool        `json:"stackable"`
+}
+
+type taxRecord struct {
+	Category string `json:"category,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Bips     int64  `json:"bips"`
+}
+
+// toRecord takes a deep snapshot of o.
+func toRecord(o *order) orderRecord {
+	r := orderRecord{
+		ID:              o.id,
+		CustomerID:      o.customer.id,
+		Customer:        o.name,
+		CustomerEmail:   o.email,
+		CustomerPhone:   o.phone,
+		DefaultShipping: o.defaultShipping,
+		DefaultBilling:  o.defaultBilling,
+		Currency:        o.currency,
+		Region:          o.region,
+		Status:          o.status,
+		CreatedAt:       o.createdAt,
+		UpdatedAt:       o.updatedAt,
+		History:         slices.Clone(o.history),
+		Audit:           slices.Clone(o.audit),
+	}
+	for _, a := range o.addresses {
+		r.Addresses = append(r.Addresses, addressRecord{
+			a.label, a.kinds&shippingAddress != 0, a.kinds&billingAddress != 0,
+			a.line1, a.line2, a.city, a.region, a.postalCode, a.country,
+		})
+	}
+	for _, it := range o.items {
+		r.Items = append(r.Items, itemRecord{it.sku, it.name, it.category, it.quantity, it.unitPrice})
+	}
+	for _, c := range o.coupons {
+		r.Coupons = append(r.Coupons, couponRecord{c.code, c.kind == percentOff, c.bips, c.amount, c.stackable})
+	}
+	for _, t := range o.taxes {
+		r.Taxes = append(r.Taxes, taxRecord{t.category, t.region, t.bips})
+	}
+	return r
+}
+
+// toOrder rebuilds an order from a record. Hooks are not stored; attach a
+// lifecycle again if the order needs them.
+func (r orderRecord) toOrder() *order {
+	o := &order{
+		id:        r.ID,
+		currency:  r.Currency,
+		region:    r.Region,
+		status:    r.Status,
+		createdAt: r.CreatedAt,
+		updatedAt: r.UpdatedAt,
+		history:   slices.Clone(r.History),
+		audit:     slices.Clone(r.Audit),
+	}
+	o.customer = customer{
+		id:              r.CustomerID,
+		name:            r.Customer,
+		email:           r.CustomerEmail,
+		phone:           r.CustomerPhone,
+		defaultShipping: r.DefaultShipping,
+		defaultBilling:  r.DefaultBilling,
+	}
+	for _, a := range r.Addresses {
+		var kinds addressKind
+		if a.Shipping {
+			kinds |= shippingAddress
+		}
+		if a.Billing {
+			kinds |= billingAddress
+		}
+		o.addresses = append(o.addresses, address{a.Label, kinds, a.Line1, a.Line2, a.City, a.Region, a.PostalCode, a.Country})
+	}
+	for _, it := range r.Items {
+		o.items = append(o.items, lineItem{it.SKU, it.Name, it.Category, it.Quantity, it.UnitPrice})
+	}
+	for _, c := range r.Coupons {
+		kind := fixedOff
+		if c.Percent {
+			kind = percentOff
+		}
+		o.coupons = append(o.coupons, coupon{c.Code, kind, c.Bips, c.Amount, c.Stackable})
+	}
+	for _, t := range r.Taxes {
+		o.taxes = append(o.taxes, taxRule{t.Category, t.Region, t.Bips})
+	}
+	return o
+}
+
+// validate rejects records no order could have produced.
+func (r orderRecord) validate() error {
+	if r.ID == "" {
+		return errors.New("order record without id")
+	}
+	if !r.Status.isValid() {
+		return fmt.Errorf("order %s: %w: %q", r.ID, ErrInvalidOrderStatus, r.Status)
+	}
+	return nil
+}
+
+// ── In-Memory Implementation ──────────────────────────────────────────────────
+// memoryOrderRepository keeps snapshots in a map. It is safe for concurrent use.
+type memoryOrderRepository struct {
+	mu      sync.RWMutex
+	records map[string]orderRecord
+}
+
+func newMemoryOrderRepository() *memoryOrderRepository {
+	return &memoryOrderRepository{records: make(map[string]orderRecord)}
+}
+
+// Save implements OrderRepository.
+func (m *memoryOrderRepository) Save(o *order) error {
+	r := toRecord(o)
+	if err := r.validate(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[r.ID] = r
+	return nil
+}
+
+// Get implements OrderRepository.
+func (m *memoryOrderRepository) Get(id string) (*order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	r, ok := m.records[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrOrderNotFound, id)
+	}
+	return r.toOrder(), nil
+}
+
+// List implements OrderRepository.
+func (m *memoryOrderRepository) List(q orderQuery) ([]*order, int, error) {
+	m.mu.RLock()
+	var matched []orderRecord
+	for _, r := range m.records {
+		if q.matches(r) {
+			matched = append(matched, r)
+		}
+	}
+	m.mu.RUnlock()
+
+	// Oldest first; ties broken by ID so pages are stable.
+	slices.SortFunc(matched, func(a, b orderRecord) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	total := len(matched)
+	start := min(max(q.Offset, 0), total)
+	end := total
+	if q.Limit > 0 {
+		end = min(start+q.Limit, total)
+	}
+	orders := make([]*order, 0, end-start)
+	for _, r := range matched[start:end] {
+		orders = append(orders, r.toOrder())
+	}
+	return orders, total, nil
+}
+
+// ── Append-Only File Implementation ───────────────────────────────────────────
+// logFile is what fileOrderRepository needs from its file. *os.File is one;
+// tests wrap it to make writes fail half-way.
+type logFile interface {
+	io.Writer
+	io.Seeker
+	io.Closer
+	Sync() error
+	Truncate(size int64) error
+}
+
+// fileOrderRepository appends every Save to a JSON-lines file and keeps an
+// in-memory index for queries. It is safe for concurrent use.
+type fileOrderRepository struct {
+	index *memoryOrderRepository
+
+	mu     sync.Mutex // serializes appends
+	file   logFile
+	size   int64 // offset just past the last complete line
+	failed error // set when a torn append could not be cut off; every later Save returns it
+}
+
+// openFileOrderRepository opens (or creates) the log at path and replays it.
+// A damaged LAST line — a write cut short by a crash — is cut off; damage
+// anywhere else is reported as an error, since it is not a crash artefact.
+func openFileOrderRepository(path string) (*fileOrderRepository, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	index := newMemoryOrderRepository()
+	good, err := replay(f, index)
+	if err == nil {
+		err = f.Truncate(good) // drop a torn final line, if any
+	}
+	if err == nil {
+		_, err = f.Seek(good, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &fileOrderRepository{index: index, file: f, size: good}, nil
+}
+
+// replay loads every complete, valid line of f into index and returns the
+// offset just past the last one.
+func replay(f *os.File, index *memoryOrderRepository) (int64, error) {
+	rd := bufio.NewReader(f)
+	var offset int64
+	for lineNo := 1; ; lineNo++ {
+		line, err := rd.ReadBytes('\n')
+		if err == io.EOF {
+			return offset, nil // anything in line has no newline: a torn write
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		var r orderRecord
+		err = json.Unmarshal(bytes.TrimSpace(line), &r)
+		if err == nil {
+			err = r.validate()
+		}
+		if err != nil {
+			if _, peekErr := rd.Peek(1); peekErr == io.EOF {
+				return offset, nil // damaged last line: cut it off
+			}
+			return 0, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		index.records[r.ID] = r // no lock needed: index is not shared yet
+		offset += int64(len(line))
+	}
+}
+
+// Save implements OrderRepository. The snapshot is on disk (fsynced) before
+// Save returns.
+//
+// A Write or Sync that fails may leave part of the line in the file, and the
+// next Save would glue its line onto that fragment. So on error the file is
+// truncated back to where the line started. If even that fails, the
+// repository stops accepting writes: reopening it cuts the torn line off.
+func (fr *fileOrderRepository) Save(o *order) error {
+	r := toRecord(o)
+	if err := r.validate(); err != nil {
+		return err
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if fr.failed != nil {
+		return fr.failed
+	}
+	line = append(line, '\n')
+	_, err = fr.file.Write(line)
+	if err == nil {
+		err = fr.file.Sync()
+	}
+	if err != nil {
+		fr.rollback()
+		return err
+	}
+	fr.size += int64(len(line))
+	fr.index.mu.Lock()
+	fr.index.records[r.ID] = r
+	fr.index.mu.Unlock()
+	return nil
+}
+
+// rollback cuts the file back to the end of the last complete line, or
+// marks the repository failed if it cannot. fr.mu must be held.
+func (fr *fileOrderRepository) rollback() {
+	err := fr.file.Truncate(fr.size)
+	if err == nil {
+		_, err = fr.file.Seek(fr.size, io.SeekStart)
+	}
+	if err != nil {
+		fr.failed = fmt.Errorf("%w: %w", ErrRepositoryFailed, err)
+	}
+}
+
+// Get implements OrderRepository.
+func (fr *fileOrderRepository) Get(id string) (*order, error) {
+	return fr.index.Get(id)
+}
+
+// List implements OrderRepository.
+func (fr *fileOrderRepository) List(q orderQuery) ([]*order, int, error) {
+	return fr.index.List(q)
+}
+
+// Close closes the file.
+func (fr *fileOrderRepository) Close() error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return fr.file.Close()
+}
+
+// Compile-time checks that both types satisfy the interface.
+var (
+	_ OrderRepository = (*memoryOrderRepository)(nil)
+	_ OrderRepository = (*fileOrderRepository)(nil)
+)
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/money"
+)
+
+// faultyFile wraps the log file and fails the next Write (after writing half
+// of it), Sync or Truncate on request.
+type faultyFile struct {
+	*os.File
+	failWrite, failSync, failTruncate bool
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (f *faultyFile) Write(p []byte) (int, error) {
+	if f.failWrite {
+		f.failWrite = false
+		n, _ := f.File.Write(p[:len(p)/2])
+		return n, errDiskFull
+	}
+	return f.File.Write(p)
+}
+
+func (f *faultyFile) Sync() error {
+	if f.failSync {
+		f.failSync = false
+		return errDiskFull
+	}
+	return f.File.Sync()
+}
+
+func (f *faultyFile) Truncate(size int64) error {
+	if f.failTruncate {
+		return errDiskFull
+	}
+	return f.File.Truncate(size)
+}
+
+func testOrder(t *testing.T, id string) *order {
+	t.Helper()
+	at := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	o, err := newOrder(id, orderOptions{Now: func() time.Time { return at }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.addItem(lineItem{sku: "TEA-01", name: "Masala chai", quantity: 2, unitPrice: money.MustParse("15.00 INR")}); err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func openTestRepository(t *testing.T, path string) *fileOrderRepository {
+	t.Helper()
+	repo, err := openFileOrderRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+func TestFileRepositoryUndoesFailedAppends(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault func(*faultyFile)
+	}{
+		{"short write", func(f *faultyFile) { f.failWrite = true }},
+		{"failed sync", func(f *faultyFile) { f.failSync = true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "orders.jsonl")
+			repo := openTestRepository(t, path)
+			if err := repo.Save(testOrder(t, "ORD-1")); err != nil {
+				t.Fatal(err)
+			}
+			file := &faultyFile{File: repo.file.(*os.File)}
+			repo.file = file
+
+			tt.fault(file)
+			if err := repo.Save(testOrder(t, "ORD-2")); !errors.Is(err, errDiskFull) {
+				t.Fatalf("Save = %v, want errDiskFull", err)
+			}
+			if _, err := repo.Get("ORD-2"); !errors.Is(err, ErrOrderNotFound) {
+				t.Fatalf("a failed Save was indexed: Get = %v", err)
+			}
+			if err := repo.Save(testOrder(t, "ORD-3")); err != nil {
+				t.Fatalf("Save after a rolled back failure: %v", err)
+			}
+
+			// Every line on disk is whole: reopening finds both orders and
+			// no damage in the middle of the file.
+			reopened := openTestRepository(t, path)
+			for _, id := range []string{"ORD-1", "ORD-3"} {
+				if _, err := reopened.Get(id); err != nil {
+					t.Errorf("after reopening: Get(%s) = %v", id, err)
+				}
+			}
+		})
+	}
+}
+
+func TestFileRepositoryFailsWhenRollbackFails(t *testing.T) {
+	repo := openTestRepository(t, filepath.Join(t.TempDir(), "orders.jsonl"))
+	file := &faultyFile{File: repo.file.(*os.File), failWrite: true, failTruncate: true}
+	repo.file = file
+
+	if err := repo.Save(testOrder(t, "ORD-1")); !errors.Is(err, errDiskFull) {
+		t.Fatalf("Save = %v, want errDiskFull", err)
+	}
+	file.failTruncate = false
+	if err := repo.Save(testOrder(t, "ORD-2")); !errors.Is(err, ErrRepositoryFailed) {
+		t.Fatalf("Save after a torn line was left behind = %v, want ErrRepositoryFailed", err)
+	}
+}
+
+func TestFileRepositoryReplay(t *testing.T) {
+	tests := []struct {
+		name    string
+		tail    string // written after two good lines
+		wantErr bool
+	}{
+		{"clean", "", false},
+		{"torn last line", `{"id":"ORD-9","stat`, false},
+		{"damaged last line", "{not json}\n", false},
+		{"damage in the middle", "{not json}\n" + `{"id":"ORD-9","status":"recieved"}` + "\n", true},
+		{"unknown status in the middle", `{"id":"ORD-9","status":"shipped"}` + "\n" + `{"id":"ORD-8","status":"recieved"}` + "\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "orders.jsonl")
+			repo := openTestRepository(t, path)
+			for _, id := range []string{"ORD-1", "ORD-2"} {
+				if err := repo.Save(testOrder(t, id)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			repo.Close()
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.WriteString(tt.tail)
+			f.Close()
+
+			reopened, err := openFileOrderRepository(path)
+			if tt.wantErr {
+				if err == nil {
+					reopened.Close()
+					t.Fatal("damage in the middle of the file was not reported")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reopened.Close()
+			if _, total, _ := reopened.List(orderQuery{}); total != 2 {
+				t.Fatalf("%d orders after replay, want 2", total)
+			}
+			// The next Save starts on a fresh line.
+			if err := reopened.Save(testOrder(t, "ORD-3")); err != nil {
+				t.Fatal(err)
+			}
+			again := openTestRepository(t, path)
+			if _, total, _ := again.List(orderQuery{}); total != 3 {
+				t.Fatalf("%d orders after saving and reopening, want 3", total)
+			}
+		})
+	}
+}
+
+func TestRepositoriesKeepTheCustomer(t *testing.T) {
+	anurag := &customer{
+		id:    "CUS-1",
+		name:  "Anurag",
+		email: "anurag@example.com",
+		phone: "+91 98450 12345",
+		addresses: []address{
+			{label: "home", kinds: shippingAddress | billingAddress, line1: "12 MG Road", city: "Bengaluru", region: "KA", postalCode: "560001", country: "IN"},
+			{label: "office", kinds: shippingAddress, line1: "4th Floor", line2: "Tech Park", city: "Panaji", region: "GOA", postalCode: "403001", country: "IN"},
+		},
+		defaultShipping: "office",
+		defaultBilling:  "home",
+	}
+	path := filepath.Join(t.TempDir(), "orders.jsonl")
+	file := openTestRepository(t, path)
+	repos := []struct {
+		name   string
+		repo   OrderRepository
+		reopen func() OrderRepository
+	}{
+		{"memory", newMemoryOrderRepository(), nil},
+		{"file", file, func() OrderRepository { file.Close(); return openTestRepository(t, path) }},
+	}
+	for _, tt := range repos {
+		t.Run(tt.name, func(t *testing.T) {
+			o, _ := newOrder("ORD-1", orderOptions{Customer: anurag})
+			if err := tt.repo.Save(o); err != nil {
+				t.Fatal(err)
+			}
+			repo := tt.repo
+			if tt.reopen != nil {
+				repo = tt.reopen()
+			}
+			saved, err := repo.Get("ORD-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(saved.customer, *anurag) {
+				t.Fatalf("reloaded customer = %+v, want %+v", saved.customer, *anurag)
+			}
+			if ship, ok := saved.defaultAddress(shippingAddress); !ok || ship.label != "office" {
+				t.Fatalf("reloaded default shipping address = %v, %v; want office", ship, ok)
+			}
+		})
+	}
+}
+
+func TestOldRecordsStillLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.jsonl")
+	line := `{"id":"ORD-1","customer_id":"CUS-1","customer":"Anurag","items":[{"sku":"TEA-01","name":"Masala chai","quantity":2,"unit_price":"15.00 INR"}],` +
+		`"status":"recieved","created_at":"2025-01-15T10:00:00Z","updated_at":"2025-01-15T10:00:00Z","history":[{"To":"recieved","At":"2025-01-15T10:00:00Z"}]}` + "\n"
+	if err := os.WriteFile(path, []byte(line), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	o, err := openTestRepository(t, path).Get("ORD-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	amount, err := o.getAmount()
+	if o.customer.id != "CUS-1" || o.name != "Anurag" || len(o.addresses) != 0 || err != nil || !amount.Equal(money.MustParse("30.00 INR")) {
+		t.Fatalf("old record loaded as customer %+v, amount %s, %v", o.customer, amount, err)
+	}
+}
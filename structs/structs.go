@@ -3,57 +3,238 @@ package main
 
 // NOTE: If you don't set any field in a struct, Go uses its zero value:
 //   string → ""   |   int/float → 0   |   bool → false   |   pointer → nil
+//
+// This program spans several files (lifecycle.go, pricing.go, repository.go,
+// ...), so run the whole directory:  go run ./structs
 
 // Import required packages
 import (
-	"fmt"  // "fmt" for printing output to the console
-	"time" // "time" for time-related types like time.Time and time.Now()
+	"context"       // "context" for publishing order events
+	"errors"        // "errors" for errors.Is / errors.As on typed errors
+	"fmt"           // "fmt" for printing output to the console
+	"os"            // "os" for the temporary directory the saved orders go in
+	"path/filepath" // "path/filepath" for joining the orders file path
+	"slices"        // "slices" for copying items, coupons and the status history
+	"strings"       // "strings" for comparing coupon codes
+	"time"          // "time" for time-related types like time.Time and time.Now()
+
+	"github.com/golang/events" // order events for the rest of the program (see packages/events)
+	"github.com/golang/money"  // exact decimal amounts (see packages/money)
+	"github.com/golang/rbac"   // role-based access policy (see packages/rbac)
+	"github.com/golang/user"   // user.User and user.Role (see packages/user)
 )
 
 // ── Struct Embedding ───────────────────────────────────────────────────────────
-// 'customer' is a simple struct with just a name field
+// 'customer' (see customer.go) is a struct with the customer's name, contact
+// details and addresses
 // Structs in Go are custom data types that group related fields together
-type customer struct {
-	name string // 'name' field of type string — stores the customer's name
-}
 
 // 'order' struct embeds the 'customer' struct (struct embedding = composition)
 // Embedding lets 'order' inherit the fields and methods of 'customer'
 // This is Go's way of achieving composition (not classical inheritance)
+// Both structs have an 'id': o.id is the order's (the shallower field wins),
+// the customer's is o.customer.id
 type order struct {
-	id        string    // unique identifier for the order
-	amount    float32   // order total amount as a 32-bit float
-	status    string    // current status of the order (e.g., "pending", "completed")
-	customer            // EMBEDDED struct — 'order' now has access to customer.name
-	createdAt time.Time // stores the date/time the order was created
+	id        string      // unique identifier for the order
+	currency  string      // every price in the order is in it, e.g. "INR"
+	items     []lineItem  // what was ordered — the amount is computed from these (see pricing.go)
+	coupons   []coupon    // discount codes applied to the order
+	region    string      // where the order is delivered — picks the tax rules
+	taxes     taxTable    // tax rates by category and region
+	status    OrderStatus // current status of the order (see lifecycle.go)
+	customer              // EMBEDDED struct — 'order' now has access to customer.name
+	createdAt time.Time   // stores the date/time the order was created
+	updatedAt time.Time   // when anything about the order last changed
+
+	history   []statusChange   // every status the order has been in, oldest first
+	audit     []auditEntry     // who changed which field, and when (see audit.go)
+	lifecycle *lifecycle       // hooks run on every status change (nil = none)
+	events    *events.Bus      // told about creation and status changes (nil = nobody)
+	now       func() time.Time // the order's clock (nil = time.Now)
+}
+
+// ── Options Struct ─────────────────────────────────────────────────────────────
+// orderOptions groups newOrder's optional settings. Any field left out gets
+// its zero value: prices in INR, no region, no taxes, no hooks, no events,
+// the real clock.
+type orderOptions struct {
+	Currency  string           // currency of every price in the order; "" means INR
+	Region    string           // delivery region, e.g. "KA"
+	Taxes     taxTable         // tax rules; nil means no tax
+	Lifecycle *lifecycle       // status-change hooks; nil means none
+	Events    *events.Bus      // where OrderCreated / StatusChanged go; nil means nowhere
+	Customer  *customer        // who the order is for; copied into the order
+	Now       func() time.Time // clock for every timestamp; nil means time.Now
 }
 
 // ── Constructor Function ───────────────────────────────────────────────────────
 // 'newOrder' is a constructor-style function that creates and returns an *order
 // Returning a pointer (*order) is efficient — avoids copying the entire struct
-func newOrder(id string, amount float32, status string) *order {
+// Every order starts out Recieved and empty; add items with addItem
+// Inject opts.Now to get predictable timestamps (in tests, or when replaying)
+// With opts.Events, the order exists only once OrderCreated is recorded: if
+// the bus cannot record it, newOrder returns the error and no order
+func newOrder(id string, opts orderOptions) (*order, error) {
+	clock := opts.Now
+	if clock == nil {
+		clock = time.Now
+	}
+	now := clock()
+	currency := opts.Currency
+	if currency == "" {
+		currency = defaultCurrency
+	}
 	// Create an 'order' value using field names (named initialization)
 	order := order{
-		id:     id,     // set the id field
-		amount: amount, // set the amount field
-		status: status, // set the status field
+		id:        id, // set the id field
+		currency:  currency,
+		region:    opts.Region,
+		taxes:     opts.Taxes,
+		status:    Recieved, // every order starts here
+		createdAt: now,
+		updatedAt: now,
+		history:   []statusChange{{To: Recieved, At: now}}, // creation is the first history entry
+		lifecycle: opts.Lifecycle,
+		events:    opts.Events,
+		now:       clock,
+	}
+	if opts.Customer != nil {
+		order.customer = opts.Customer.clone() // a snapshot: later address changes don't touch this order
+	}
+	if err := order.events.Publish(context.Background(), events.OrderCreated{OrderID: id, Customer: order.customer.id}); err != nil {
+		return nil, fmt.Errorf("order %s: %w", id, err)
+	}
+	return &order, nil // return a pointer to the order (& takes the address)
+}
+
+// ── Mutating Methods ──────────────────────────────────────────────────────────
+// addItem adds a line item. Items can only be added while the order is still
+// Recieved, must all be in one currency, and are checked by pricing the
+// order with them BEFORE anything is changed.
+func (o *order) addItem(item lineItem) error {
+	if o.status != Recieved {
+		return fmt.Errorf("%w: order %s is %s", ErrOrderLocked, o.id, o.status)
+	}
+	if item.sku == "" || item.quantity <= 0 || !item.unitPrice.IsPositive() {
+		return fmt.Errorf("%w: %+v", ErrInvalidItem, item)
+	}
+	items := append(slices.Clone(o.items), item)
+	if _, err := priceOrder(o.priceCurrency(), items, o.coupons, o.taxes, o.region); err != nil {
+		return err
+	}
+	o.record(o.clock(), systemActor, "items", itemList(o.items), itemList(items))
+	o.items = items
+	return nil
+}
+
+// applyCoupon adds a coupon, enforcing the stacking rules in pricing.go.
+func (o *order) applyCoupon(c coupon) error {
+	if o.status != Recieved {
+		return fmt.Errorf("%w: order %s is %s", ErrOrderLocked, o.id, o.status)
+	}
+	switch {
+	case c.code == "":
+		return fmt.Errorf("%w: empty code", ErrInvalidCoupon)
+	case c.kind == percentOff && (c.bips <= 0 || c.bips > 10_000):
+		return fmt.Errorf("%w: %s: percentage must be between 0 and 100", ErrInvalidCoupon, c.code)
+	case c.kind == fixedOff && !c.amount.IsPositive():
+		return fmt.Errorf("%w: %s: amount must be positive", ErrInvalidCoupon, c.code)
+	}
+	for _, applied := range o.coupons {
+		if strings.EqualFold(applied.code, c.code) {
+			return fmt.Errorf("%w: %s", ErrDuplicateCoupon, c.code)
+		}
+		if !applied.stackable || !c.stackable {
+			return fmt.Errorf("%w: %s with %s", ErrCouponNotStackable, c.code, applied.code)
+		}
+	}
+	coupons := append(slices.Clone(o.coupons), c)
+	if _, err := priceOrder(o.priceCurrency(), o.items, coupons, o.taxes, o.region); err != nil {
+		return err
 	}
-	return &order // return a pointer to the order (& takes the address)
+	o.record(o.clock(), systemActor, "coupons", couponList(o.coupons), couponList(coupons))
+	o.coupons = coupons
+	return nil
+}
+
+// receipt prices the order line by line.
+func (o *order) receipt() (receipt, error) {
+	return priceOrder(o.priceCurrency(), o.items, o.coupons, o.taxes, o.region)
+}
+
+// priceCurrency is the order's currency. An order built by hand rather than
+// by newOrder may not say; its items do, and an empty one is in INR.
+func (o *order) priceCurrency() string {
+	switch {
+	case o.currency != "":
+		return o.currency
+	case len(o.items) > 0:
+		return o.items[0].unitPrice.Currency()
+	}
+	return defaultCurrency
 }
 
 // ── Method with Pointer Receiver ──────────────────────────────────────────────
 // Methods in Go are attached to a type using a receiver
 // (o *order) is a POINTER RECEIVER — changes made inside this method affect the original struct
-// This method updates the 'status' field of an order
-func (o *order) changeStatus(status string) {
+// This method moves the order to 'status' — if the lifecycle allows it.
+// An illegal move (e.g. Delivered → Recieved) returns a *TransitionError and
+// leaves the order untouched — and so does a move its event bus cannot record.
+func (o *order) changeStatus(status OrderStatus) error {
+	return o.transition(systemActor, status)
+}
+
+// transition is changeStatus with the actor to record in the audit trail.
+func (o *order) transition(actor string, status OrderStatus) error {
+	if !o.status.canTransitionTo(status) {
+		return &TransitionError{OrderID: o.id, From: o.status, To: status}
+	}
+	change := statusChange{From: o.status, To: status, At: o.clock()}
+
+	// Tell the rest of the program FIRST. Publish only fails when the event
+	// could not be recorded, and then the order must not move either —
+	// otherwise the order and its events would disagree for good
+	if err := o.events.Publish(context.Background(), events.StatusChanged{
+		OrderID: o.id, From: string(change.From), To: string(change.To), Actor: actor,
+	}); err != nil {
+		return fmt.Errorf("order %s: %w", o.id, err)
+	}
+
 	o.status = status // modify the status of the order via its pointer
+	o.history = append(o.history, change)
+	o.record(change.At, actor, "status", string(change.From), string(change.To))
+	o.lifecycle.run(o, change) // exit hooks of the old status, enter hooks of the new one
+	return nil
+}
+
+// statusHistory returns a copy of the order's history, so callers cannot
+// rewrite it.
+func (o *order) statusHistory() []statusChange {
+	return slices.Clone(o.history)
+}
+
+// ── Guarded Method ────────────────────────────────────────────────────────────
+// changeStatusAs is changeStatus for a specific actor: only users whose roles
+// grant "update:orders" in the access policy may move an order along.
+// It returns an error (wrapping rbac.ErrForbidden) instead of changing anything
+func (o *order) changeStatusAs(actor user.User, policy *rbac.Engine, status OrderStatus) error {
+	if err := policy.Require(actor, "update", "orders"); err != nil {
+		return err // refuse — the status stays as it was
+	}
+	return o.transition(actor.Email, status) // the audit trail names the user
 }
 
 // ── Method with Pointer Receiver (Getter) ─────────────────────────────────────
-// This method returns the 'amount' field of the order
-// Using a pointer receiver is consistent and allows future mutation if needed
-func (o *order) getAmount() float32 {
-	return o.amount // read and return the amount value
+// This method computes the order total from its items, coupons and taxes.
+// addItem and applyCoupon price the order before accepting a change, but an
+// order built by hand or loaded from disk has not been through them, so the
+// pricing error is returned rather than hidden behind a zero amount
+func (o *order) getAmount() (money.Money, error) {
+	r, err := o.receipt()
+	if err != nil {
+		return money.Money{}, err
+	}
+	return r.total, nil
 }
 
 // main is the program's entry point
@@ -63,9 +244,11 @@ func main() {
 	// Create an 'order' struct using named field initialization
 	// The embedded 'customer' struct is initialized using its type name as the field key
 	ccs := order{
-		id:     "1",         // set order ID
-		amount: 45,          // set order amount
-		status: "completed", // set order status
+		id: "1", // set order ID
+		items: []lineItem{ // set what was ordered
+			{sku: "TEA-01", name: "Masala chai", quantity: 3, unitPrice: money.MustParse("15.00 INR")},
+		},
+		status: Delivered, // set order status
 		customer: customer{ // initialize the embedded 'customer' struct
 			name: "Anurag", // set the customer's name inside the embedded struct
 		},
@@ -73,11 +256,247 @@ func main() {
 
 	// Print the entire struct — shows all fields including embedded customer
 	fmt.Println(ccs)
-	// Output: {1 45 completed {Anurag} 0001-01-01 00:00:00 +0000 UTC}
+	// Output: {1  [{TEA-01 Masala chai  3 {1500 INR}}] []  [] delivered { Anurag   []  } {0 0 <nil>} {0 0 <nil>} [] [] <nil> <nil> <nil>}
+	// (fmt can't call String() on unexported fields, so Money shows as minor units)
+
+	amount, err := ccs.getAmount()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("Amount:", amount) // Output: Amount: 45.00 INR
 
 	// Access embedded struct fields directly:
 	// fmt.Println(ccs.name)       → "Anurag"  (promoted from embedded customer)
 	// fmt.Println(ccs.customer.name) → "Anurag" (explicit access also works)
+
+	// ── Admin-only Operations ──────────────────────────────────────────────
+	// Customers may read orders; only staff (and admins, who inherit from staff)
+	// may change an order's status
+	policy, err := rbac.NewEngine(rbac.Policy{Roles: map[user.Role]rbac.RoleDefinition{
+		"customer": {Permissions: []user.Permission{"create:orders", "read:orders"}},
+		"staff":    {Inherits: []user.Role{"customer"}, Permissions: []user.Permission{"update:orders"}},
+		"admin":    {Inherits: []user.Role{"staff"}, Permissions: []user.Permission{"*:orders", "*:payments"}},
+	}})
+	if err != nil {
+		fmt.Println("policy:", err)
+		return
+	}
+
+	shopper := user.User{Email: "Anurag@gmail.com", Name: "Anurag", Roles: []user.Role{"customer"}}
+	admin := user.User{Email: "admin@example.com", Name: "Admin", Roles: []user.Role{"admin"}}
+
+	fmt.Println(ccs.changeStatusAs(shopper, policy, Refunded)) // rbac: forbidden: Anurag@gmail.com may not update orders
+	if err := ccs.changeStatusAs(admin, policy, Refunded); err == nil {
+		fmt.Println("status:", ccs.status) // status: refunded
+	}
+
+	// ── The Order Lifecycle ────────────────────────────────────────────────
+	// Hooks run whenever an order leaves or enters a status
+	lc := newLifecycle()
+	lc.OnEnter(Cancelled, func(o *order, c statusChange) {
+		fmt.Println("hook: release stock for order", o.id, "cancelled while", c.From)
+	})
+	lc.OnExit(Prepared, func(o *order, c statusChange) {
+		fmt.Println("hook: order", o.id, "left the kitchen →", c.To)
+	})
+
+	o, _ := newOrder("ORD-102", orderOptions{Lifecycle: lc}) // no event bus, so creating it cannot fail
+	for _, next := range []OrderStatus{Confirmed, Prepared, Delivered} {
+		if err := o.changeStatus(next); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	// Going backwards is refused with a typed error
+	err = o.changeStatus(Recieved)
+	var transitionErr *TransitionError
+	if errors.As(err, &transitionErr) {
+		fmt.Println(err) // order ORD-102: illegal order status transition: delivered → recieved
+	}
+	fmt.Println(errors.Is(err, ErrIllegalTransition)) // true
+
+	o2, _ := newOrder("ORD-103", orderOptions{Lifecycle: lc})
+	o2.changeStatus(Confirmed)
+	o2.changeStatus(Cancelled) // hook: release stock for order ORD-103 cancelled while confirmed
+
+	for _, c := range o.statusHistory() {
+		fmt.Printf("%s  %-9s → %s\n", c.At.Format(time.TimeOnly), c.From, c.To)
+	}
+
+	// ── Customers (see customer.go) ────────────────────────────────────────
+	// A customer is linked to the user account they log in with
+	anurag, err := newCustomer("CUS-1", shopper, "+91 98450 12345")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	anurag.addAddress(address{label: "home", kinds: shippingAddress | billingAddress, line1: "12 MG Road", city: "Bengaluru", region: "KA", postalCode: "560001", country: "IN"})
+	anurag.addAddress(address{label: "office", kinds: shippingAddress, line1: "4th Floor, Tech Park", city: "Panaji", region: "GOA", postalCode: "403001", country: "IN"})
+	fmt.Println(anurag.setDefault(billingAddress, "office")) // invalid address: office is not a billing address
+	home, _ := anurag.defaultAddress(shippingAddress)
+	fmt.Println("ship to:", home) // ship to: 12 MG Road, Bengaluru 560001, KA, IN
+
+	// ── Line Items, Taxes and Coupons ──────────────────────────────────────
+	// GST: 5% on food, 18% on everything else — 12% on food in region "GOA"
+	gst := taxTable{
+		{bips: 1800},
+		{category: "food", bips: 500},
+		{category: "food", region: "GOA", bips: 1200},
+	}
+	cart, _ := newOrder("ORD-104", orderOptions{Region: home.region, Taxes: gst, Customer: anurag})
+	cart.addItem(lineItem{sku: "PZ-MARG-L", name: "Margherita (large)", category: "food", quantity: 2, unitPrice: money.MustParse("349.00 INR")})
+	cart.addItem(lineItem{sku: "BEV-COLA", name: "Cola 500ml", category: "food", quantity: 3, unitPrice: money.MustParse("45.50 INR")})
+	cart.addItem(lineItem{sku: "MUG-LOGO", name: "Branded mug", category: "merch", quantity: 1, unitPrice: money.MustParse("199.99 INR")})
+
+	cart.applyCoupon(coupon{code: "WELCOME10", kind: percentOff, bips: 1000, stackable: true})
+	cart.applyCoupon(coupon{code: "FLAT50", kind: fixedOff, amount: money.MustParse("50.00 INR"), stackable: true})
+	fmt.Println(cart.applyCoupon(coupon{code: "MEGA40", kind: percentOff, bips: 4000})) // coupon cannot be combined with other coupons: MEGA40 with WELCOME10
+
+	r, _ := cart.receipt()
+	fmt.Println(r)
+	fmt.Println("Amount:", r.total)
+
+	// ── Audit Trail (see audit.go) ─────────────────────────────────────────
+	// A fake clock that ticks one minute per call makes the timestamps predictable
+	tick := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	clock := func() time.Time {
+		tick = tick.Add(time.Minute)
+		return tick
+	}
+	ticket, _ := newOrder("ORD-106", orderOptions{Now: clock})
+	ticket.addItem(lineItem{sku: "TEA-01", name: "Masala chai", quantity: 2, unitPrice: money.MustParse("15.00 INR")})
+	ticket.addItem(lineItem{sku: "SAM-02", name: "Samosa", quantity: 1, unitPrice: money.MustParse("12.00 INR")})
+	ticket.applyCoupon(coupon{code: "FLAT5", kind: fixedOff, amount: money.MustParse("5.00 INR")})
+	ticket.changeStatus(Confirmed)
+	ticket.changeStatusAs(admin, policy, Cancelled)
+	fmt.Println("created", ticket.createdAt.Format(time.TimeOnly), "updated", ticket.updatedAt.Format(time.TimeOnly)) // created 10:01:00 updated 10:06:00
+	for _, e := range ticket.auditTrail() {
+		fmt.Println(e)
+	}
+	// 10:02:00  system             items    "" → "2 × TEA-01"
+	// 10:03:00  system             items    "2 × TEA-01" → "2 × TEA-01, 1 × SAM-02"
+	// 10:04:00  system             coupons  "" → "FLAT5"
+	// 10:05:00  system             status   "recieved" → "confirmed"
+	// 10:06:00  admin@example.com  status   "confirmed" → "cancelled"
+
+	// ── Order Events (see packages/events) ─────────────────────────────────
+	// The kitchen display hears about every order as it happens; the mailer
+	// runs on its own goroutine so a slow email never holds up the order
+	bus := events.New(events.Options{Outbox: events.NewMemoryOutbox()})
+	bus.Subscribe("kitchen", func(ctx context.Context, env events.Envelope) error {
+		switch e := env.Event.(type) {
+		case events.OrderCreated:
+			fmt.Println("kitchen: new order", e.OrderID)
+		case events.StatusChanged:
+			fmt.Println("kitchen:", e.OrderID, e.From, "→", e.To, "by", e.Actor)
+		}
+		return nil
+	})
+	mailed := make(chan string, 4)
+	bus.SubscribeAsync("mailer", events.On(func(ctx context.Context, e events.StatusChanged, _ events.Envelope) error {
+		mailed <- e.OrderID + " is now " + e.To
+		return nil
+	}))
+	live, err := newOrder("ORD-107", orderOptions{Events: bus}) // kitchen: new order ORD-107
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	live.changeStatus(Confirmed)                 // kitchen: ORD-107 recieved → confirmed by system
+	live.changeStatusAs(admin, policy, Prepared) // kitchen: ORD-107 confirmed → prepared by admin@example.com
+	bus.Close()                                  // waits for the mailer
+	close(mailed)
+	for m := range mailed {
+		fmt.Println("mailer:", m)
+	}
+
+	// An event that cannot be recorded stops the change it describes
+	fmt.Println(live.changeStatus(Delivered)) // order ORD-107: events: bus closed
+	fmt.Println(live.status)                  // prepared
+
+	// ── Saving Orders (see repository.go) ──────────────────────────────────
+	// An append-only JSON-lines file: every Save adds one line and fsyncs it
+	dir, err := os.MkdirTemp("", "orders")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "orders.jsonl")
+
+	repo, err := openFileOrderRepository(path)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, ord := range []*order{o, o2, cart} {
+		if err := repo.Save(ord); err != nil {
+			fmt.Println(err)
+		}
+	}
+	repo.Close()
+
+	// Simulate a crash half-way through a write: a torn last line
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"id":"ORD-105","stat`)
+	f.Close()
+
+	// Reopening replays the file and cuts the torn line off
+	repo, err = openFileOrderRepository(path)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer repo.Close()
+
+	saved, err := repo.Get("ORD-104")
+	if err == nil {
+		if amount, err := saved.getAmount(); err != nil {
+			fmt.Println(err)
+		} else {
+			fmt.Println("reloaded:", saved.id, saved.name, saved.status, amount) // reloaded: ORD-104 Anurag recieved 947.23 INR
+		}
+	}
+	_, err = repo.Get("ORD-105")
+	fmt.Println(errors.Is(err, ErrOrderNotFound)) // true
+
+	page, total, _ := repo.List(orderQuery{Customer: "anurag"})
+	fmt.Println("Anurag's orders:", len(page), "of", total) // Anurag's orders: 1 of 1
+	page, total, _ = repo.List(orderQuery{CreatedFrom: o.createdAt, Limit: 2})
+	for _, ord := range page {
+		fmt.Println(" ", ord.id, ord.status)
+	}
+	fmt.Println("  page 1 of", total, "orders") // page 1 of 3 orders
+
+	// ── A Customer's Order History ─────────────────────────────────────────
+	// Support sees every order of a customer, with lifetime spend — carts and
+	// cancelled or refunded orders count as orders, but not as spend
+	office, _ := anurag.address("office")
+	for i, final := range []OrderStatus{Delivered, Cancelled} {
+		past, _ := newOrder(fmt.Sprintf("ORD-2%02d", i), orderOptions{Region: office.region, Taxes: gst, Customer: anurag})
+		past.addItem(lineItem{sku: "TEA-01", name: "Masala chai", category: "food", quantity: 4, unitPrice: money.MustParse("15.00 INR")})
+		past.changeStatus(Confirmed)
+		if final == Delivered {
+			past.changeStatus(Prepared)
+		}
+		past.changeStatus(final)
+		repo.Save(past)
+	}
+	h, err := anurag.history(repo)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, ord := range h.orders {
+		amount, err := ord.getAmount()
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Printf("  %s  %-9s %s\n", ord.id, ord.status, amount)
+	}
+	fmt.Println("orders:", len(h.orders), "delivered:", h.byStatus[Delivered], "spent:", h.lifetimeSpend["INR"]) // orders: 3 delivered: 1 spent: 67.20 INR
 }
 
 // ─────────────────────────────────────────────────────────────────────────────
@@ -85,19 +504,21 @@ func main() {
 // ─────────────────────────────────────────────────────────────────────────────
 // func main() {
 // 	// Create an order using the constructor function
-// 	myOrder := newOrder("ORD-101", 199.99, "pending")
+// 	myOrder, _ := newOrder("ORD-101", orderOptions{})
+// 	myOrder.addItem(lineItem{sku: "BK-1", name: "Go book", quantity: 1, unitPrice: money.MustParse("199.99 INR")})
 //
 // 	// Access fields on the returned pointer
 // 	fmt.Println("Order ID:", myOrder.id)          // Output: Order ID: ORD-101
-// 	fmt.Println("Amount:", myOrder.getAmount())   // Output: Amount: 199.99
-// 	fmt.Println("Status:", myOrder.status)        // Output: Status: pending
+// 	amount, _ := myOrder.getAmount()
+// 	fmt.Println("Amount:", amount)                 // Output: Amount: 199.99 INR
+// 	fmt.Println("Status:", myOrder.status)        // Output: Status: recieved
 //
 // 	// Set the creation timestamp
 // 	myOrder.createdAt = time.Now()
 //
 // 	// Change the order status using the method (modifies via pointer)
-// 	myOrder.changeStatus("shipped")
-// 	fmt.Println("Updated Status:", myOrder.status) // Output: Updated Status: shipped
+// 	myOrder.changeStatus(Confirmed)
+// 	fmt.Println("Updated Status:", myOrder.status) // Output: Updated Status: confirmed
 //
 // 	// Set the embedded customer's name
 // 	myOrder.customer = customer{name: "Priya"}